@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type setRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+// GetAllUsers returns every user
+//
+//	@Summary		Returns all users
+//	@Description	Returns all users including their role and suspension state
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]database.User
+//	@Router			/api/v1/admin/users [get]
+//	@Security		BearerAuth
+func (app *application) getAllUsers(c *gin.Context) {
+	users, err := app.models.Users.GetAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (app *application) userFromParam(c *gin.Context) (*database.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return nil, false
+	}
+
+	user, err := app.models.Users.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive user"})
+		return nil, false
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	if user.Id == app.GetUserFromContext(c).Id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own account here"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// userFromParam loads the user from the :id parameter for the admin
+// handlers. Admins cannot suspend, demote or delete themselves, so there
+// is always at least one admin left.
+
+// SuspendUser suspends a user
+//
+//	@Summary		Suspends a user
+//	@Description	Blocks the user from logging in and revokes all their sessions
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"User ID"
+//	@Success		204
+//	@Router			/api/v1/admin/users/{id}/suspend [post]
+//	@Security		BearerAuth
+func (app *application) suspendUser(c *gin.Context) {
+	user, ok := app.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	if user.Role == database.RoleAdmin && !hasRole(app.GetUserFromContext(c), database.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to suspend an admin"})
+		return
+	}
+
+	if err := app.models.Users.SetSuspended(c.Request.Context(), user.Id, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend user"})
+		return
+	}
+
+	if err := app.models.Sessions.RevokeAllForUser(c.Request.Context(), user.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	app.recheckAccess(user.Id)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// UnsuspendUser lifts the suspension of a user
+//
+//	@Summary		Unsuspends a user
+//	@Description	Allows a suspended user to log in again
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"User ID"
+//	@Success		204
+//	@Router			/api/v1/admin/users/{id}/suspend [delete]
+//	@Security		BearerAuth
+func (app *application) unsuspendUser(c *gin.Context) {
+	user, ok := app.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Users.SetSuspended(c.Request.Context(), user.Id, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsuspend user"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// SetUserRole changes the role of a user
+//
+//	@Summary		Changes the role of a user
+//	@Description	Sets the role of a user to user, moderator or admin
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int				true	"User ID"
+//	@Param			role	body	setRoleRequest	true	"Role"
+//	@Success		204
+//	@Router			/api/v1/admin/users/{id}/role [put]
+//	@Security		BearerAuth
+func (app *application) setUserRole(c *gin.Context) {
+	var request setRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := app.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Users.SetRole(c.Request.Context(), user.Id, request.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change role"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// DeleteUser deletes a user
+//
+//	@Summary		Deletes a user
+//	@Description	Deletes a user together with their events and attendances
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"User ID"
+//	@Success		204
+//	@Router			/api/v1/admin/users/{id} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteUser(c *gin.Context) {
+	user, ok := app.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Users.Delete(c.Request.Context(), user.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+/*
+The admin handlers are mounted behind RequireRole, see routes.go.
+Moderators may list and suspend users, changing roles and deleting
+accounts is reserved for admins. Events are moderated through the regular
+event routes, the policies in policy.go let moderators and admins edit
+and delete any event.
+*/
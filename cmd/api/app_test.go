@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+	"github.com/schlafer/EventApp/internal/database/dbtest"
+	"github.com/schlafer/EventApp/internal/hub"
+	"github.com/schlafer/EventApp/internal/mailer"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testServer struct {
+	*httptest.Server
+	app *application
+}
+
+func newTestServer(t *testing.T, db *sql.DB, dialect string, configure ...func(app *application)) *testServer {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	app := &application{
+		baseURL:          "http://localhost:8080",
+		jwtSecret:        "test-secret",
+		accessTokenTTL:   15 * time.Minute,
+		refreshTokenTTL:  time.Hour,
+		verificationTTL:  time.Hour,
+		passwordResetTTL: time.Hour,
+		webhookRetryBase: time.Second,
+		webhookClient:    newWebhookClient(),
+		webhookWake:      make(chan struct{}, 1),
+		hub:              hub.New(streamHistory, streamBuffer),
+		chat:             hub.New(0, chatBuffer),
+		models:           database.NewModels(db, dialect, database.Timeouts{Default: 10 * time.Second}),
+		mailer:           mailer.NewLogMailer(io.Discard),
+	}
+
+	for _, fn := range configure {
+		fn(app)
+	}
+
+	server := httptest.NewServer(app.routes())
+	t.Cleanup(func() {
+		server.Close()
+		app.wg.Wait()
+	})
+
+	return &testServer{Server: server, app: app}
+}
+
+func withTestServer(t *testing.T, test func(t *testing.T, server *testServer)) {
+	dbtest.ForEachDialect(t, func(t *testing.T, db *sql.DB, dialect string) {
+		test(t, newTestServer(t, db, dialect))
+	})
+}
+
+/*
+withTestServer runs test against the full API, once for every database
+dialect, see dbtest.ForEachDialect. The application is set up like in
+main, but without background workers and with emails thrown away.
+newTestServer takes functions that change the configuration before the
+routes are built, like the email verification mode.
+*/
+
+func (s *testServer) newUser(t *testing.T, name string) (*database.User, string) {
+	t.Helper()
+
+	user := &database.User{Email: strings.ToLower(name) + "@example.com", Name: name, Password: "hash"}
+	if err := s.app.models.Users.Insert(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := s.app.issueTokens(context.Background(), user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user, tokens.Token
+}
+
+// newUser registers a user directly in the database and returns it
+// together with an access token.
+
+func (s *testServer) do(t *testing.T, method, path, token, body string) *http.Response {
+	t.Helper()
+
+	request, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := s.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+
+	return response
+}
+
+func (s *testServer) newEvent(t *testing.T, token string, fields string) int {
+	t.Helper()
+
+	body := `{"name": "Go meetup", "description": "Talks about Go and databases", "location": "Berlin",
+		"startsAt": "2026-11-05T18:00:00Z", "endsAt": "2026-11-05T21:00:00Z", "status": "published"` + fields + `}`
+
+	response := s.do(t, http.MethodPost, "/api/v1/events", token, body)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("creating an event = %s", response.Status)
+	}
+
+	var event database.Event
+	decodeJSON(t, response, &event)
+	return event.Id
+}
+
+// newEvent creates a published event through the API, fields are extra
+// JSON members like `, "capacity": 2`.
+
+func decodeJSON(t *testing.T, response *http.Response, target interface{}) {
+	t.Helper()
+
+	if err := json.NewDecoder(response.Body).Decode(target); err != nil {
+		t.Fatalf("decoding the response: %v", err)
+	}
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/schlafer/EventApp/internal/database"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,7 +19,13 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
 }
 
 type registerRequest struct {
@@ -28,7 +36,7 @@ type registerRequest struct {
 
 // RegisterUser registers a new user
 // @Summary		Registers a new user
-// @Description	Registers a new user
+// @Description	Registers a new user and emails a token to verify the address
 // @Tags			auth
 // @Accept			json
 // @Produce		json
@@ -56,15 +64,25 @@ func (app *application) registerUser(c *gin.Context) {
 		Name:     register.Name,
 	}
 
-	err = app.models.Users.Insert(&user)
+	err = app.models.Users.Insert(c.Request.Context(), &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
 		return
 	}
 
+	app.background(func() {
+		if err := app.sendVerificationEmail(context.Background(), &user); err != nil {
+			log.Print(err)
+		}
+	})
+
 	c.JSON(http.StatusCreated, user)
 }
 
+// The user exists once Insert succeeded, so a failing verification email
+// does not fail the registration. The user can ask for a new token with
+// resendVerification.
+
 // Login logs in a user
 //
 //	@Summary		Logs in a user
@@ -82,7 +100,7 @@ func (app *application) login(c *gin.Context) {
 		return
 	}
 
-	existingUser, err := app.models.Users.GetByEmail(auth.Email)
+	existingUser, err := app.models.Users.GetByEmail(c.Request.Context(), auth.Email)
 	if existingUser == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
@@ -99,17 +117,107 @@ func (app *application) login(c *gin.Context) {
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userId": existingUser.Id,
-		"exp":    time.Now().Add(time.Hour * 72).Unix(),
-	})
+	if existingUser.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account has been suspended"})
+		return
+	}
+
+	tokens, err := app.issueTokens(c.Request.Context(), existingUser.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+
+}
 
-	tokenString, err := token.SignedString([]byte(app.jwtSecret))
+// Refresh exchanges a refresh token for new tokens
+//
+//	@Summary		Refreshes the access token
+//	@Description	Exchanges a refresh token for a new access token and a new refresh token. Every refresh token can only be used once.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	body		refreshRequest	true	"Refresh token"
+//	@Success		200		{object}	loginResponse
+//	@Router			/api/v1/auth/refresh [post]
+func (app *application) refresh(c *gin.Context) {
+	var request refreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refreshToken, refreshHash, err := generateToken()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, loginResponse{Token: tokenString})
+	session, err := app.models.Sessions.Rotate(c.Request.Context(), hashToken(request.RefreshToken), refreshHash, time.Now().Add(app.refreshTokenTTL))
+	if errors.Is(err, database.ErrInvalidRefreshToken) || errors.Is(err, database.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	accessToken, err := app.signAccessToken(session.UserId, session.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(app.accessTokenTTL.Seconds()),
+	})
+}
+
+// Logout ends the current session
+//
+//	@Summary		Logs out
+//	@Description	Revokes the current session, its access and refresh tokens stop working and the chats and event streams opened with it are closed
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Success		204
+//	@Router			/api/v1/auth/logout [post]
+//	@Security		BearerAuth
+func (app *application) logout(c *gin.Context) {
+	if err := app.models.Sessions.Revoke(c.Request.Context(), app.GetSessionIdFromContext(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	app.recheckAccess(app.GetUserFromContext(c).Id)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// LogoutAll ends every session of the current user
+//
+//	@Summary		Logs out of all sessions
+//	@Description	Revokes every session of the current user on all devices and closes their chats and event streams
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Success		204
+//	@Router			/api/v1/auth/logout-all [post]
+//	@Security		BearerAuth
+func (app *application) logoutAll(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	if err := app.models.Sessions.RevokeAllForUser(c.Request.Context(), user.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	app.recheckAccess(user.Id)
 
+	c.JSON(http.StatusNoContent, nil)
 }
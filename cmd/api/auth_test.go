@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+)
+
+func (s *testServer) passwordResetToken(t *testing.T, userId int) string {
+	t.Helper()
+
+	token, hash, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.app.models.Tokens.Insert(context.Background(), userId, hash, database.ScopePasswordReset, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// passwordResetToken stores a reset token like forgotPassword does and
+// returns the plain token that would have been emailed.
+
+func TestResetPasswordRevokesSessions(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		user, _ := server.newUser(t, "Owner")
+
+		var sessions []*loginResponse
+		for i := 0; i < 2; i++ {
+			tokens, err := server.app.issueTokens(context.Background(), user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessions = append(sessions, tokens)
+		}
+
+		token := server.passwordResetToken(t, user.Id)
+		other := server.passwordResetToken(t, user.Id)
+
+		response := server.do(t, http.MethodPost, "/api/v1/auth/reset-password", "", `{"token": "`+token+`", "password": "new-password"}`)
+		if response.StatusCode != http.StatusNoContent {
+			t.Fatalf("resetting the password = %s", response.Status)
+		}
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			token  string
+			body   string
+			status int
+		}{
+			{"first access token", http.MethodGet, "/api/v1/webhooks", sessions[0].Token, "", http.StatusUnauthorized},
+			{"second access token", http.MethodGet, "/api/v1/webhooks", sessions[1].Token, "", http.StatusUnauthorized},
+			{"first refresh token", http.MethodPost, "/api/v1/auth/refresh", "", `{"refreshToken": "` + sessions[0].RefreshToken + `"}`, http.StatusUnauthorized},
+			{"second refresh token", http.MethodPost, "/api/v1/auth/refresh", "", `{"refreshToken": "` + sessions[1].RefreshToken + `"}`, http.StatusUnauthorized},
+			{"used reset token", http.MethodPost, "/api/v1/auth/reset-password", "", `{"token": "` + token + `", "password": "another-password"}`, http.StatusBadRequest},
+			{"other reset token", http.MethodPost, "/api/v1/auth/reset-password", "", `{"token": "` + other + `", "password": "another-password"}`, http.StatusBadRequest},
+			{"new password", http.MethodPost, "/api/v1/login", "", `{"email": "` + user.Email + `", "password": "new-password"}`, http.StatusOK},
+		}
+
+		for _, tc := range tests {
+			response := server.do(t, tc.method, tc.path, tc.token, tc.body)
+			if response.StatusCode != tc.status {
+				t.Errorf("%s = %s, want %d", tc.name, response.Status, tc.status)
+			}
+		}
+	})
+}
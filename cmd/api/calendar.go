@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+	"github.com/schlafer/EventApp/internal/ical"
+
+	"github.com/gin-gonic/gin"
+)
+
+type calendarFeedResponse struct {
+	URL string `json:"url"`
+}
+
+func (app *application) icalEvent(event *database.Event) (ical.Event, error) {
+	host := "eventapp"
+	if u, err := url.Parse(app.baseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	zone := event.Zone()
+	start, end := event.StartsAt.In(zone), event.EndsAt.In(zone)
+
+	result := ical.Event{
+		UID:         fmt.Sprintf("event-%d@%s", event.Id, host),
+		Start:       start,
+		End:         end,
+		AllDay:      isMidnight(start) && isMidnight(end),
+		Sequence:    event.Version - 1,
+		Summary:     event.Name,
+		Description: event.Description,
+		Location:    event.Location,
+		RRule:       event.Rrule,
+		URL:         fmt.Sprintf("%s/api/v1/events/%d", app.baseURL, event.Id),
+	}
+
+	if event.Status == database.EventCancelled {
+		result.Status = ical.StatusCancelled
+	}
+
+	for _, exdate := range event.ExDates {
+		date, err := event.StartOn(exdate)
+		if err != nil {
+			return ical.Event{}, err
+		}
+		result.ExDates = append(result.ExDates, date)
+	}
+
+	return result, nil
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
+}
+
+/*
+icalEvent converts an event for the iCalendar export. The UID only
+depends on the id of the event and the host of BASE_URL, so calendar apps
+recognise an event again after it was changed with updateEvent and update
+it instead of adding a copy. Events that start and end at local midnight
+are exported as all-day events, this includes every event that was created
+before events had a time. A cancelled event is exported with
+STATUS:CANCELLED so calendar apps strike it through or remove it. SEQUENCE
+follows the version, which SetStatus raises as well, so the apps take the
+cancellation as a newer revision of the event they already have.
+*/
+
+func (app *application) writeCalendar(c *gin.Context, filename string, calendar *ical.Calendar) {
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	if err := calendar.Write(c.Writer); err != nil {
+		c.Error(err)
+	}
+}
+
+// ExportEvent returns an event in iCalendar format
+//
+//	@Summary		Returns an event in iCalendar format
+//	@Description	Returns the event as an RFC 5545 .ics file. A recurring event contains its RRULE, EXDATE and the occurrences that were edited on their own.
+//	@Tags			calendar
+//	@Produce		text/calendar
+//	@Param			id		path		int		true	"Event ID"
+//	@Param			invite	query		string	false	"Invite token of a private event"
+//	@Success		200		{string}	string
+//	@Router			/api/v1/events/{id}.ics [get]
+func (app *application) exportEvent(c *gin.Context, idParam string) {
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.requireView(c, event) {
+		return
+	}
+
+	master, err := app.icalEvent(event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export event"})
+		return
+	}
+
+	calendar := &ical.Calendar{Name: event.Name, Events: []ical.Event{master}}
+
+	if event.IsRecurring() {
+		occurrences, err := app.models.Events.GetModifiedOccurrences(c.Request.Context(), event.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive occurrences"})
+			return
+		}
+
+		for _, occurrence := range occurrences {
+			exception, err := app.icalEvent(occurrence)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export event"})
+				return
+			}
+
+			exception.UID = master.UID
+			exception.AllDay = master.AllDay
+			exception.RecurrenceId, err = event.StartOn(occurrence.OccurrenceDate)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export event"})
+				return
+			}
+			calendar.Events = append(calendar.Events, exception)
+		}
+	}
+
+	app.writeCalendar(c, fmt.Sprintf("event-%d.ics", event.Id), calendar)
+}
+
+/*
+Gin cannot route /events/:id.ics next to /events/:id, so getEvent hands
+requests whose id ends in .ics over to exportEvent.
+Edited occurrences keep the UID of the series and name the occurrence
+they replace in RECURRENCE-ID, that is how iCalendar expresses exceptions.
+*/
+
+// CreateCalendarFeed creates the personal calendar feed of the current user
+//
+//	@Summary		Creates the personal calendar feed of the current user
+//	@Description	Returns a secret URL that calendar apps can subscribe to. It lists every event the user attends. Calling it again replaces the URL, the old one stops working.
+//	@Tags			calendar
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	calendarFeedResponse
+//	@Router			/api/v1/calendar/feed [post]
+//	@Security		BearerAuth
+func (app *application) createCalendarFeed(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	token, hash, err := generateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+
+	if err := app.models.Feeds.Rotate(c.Request.Context(), user.Id, hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, calendarFeedResponse{
+		URL: fmt.Sprintf("%s/api/v1/calendar/%s.ics", app.baseURL, token),
+	})
+}
+
+// DeleteCalendarFeed turns off the personal calendar feed of the current user
+//
+//	@Summary		Turns off the personal calendar feed of the current user
+//	@Description	The feed URL stops working
+//	@Tags			calendar
+//	@Accept			json
+//	@Produce		json
+//	@Success		204
+//	@Router			/api/v1/calendar/feed [delete]
+//	@Security		BearerAuth
+func (app *application) deleteCalendarFeed(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	if err := app.models.Feeds.Delete(c.Request.Context(), user.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetCalendarFeed returns a personal calendar feed
+//
+//	@Summary		Returns a personal calendar feed
+//	@Description	Lists every event the owner of the token attends in iCalendar format. Meant to be subscribed to from a calendar app.
+//	@Tags			calendar
+//	@Produce		text/calendar
+//	@Param			token	path		string	true	"Feed token"
+//	@Success		200		{string}	string
+//	@Router			/api/v1/calendar/{token}.ics [get]
+func (app *application) getCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	userId, err := app.models.Feeds.GetUserId(c.Request.Context(), hashToken(token))
+	if errors.Is(err, database.ErrInvalidToken) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive calendar feed"})
+		return
+	}
+
+	events, err := app.models.Attendees.GetEventsByAttendee(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+
+	calendar := &ical.Calendar{Name: "EventApp"}
+	for _, event := range events {
+		entry, err := app.icalEvent(event)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export events"})
+			return
+		}
+		calendar.Events = append(calendar.Events, entry)
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Hour.Seconds())))
+	app.writeCalendar(c, "eventapp.ics", calendar)
+}
+
+/*
+The feed is built from the events the user attends, occurrences of a
+recurring event show up as events of their own. It is public on purpose:
+calendar apps cannot send an access token, the secret token in the URL
+is the only thing that protects it.
+*/
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func readCalendar(t *testing.T, response *http.Response) []map[string]string {
+	t.Helper()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("exporting the event = %s", response.Status)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []map[string]string
+	for _, line := range strings.Split(string(body), "\r\n") {
+		name, value, _ := strings.Cut(line, ":")
+		name, _, _ = strings.Cut(name, ";")
+
+		switch {
+		case line == "BEGIN:VEVENT":
+			events = append(events, map[string]string{})
+		case len(events) > 0 && name != "END":
+			events[len(events)-1][name] = value
+		}
+	}
+
+	return events
+}
+
+// readCalendar returns the properties of every VEVENT of an exported
+// calendar by name, without their parameters.
+
+func TestExportEventUIDSequenceAndStatus(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, token := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, token, `, "rrule": "FREQ=WEEKLY;COUNT=3"`)
+		path := fmt.Sprintf("/api/v1/events/%d", eventId)
+		uid := fmt.Sprintf("event-%d@localhost", eventId)
+
+		tests := []struct {
+			name     string
+			method   string
+			path     string
+			body     string
+			sequence []string
+			summary  []string
+			status   string
+		}{
+			{"created", "", "", "", []string{""}, []string{"Go meetup"}, ""},
+			{"renamed", http.MethodPatch, path, `{"name": "Go and databases"}`, []string{"1"}, []string{"Go and databases"}, ""},
+			{"occurrence edited", http.MethodPut, path + "/occurrences/2026-11-12?scope=this",
+				`{"name": "Go meetup special", "description": "Talks about Go and databases", "location": "Hamburg",
+				"startsAt": "2026-11-12T18:00:00Z", "endsAt": "2026-11-12T21:00:00Z"}`,
+				[]string{"1", "1"}, []string{"Go and databases", "Go meetup special"}, ""},
+			{"cancelled", http.MethodPost, path + "/cancel", `{"reason": "Rain"}`,
+				[]string{"2", "2"}, []string{"Go and databases", "Go meetup special"}, "CANCELLED"},
+		}
+
+		for _, tc := range tests {
+			if tc.method != "" {
+				if response := server.do(t, tc.method, tc.path, token, tc.body); response.StatusCode != http.StatusOK {
+					t.Fatalf("%s: %s %s = %s", tc.name, tc.method, tc.path, response.Status)
+				}
+			}
+
+			events := readCalendar(t, server.do(t, http.MethodGet, path+".ics", "", ""))
+			if len(events) != len(tc.summary) {
+				t.Fatalf("%s: %d events, want %d", tc.name, len(events), len(tc.summary))
+			}
+
+			for i, event := range events {
+				if event["UID"] != uid {
+					t.Errorf("%s: UID of event %d = %q, want %q", tc.name, i, event["UID"], uid)
+				}
+				if event["SEQUENCE"] != tc.sequence[i] {
+					t.Errorf("%s: SEQUENCE of event %d = %q, want %q", tc.name, i, event["SEQUENCE"], tc.sequence[i])
+				}
+				if event["STATUS"] != tc.status {
+					t.Errorf("%s: STATUS of event %d = %q, want %q", tc.name, i, event["STATUS"], tc.status)
+				}
+				if event["SUMMARY"] != tc.summary[i] {
+					t.Errorf("%s: SUMMARY of event %d = %q, want %q", tc.name, i, event["SUMMARY"], tc.summary[i])
+				}
+			}
+			if len(events) > 1 && events[1]["RECURRENCE-ID"] != "20261112T180000Z" {
+				t.Errorf("%s: RECURRENCE-ID = %q, want the edited date", tc.name, events[1]["RECURRENCE-ID"])
+			}
+		}
+	})
+}
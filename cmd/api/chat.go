@@ -0,0 +1,605 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/schlafer/EventApp/internal/database"
+	"github.com/schlafer/EventApp/internal/hub"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	chatMaxMessage = 2000
+	chatReadLimit  = 8192
+	chatWriteWait  = 10 * time.Second
+	chatPongWait   = 60 * time.Second
+	chatPingPeriod = 50 * time.Second
+	chatBuffer     = 64
+	chatRecheck    = "recheck"
+
+	chatProtocol        = "eventapp.chat"
+	tokenProtocolPrefix = "access_token."
+)
+
+var errChatRevoked = errors.New("chat access revoked")
+
+var chatUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{chatProtocol},
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+/*
+The chat does not rely on cookies, every connection carries the access
+token of its user, so a page on another origin cannot act for the user
+and the origin does not need to be checked.
+*/
+
+type chatClientFrame struct {
+	Type string `json:"type"`
+	Body string `json:"body"`
+}
+
+type chatFrame struct {
+	Type    string                `json:"type"`
+	Message *database.ChatMessage `json:"message,omitempty"`
+	Id      int                   `json:"id,omitempty"`
+	UserId  int                   `json:"userId,omitempty"`
+	Until   *time.Time            `json:"until,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+/*
+Clients send {"type":"message","body":"..."} to write in the chat. The
+server sends every new message as {"type":"message","message":{...}},
+{"type":"deleted","id":...} when a message was removed, {"type":"muted"}
+and {"type":"unmuted"} with the userId when a moderator muted or unmuted
+somebody, and {"type":"error"} when a frame of this client was refused.
+*/
+
+type listChatMessagesRequest struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string `form:"cursor"`
+}
+
+type listChatMessagesResponse struct {
+	Messages []*database.ChatMessage `json:"messages"`
+	Metadata database.Metadata       `json:"metadata"`
+}
+
+type muteRequest struct {
+	UserId  int `json:"userId" binding:"required,min=1"`
+	Minutes int `json:"minutes" binding:"omitempty,min=1,max=525600"`
+}
+
+func (app *application) chatAccess(c *gin.Context) (*database.Event, bool, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return nil, false, false
+	}
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return nil, false, false
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false, false
+	}
+
+	member, _, ok := app.viewEvent(c, event)
+	if !ok {
+		return nil, false, false
+	}
+
+	if event.IsRecurring() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Every occurrence of a recurring event has its own chat"})
+		return nil, false, false
+	}
+
+	moderator, allowed, err := app.chatRole(c.Request.Context(), app.GetUserFromContext(c), member, event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive attendee"})
+		return nil, false, false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only attendees can join the chat of this event"})
+		return nil, false, false
+	}
+
+	return event, moderator, true
+}
+
+func (app *application) chatRole(ctx context.Context, user *database.User, member string, event *database.Event) (bool, bool, error) {
+	if app.can(user, member, actionModerateChat, event) {
+		return true, true, nil
+	}
+
+	attendee, err := app.models.Attendees.GetByEventAndAttendee(ctx, event.Id, user.Id)
+	if err != nil {
+		return false, false, err
+	}
+
+	return false, attendee != nil && attendee.Status != database.StatusDeclined, nil
+}
+
+/*
+chatAccess lets the attendees of an event into its chat, everybody that
+answered going or maybe, and the users that may moderate it: the owner,
+co-hosts, moderators and admins. The second result tells whether the user
+is a moderator. chatRole makes the decision, it returns whether the user
+is a moderator and whether they may take part at all.
+*/
+
+func (app *application) inChat(ctx context.Context, eventId, userId, sessionId int) (bool, error) {
+	event, err := app.models.Events.Get(ctx, eventId)
+	if err != nil || event == nil {
+		return false, err
+	}
+
+	session, err := app.models.Sessions.Get(ctx, sessionId)
+	if err != nil || session == nil || !session.Active() || session.UserId != userId {
+		return false, err
+	}
+
+	user, err := app.models.Users.Get(ctx, userId)
+	if err != nil || user == nil || user.SuspendedAt != nil {
+		return false, err
+	}
+
+	member := ""
+	if !isOwner(user, event) {
+		member, err = app.models.Members.GetRole(ctx, seriesIdOf(event), user.Id)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	_, allowed, err := app.chatRole(ctx, user, member, event)
+	return allowed, err
+}
+
+func (app *application) recheckAccess(userId int) {
+	app.publishChat(0, chatFrame{Type: chatRecheck, UserId: userId})
+}
+
+func recheckedUser(message hub.Message) int {
+	var frame chatFrame
+	if err := json.Unmarshal(message.Data, &frame); err != nil {
+		return 0
+	}
+	return frame.UserId
+}
+
+/*
+A connection to the chat outlives the request that opened it, so the
+access of its user is checked again from the database with inChat before
+every message they send, and whenever recheckAccess says that something
+about the user changed: they cancelled their RSVP, declined, were removed
+from the attendees or the members, were suspended, logged out or reset
+their password. inChat checks the session of the connection as well, so a
+logout only ends the connections opened with that session. The recheck goes to
+the connections of the user in every chat, event id 0, and to their event
+streams, and is not passed on to the clients. A user that lost their access is disconnected with a
+policy violation. The recheck frame only reaches the connections of this
+server, with several servers the check before every message still keeps
+the user from writing.
+*/
+
+func (app *application) publishChat(eventId int, frame chatFrame) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Failed to publish chat %s of event %d: %v", frame.Type, eventId, err)
+		return
+	}
+
+	app.chat.Publish(hub.Message{Type: frame.Type, EventId: eventId, Data: data})
+}
+
+// publishChat sends a frame to everybody connected to the chat of the
+// event. The chat has a hub of its own, its messages never reach the
+// event streams.
+
+// Chat joins the chat of an event
+//
+//	@Summary		Joins the chat of an event
+//	@Description	Upgrades the connection to a WebSocket. Browsers offer the subprotocols eventapp.chat and access_token.<token>, for example new WebSocket(url, ["eventapp.chat", "access_token." + token]); the server answers with eventapp.chat. Other clients can send the Authorization header. Only attendees, the owner, co-hosts, moderators and admins can join. Send {"type":"message","body":"..."} to write; new messages, deletions and mutes are pushed to every connection.
+//	@Tags			chat
+//	@Param			id	path	int	true	"Event ID"
+//	@Success		101
+//	@Router			/api/v1/events/{id}/chat [get]
+//	@Security		BearerAuth
+func (app *application) joinChat(c *gin.Context) {
+	event, _, ok := app.chatAccess(c)
+	if !ok {
+		return
+	}
+
+	conn, err := chatUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	subscription, _, _ := app.chat.Subscribe(func(message hub.Message) bool {
+		return message.EventId == event.Id || message.Type == chatRecheck
+	}, "")
+	defer app.chat.Unsubscribe(subscription)
+
+	ctx := c.Request.Context()
+	user := app.GetUserFromContext(c)
+	sessionId := app.GetSessionIdFromContext(c)
+	replies := make(chan chatFrame, chatBuffer)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		conn.SetReadLimit(chatReadLimit)
+		conn.SetReadDeadline(time.Now().Add(chatPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(chatPongWait))
+		})
+
+		for {
+			var frame chatClientFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+
+			reply, err := app.receiveChat(ctx, event, user, sessionId, frame)
+			if errors.Is(err, errChatRevoked) {
+				closeChat(conn)
+				return
+			}
+			if reply != nil {
+				select {
+				case replies <- *reply:
+				default:
+				}
+			}
+		}
+	}()
+
+	ping := time.NewTicker(chatPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case message, ok := <-subscription.Messages():
+			conn.SetWriteDeadline(time.Now().Add(chatWriteWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "reconnect"))
+				return
+			}
+			if message.Type == chatRecheck {
+				if app.revokedChat(ctx, event, user, sessionId, message) {
+					closeChat(conn)
+					return
+				}
+				continue
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, message.Data); err != nil {
+				return
+			}
+		case reply := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(chatWriteWait))
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(chatWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/*
+Every connection has one goroutine that reads the frames of the client
+and the handler itself, which is the only one writing to the connection,
+as gorilla/websocket requires. A ping goes out every chatPingPeriod, a
+client that does not answer within chatPongWait is disconnected. When the
+server shuts down, or the connection cannot keep up with the chat, it is
+closed with "going away" and the client reconnects and loads the history.
+*/
+
+func (app *application) revokedChat(ctx context.Context, event *database.Event, user *database.User, sessionId int, message hub.Message) bool {
+	if recheckedUser(message) != user.Id {
+		return false
+	}
+
+	allowed, err := app.inChat(ctx, event.Id, user.Id, sessionId)
+	return err == nil && !allowed
+}
+
+func closeChat(conn *websocket.Conn) {
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "You are no longer part of this chat"),
+		time.Now().Add(chatWriteWait))
+}
+
+// closeChat may be called by the reading goroutine as well, gorilla/websocket
+// allows WriteControl next to the writes of the handler.
+
+func (app *application) receiveChat(ctx context.Context, event *database.Event, user *database.User, sessionId int, frame chatClientFrame) (*chatFrame, error) {
+	if frame.Type != "message" {
+		return &chatFrame{Type: "error", Error: "Unknown frame type " + strconv.Quote(frame.Type)}, nil
+	}
+
+	body := strings.TrimSpace(frame.Body)
+	if body == "" || utf8.RuneCountInString(body) > chatMaxMessage {
+		return &chatFrame{Type: "error", Error: "A message needs between 1 and 2000 characters"}, nil
+	}
+
+	allowed, err := app.inChat(ctx, event.Id, user.Id, sessionId)
+	if err != nil {
+		return &chatFrame{Type: "error", Error: "Failed to send message"}, nil
+	}
+	if !allowed {
+		return nil, errChatRevoked
+	}
+
+	muted, err := app.models.Chat.IsMuted(ctx, event.Id, user.Id)
+	if err != nil {
+		return &chatFrame{Type: "error", Error: "Failed to send message"}, nil
+	}
+	if muted {
+		return &chatFrame{Type: "error", Error: "You are muted in this chat"}, nil
+	}
+
+	message := &database.ChatMessage{EventId: event.Id, UserId: user.Id, Name: user.Name, Body: body}
+	if err := app.models.Chat.InsertMessage(ctx, message); err != nil {
+		return &chatFrame{Type: "error", Error: "Failed to send message"}, nil
+	}
+
+	app.publishChat(event.Id, chatFrame{Type: "message", Message: message})
+	return nil, nil
+}
+
+// receiveChat handles a frame of a client. The message is stored before it
+// is sent to the others, the sender gets it back like everybody else. A
+// refused frame is answered with an error frame to the sender only. If the
+// user is no longer allowed in the chat, errChatRevoked tells the caller to
+// close the connection.
+
+// GetChatMessages returns the chat history of an event
+//
+//	@Summary		Returns the chat history of an event
+//	@Description	Returns a page of chat messages, newest first. Pass nextCursor as cursor to get older messages.
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Event ID"
+//	@Param			limit	query		int		false	"Page size (1-100, default 50)"
+//	@Param			cursor	query		string	false	"Cursor returned as nextCursor by the previous page"
+//	@Success		200		{object}	listChatMessagesResponse
+//	@Router			/api/v1/events/{id}/chat/messages [get]
+//	@Security		BearerAuth
+func (app *application) getChatMessages(c *gin.Context) {
+	event, _, ok := app.chatAccess(c)
+	if !ok {
+		return
+	}
+
+	var request listChatMessagesRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = 50
+	}
+
+	messages, metadata, err := app.models.Chat.GetMessages(c.Request.Context(), event.Id, request.Limit, request.Cursor)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, listChatMessagesResponse{Messages: messages, Metadata: metadata})
+}
+
+// DeleteChatMessage removes a chat message
+//
+//	@Summary		Removes a chat message
+//	@Description	Users can remove their own messages, the owner, co-hosts, moderators and admins any message. Everybody in the chat is told to remove it.
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	int	true	"Event ID"
+//	@Param			messageId	path	int	true	"Message ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/chat/messages/{messageId} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteChatMessage(c *gin.Context) {
+	messageId, err := strconv.Atoi(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message id"})
+		return
+	}
+
+	event, moderator, ok := app.chatAccess(c)
+	if !ok {
+		return
+	}
+
+	message, err := app.models.Chat.GetMessage(c.Request.Context(), event.Id, messageId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive message"})
+		return
+	}
+	if message == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	if message.UserId != user.Id && !moderator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only remove your own messages"})
+		return
+	}
+
+	deleted, err := app.models.Chat.DeleteMessage(c.Request.Context(), event.Id, messageId, user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
+		return
+	}
+	if deleted {
+		app.publishChat(event.Id, chatFrame{Type: "deleted", Id: messageId})
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetChatMutes returns the muted users of a chat
+//
+//	@Summary		Returns the muted users of a chat
+//	@Description	Returns the users that cannot write in the chat right now. Only the owner, co-hosts, moderators and admins can see them.
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	[]database.ChatMute
+//	@Router			/api/v1/events/{id}/chat/mutes [get]
+//	@Security		BearerAuth
+func (app *application) getChatMutes(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionModerateChat)
+	if !ok {
+		return
+	}
+
+	mutes, err := app.models.Chat.GetMutes(c.Request.Context(), event.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive mutes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mutes)
+}
+
+// MuteChatUser mutes a user in a chat
+//
+//	@Summary		Mutes a user in a chat
+//	@Description	The user can still read the chat but not write. Without minutes the mute lasts until it is lifted, muting a muted user replaces the mute. The owner of the event and everybody who may moderate its chat, co-hosts, moderators and admins, cannot be muted.
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Event ID"
+//	@Param			mute	body		muteRequest	true	"User to mute and for how long"
+//	@Success		201		{object}	database.ChatMute
+//	@Router			/api/v1/events/{id}/chat/mutes [post]
+//	@Security		BearerAuth
+func (app *application) muteChatUser(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionModerateChat)
+	if !ok {
+		return
+	}
+
+	var request muteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	if request.UserId == event.OwnerId || request.UserId == user.Id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner of the event and you yourself cannot be muted"})
+		return
+	}
+
+	target, err := app.models.Users.Get(c.Request.Context(), request.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive user"})
+		return
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	member, err := app.models.Members.GetRole(c.Request.Context(), seriesIdOf(event), target.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive member"})
+		return
+	}
+	if app.can(target, member, actionModerateChat, event) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Co-hosts, moderators and admins cannot be muted"})
+		return
+	}
+
+	mute := &database.ChatMute{EventId: event.Id, UserId: target.Id, Name: target.Name, MutedBy: &user.Id}
+	if request.Minutes > 0 {
+		until := time.Now().Add(time.Duration(request.Minutes) * time.Minute)
+		mute.Until = &until
+	}
+
+	if err := app.models.Chat.Mute(c.Request.Context(), mute); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mute user"})
+		return
+	}
+
+	app.publishChat(event.Id, chatFrame{Type: "muted", UserId: target.Id, Until: mute.Until})
+
+	c.JSON(http.StatusCreated, mute)
+}
+
+// UnmuteChatUser lifts the mute of a user
+//
+//	@Summary		Lifts the mute of a user
+//	@Description	The user can write in the chat again
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int	true	"Event ID"
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/chat/mutes/{userId} [delete]
+//	@Security		BearerAuth
+func (app *application) unmuteChatUser(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	event, ok := app.authorizeEvent(c, actionModerateChat)
+	if !ok {
+		return
+	}
+
+	unmuted, err := app.models.Chat.Unmute(c.Request.Context(), event.Id, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unmute user"})
+		return
+	}
+	if !unmuted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The user is not muted"})
+		return
+	}
+
+	app.publishChat(event.Id, chatFrame{Type: "unmuted", UserId: userId})
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func (s *testServer) chatURL(eventId int) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + fmt.Sprintf("/api/v1/events/%d/chat", eventId)
+}
+
+func (s *testServer) joinChat(t *testing.T, eventId int, token string) *websocket.Conn {
+	t.Helper()
+
+	url := s.chatURL(eventId)
+	header := http.Header{"Authorization": {"Bearer " + token}}
+
+	conn, response, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("joining the chat: %v", err)
+	}
+	response.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func expectChatClosed(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err == nil {
+			if strings.Contains(string(data), `"type":"message"`) {
+				t.Errorf("got %s after the access was revoked", data)
+			}
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Errorf("reading the chat = %v, want a policy violation close", err)
+		}
+		return
+	}
+}
+
+func TestChatTakesTheTokenAsSubprotocol(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, token := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, token, "")
+
+		dialer := websocket.Dialer{Subprotocols: []string{chatProtocol, tokenProtocolPrefix + token}}
+		conn, response, err := dialer.Dial(server.chatURL(eventId), nil)
+		if err != nil {
+			t.Fatalf("joining the chat: %v", err)
+		}
+		response.Body.Close()
+		defer conn.Close()
+
+		if conn.Subprotocol() != chatProtocol {
+			t.Errorf("subprotocol = %q, want %q", conn.Subprotocol(), chatProtocol)
+		}
+
+		_, response, err = websocket.DefaultDialer.Dial(server.chatURL(eventId)+"?access_token="+token, nil)
+		if err == nil || response == nil || response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("joining with the token in the URL = %v, want 401", err)
+		}
+	})
+}
+
+func TestAccessTokenIsNotLogged(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		var log bytes.Buffer
+		gin.DefaultWriter = &log
+		handler := server.app.routes()
+		gin.DefaultWriter = io.Discard
+
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/events/1/chat?access_token=secret-token", nil)
+		request.Header.Set("Connection", "Upgrade")
+		request.Header.Set("Upgrade", "websocket")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		if !strings.Contains(log.String(), "/api/v1/events/1/chat") || strings.Contains(log.String(), "secret-token") {
+			t.Errorf("log = %q, want the request without the token", log.String())
+		}
+	})
+}
+
+func TestChatClosesAfterCancelledRSVP(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		_, token := server.newUser(t, "Attendee")
+
+		path := fmt.Sprintf("/api/v1/events/%d/rsvp", eventId)
+		if response := server.do(t, http.MethodPost, path, token, `{"status": "going"}`); response.StatusCode != http.StatusCreated {
+			t.Fatalf("RSVP = %s", response.Status)
+		}
+
+		conn := server.joinChat(t, eventId, token)
+
+		if response := server.do(t, http.MethodDelete, path, token, ""); response.StatusCode != http.StatusNoContent {
+			t.Fatalf("cancelling the RSVP = %s", response.Status)
+		}
+
+		expectChatClosed(t, conn)
+	})
+}
+
+func TestChatChecksAccessForEveryMessage(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		attendee, token := server.newUser(t, "Attendee")
+
+		path := fmt.Sprintf("/api/v1/events/%d/rsvp", eventId)
+		if response := server.do(t, http.MethodPost, path, token, `{"status": "going"}`); response.StatusCode != http.StatusCreated {
+			t.Fatalf("RSVP = %s", response.Status)
+		}
+
+		conn := server.joinChat(t, eventId, token)
+
+		ctx := context.Background()
+		if _, err := server.app.models.Attendees.Delete(ctx, attendee.Id, eventId, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := conn.WriteJSON(chatClientFrame{Type: "message", Body: "Still here?"}); err != nil {
+			t.Fatal(err)
+		}
+		expectChatClosed(t, conn)
+
+		messages, _, err := server.app.models.Chat.GetMessages(ctx, eventId, 10, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != 0 {
+			t.Errorf("messages = %v, want none", messages)
+		}
+	})
+}
+
+// Removing the attendee through the model does not tell the chat, like a
+// change made through another API server, the message is what has to be
+// refused.
+
+func TestChatMuteRules(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		owner, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		coHost, coHostToken := server.newUser(t, "CoHost")
+		server.addMember(t, ownerToken, eventId, coHost, database.MemberCoHost)
+		viewer, _ := server.newUser(t, "Viewer")
+		server.addMember(t, ownerToken, eventId, viewer, database.MemberViewer)
+		attendee, _ := server.newUser(t, "Attendee")
+
+		ctx := context.Background()
+		moderator, _ := server.newUser(t, "Moderator")
+		if err := server.app.models.Users.SetRole(ctx, moderator.Id, database.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+		admin, _ := server.newUser(t, "Admin")
+		if err := server.app.models.Users.SetRole(ctx, admin.Id, database.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name   string
+			token  string
+			userId int
+			status int
+		}{
+			{"the owner mutes a co-host", ownerToken, coHost.Id, http.StatusBadRequest},
+			{"the owner mutes a moderator", ownerToken, moderator.Id, http.StatusBadRequest},
+			{"the owner mutes an admin", ownerToken, admin.Id, http.StatusBadRequest},
+			{"a co-host mutes the owner", coHostToken, owner.Id, http.StatusBadRequest},
+			{"a co-host mutes a viewer", coHostToken, viewer.Id, http.StatusCreated},
+			{"the owner mutes an attendee", ownerToken, attendee.Id, http.StatusCreated},
+		}
+
+		path := fmt.Sprintf("/api/v1/events/%d/chat/mutes", eventId)
+		for _, tc := range tests {
+			response := server.do(t, http.MethodPost, path, tc.token, fmt.Sprintf(`{"userId": %d}`, tc.userId))
+			if response.StatusCode != tc.status {
+				t.Errorf("%s = %s, want %d", tc.name, response.Status, tc.status)
+			}
+		}
+	})
+}
+
+func TestChatAndStreamCloseWhenTheSessionEnds(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		user, token := server.newUser(t, "Attendee")
+		other, err := server.app.issueTokens(context.Background(), user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := fmt.Sprintf("/api/v1/events/%d/rsvp", eventId)
+		if response := server.do(t, http.MethodPost, path, token, `{"status": "going"}`); response.StatusCode != http.StatusCreated {
+			t.Fatalf("RSVP = %s", response.Status)
+		}
+
+		conn := server.joinChat(t, eventId, token)
+		stream := server.openStream(t, eventId, token)
+		otherConn := server.joinChat(t, eventId, other.Token)
+
+		if response := server.do(t, http.MethodPost, "/api/v1/auth/logout", token, ""); response.StatusCode != http.StatusNoContent {
+			t.Fatalf("logging out = %s", response.Status)
+		}
+		expectChatClosed(t, conn)
+		expectStream(t, stream, "revoked")
+
+		if err := otherConn.WriteJSON(chatClientFrame{Type: "message", Body: "Still here"}); err != nil {
+			t.Fatal(err)
+		}
+		var frame chatFrame
+		otherConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := otherConn.ReadJSON(&frame); err != nil || frame.Type != "message" {
+			t.Fatalf("the chat of the other session = %+v, %v, want the message", frame, err)
+		}
+
+		reset := fmt.Sprintf(`{"token": %q, "password": "new-password"}`, server.passwordResetToken(t, user.Id))
+		if response := server.do(t, http.MethodPost, "/api/v1/auth/reset-password", "", reset); response.StatusCode != http.StatusNoContent {
+			t.Fatalf("resetting the password = %s", response.Status)
+		}
+		expectChatClosed(t, otherConn)
+	})
+}
+
+// Logging out ends the chat and the stream of that session only, resetting
+// the password ends the ones of every session.
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func (app *application) runCommand(args []string) error {
+	switch args[0] {
+	case "import":
+		return app.importCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, available commands: import", args[0])
+	}
+}
+
+/*
+runCommand is used when the api binary is started with arguments, it runs
+a single maintenance command against the configured database instead of
+starting the server.
+*/
+
+func (app *application) importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	owner := flags.String("owner", "", "email of the user that will own the events (required)")
+	format := flags.String("format", "", "csv or ics, detected from the file extension by default")
+	dryRun := flags.Bool("dry-run", false, "only validate the file")
+	publish := flags.Bool("publish", false, "publish the events instead of importing them as drafts")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: api import -owner email [-format csv|ics] [-dry-run] [-publish] file")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *owner == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("an owner and exactly one file are required")
+	}
+
+	ctx := context.Background()
+
+	user, err := app.models.Users.GetByEmail(ctx, *owner)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("no user with email %s", *owner)
+	}
+
+	filename := flags.Arg(0)
+
+	kind, err := importFormat(*format, filename, "")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := readImport(file, kind)
+	if err != nil {
+		return err
+	}
+
+	response, err := app.applyImport(ctx, rows, user.Id, *publish, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if len(response.Errors) > 0 {
+		for _, rowError := range response.Errors {
+			fmt.Fprintf(os.Stderr, "row %d: %s\n", rowError.Row, rowError.Error)
+		}
+		return fmt.Errorf("%d of %d rows are invalid, nothing was imported", len(response.Errors), len(rows))
+	}
+
+	if *dryRun {
+		fmt.Printf("%d events are valid, nothing was imported (dry run)\n", len(response.Events))
+		return nil
+	}
+
+	fmt.Printf("Imported %d events for %s\n", response.Imported, user.Email)
+	return nil
+}
+
+/*
+The import command takes the same files as POST /events/import, without
+the size limit of the endpoint:
+
+	api import -owner organizer@example.com -publish events.csv
+	api import -owner organizer@example.com -dry-run calendar.ics
+
+Row errors are printed to stderr and the command exits with status 1.
+*/
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type listCommentsRequest struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string `form:"cursor"`
+}
+
+type listCommentsResponse struct {
+	Comments []*database.Comment `json:"comments"`
+	Metadata database.Metadata   `json:"metadata"`
+}
+
+type createCommentRequest struct {
+	Body     string `json:"body" binding:"required,max=5000"`
+	ParentId *int   `json:"parentId" binding:"omitempty,min=1"`
+}
+
+type updateCommentRequest struct {
+	Body string `json:"body" binding:"required,max=5000"`
+}
+
+func (app *application) commentEvent(c *gin.Context) (*database.Event, string, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return nil, "", false
+	}
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return nil, "", false
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, "", false
+	}
+
+	member, _, ok := app.viewEvent(c, event)
+	if !ok {
+		return nil, "", false
+	}
+
+	return event, member, true
+}
+
+// commentEvent loads the event of a comment route. Everybody who may see
+// the event may read and write its comments.
+
+func (app *application) loadComment(c *gin.Context, event *database.Event) (*database.Comment, bool) {
+	id, err := strconv.Atoi(c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment id"})
+		return nil, false
+	}
+
+	comment, err := app.models.Comments.Get(c.Request.Context(), event.Id, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive comment"})
+		return nil, false
+	}
+	if comment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// GetComments returns the comments of an event
+//
+//	@Summary		Returns the comments of an event
+//	@Description	Returns a page of threads, oldest first, each with its replies. Pass nextCursor as cursor to get the next page. A deleted comment that has replies is returned with deleted set and without its body.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Event ID"
+//	@Param			limit	query		int		false	"Page size (1-100, default 20)"
+//	@Param			cursor	query		string	false	"Cursor returned as nextCursor by the previous page"
+//	@Param			invite	query		string	false	"Invite token of a private event"
+//	@Success		200		{object}	listCommentsResponse
+//	@Router			/api/v1/events/{id}/comments [get]
+func (app *application) getComments(c *gin.Context) {
+	event, _, ok := app.commentEvent(c)
+	if !ok {
+		return
+	}
+
+	var request listCommentsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = 20
+	}
+
+	comments, metadata, err := app.models.Comments.GetByEvent(c.Request.Context(), event.Id, request.Limit, request.Cursor)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, listCommentsResponse{Comments: comments, Metadata: metadata})
+}
+
+// CreateComment comments on an event
+//
+//	@Summary		Comments on an event
+//	@Description	Writes a comment, or with parentId a reply to a comment. Threads are one level deep, a reply to a reply joins the thread of the comment it answers.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Event ID"
+//	@Param			invite	query		string					false	"Invite token of a private event"
+//	@Param			comment	body		createCommentRequest	true	"Comment"
+//	@Success		201		{object}	database.Comment
+//	@Router			/api/v1/events/{id}/comments [post]
+//	@Security		BearerAuth
+func (app *application) createComment(c *gin.Context) {
+	event, _, ok := app.commentEvent(c)
+	if !ok {
+		return
+	}
+
+	var request createCommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Body = strings.TrimSpace(request.Body)
+	if request.Body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A comment cannot be empty"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	comment := &database.Comment{EventId: event.Id, UserId: user.Id, Name: user.Name, Body: request.Body}
+
+	if request.ParentId != nil {
+		parent, err := app.models.Comments.Get(c.Request.Context(), event.Id, *request.ParentId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive comment"})
+			return
+		}
+		if parent == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+			return
+		}
+
+		comment.ParentId = &parent.Id
+		if parent.ParentId != nil {
+			comment.ParentId = parent.ParentId
+		}
+	}
+
+	if err := app.models.Comments.Insert(c.Request.Context(), comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateComment edits a comment
+//
+//	@Summary		Edits a comment
+//	@Description	Only the author can edit a comment
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Event ID"
+//	@Param			commentId	path		int						true	"Comment ID"
+//	@Param			invite		query		string					false	"Invite token of a private event"
+//	@Param			comment		body		updateCommentRequest	true	"New text of the comment"
+//	@Success		200			{object}	database.Comment
+//	@Router			/api/v1/events/{id}/comments/{commentId} [patch]
+//	@Security		BearerAuth
+func (app *application) updateComment(c *gin.Context) {
+	event, _, ok := app.commentEvent(c)
+	if !ok {
+		return
+	}
+
+	comment, ok := app.loadComment(c, event)
+	if !ok {
+		return
+	}
+
+	if comment.UserId != app.GetUserFromContext(c).Id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own comments"})
+		return
+	}
+
+	var request updateCommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Body = strings.TrimSpace(request.Body)
+	if request.Body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A comment cannot be empty"})
+		return
+	}
+
+	comment.Body = request.Body
+	updated, err := app.models.Comments.Update(c.Request.Context(), comment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment deletes a comment
+//
+//	@Summary		Deletes a comment
+//	@Description	Users can delete their own comments, the owner, co-hosts, moderators and admins any comment of the event. The replies of a deleted comment stay.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	int		true	"Event ID"
+//	@Param			commentId	path	int		true	"Comment ID"
+//	@Param			invite		query	string	false	"Invite token of a private event"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/comments/{commentId} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteComment(c *gin.Context) {
+	event, member, ok := app.commentEvent(c)
+	if !ok {
+		return
+	}
+
+	comment, ok := app.loadComment(c, event)
+	if !ok {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	if comment.UserId != user.Id && !app.can(user, member, actionModerateComments, event) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to " + string(actionModerateComments)})
+		return
+	}
+
+	if _, err := app.models.Comments.Delete(c.Request.Context(), event.Id, comment.Id, user.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
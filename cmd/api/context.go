@@ -22,3 +22,10 @@ func (app *application) GetUserFromContext(c *gin.Context) *database.User {
 
 // Here we are getting the user from the context and returning it.
 // If the user is not found we return an empty user.
+
+func (app *application) GetSessionIdFromContext(c *gin.Context) int {
+	return c.GetInt("sessionId")
+}
+
+// The session id is set by the AuthMiddleware next to the user,
+// it is 0 on routes that are not behind the middleware.
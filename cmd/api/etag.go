@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func eventETag(event *database.Event) string {
+	return fmt.Sprintf(`"%d"`, event.Version)
+}
+
+func eventResponseETag(event *database.Event, comments int) string {
+	return fmt.Sprintf(`W/"%d-%d"`, event.Version, comments)
+}
+
+/*
+eventETag is the entity tag of an event, its quoted version. It changes
+with every update, so it identifies the state a client has seen, and it is
+what If-Match is compared with. A response with an event also carries its
+commentCount, which changes without a new version, so every response with
+an event carries eventResponseETag instead: a weak tag of the version and
+the number of comments. A copy cached with If-None-Match is then no longer
+current once somebody comments.
+*/
+
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+matchesETag checks an If-None-Match header, which can list several tags or
+* for any. It is the weak comparison of RFC 9110, a W/ prefix on either
+side is ignored.
+*/
+
+func matchesVersion(header string, version int) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		tag, err := strconv.Unquote(strings.TrimPrefix(candidate, "W/"))
+		if err != nil {
+			continue
+		}
+		tagVersion, comments, weak := strings.Cut(tag, "-")
+		if weak {
+			if _, err := strconv.Atoi(comments); err != nil {
+				continue
+			}
+		}
+		if tagVersion == strconv.Itoa(version) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+matchesVersion checks an If-Match header against the version of the event
+alone. It takes the tag of eventETag as well as the weak one of a response,
+the comments in it are ignored, so a comment written in the meantime does
+not make a change fail.
+*/
+
+func (app *application) checkIfMatch(c *gin.Context, event *database.Event) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || matchesVersion(header, event.Version) {
+		return true
+	}
+
+	app.preconditionFailed(c, event)
+	return false
+}
+
+func (app *application) preconditionFailed(c *gin.Context, event *database.Event) {
+	if event != nil {
+		c.Header("ETag", eventETag(event))
+	}
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "The event was changed in the meantime, fetch it again and retry"})
+}
+
+/*
+checkIfMatch enforces the If-Match precondition of PUT and PATCH: the
+change is only applied to the version the client has seen. Without the
+header the change is applied to whatever version is current, like before.
+The 412 response carries the current ETag when we know it. A change that
+slips in between reading and writing the event makes the update fail with
+ErrEditConflict, which is answered with 412 as well.
+*/
@@ -1,69 +1,213 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/schlafer/EventApp/internal/database"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	_ "github.com/joho/godotenv/autoload"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// GetEvents returns all events
+type listEventsRequest struct {
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor   string `form:"cursor"`
+	From     string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To       string `form:"to" binding:"omitempty,datetime=2006-01-02"`
+	Location string `form:"location"`
+	OwnerId  int    `form:"owner" binding:"omitempty,min=1"`
+	Sort     string `form:"sort" binding:"omitempty,oneof=date -date name -name"`
+	Status   string `form:"status" binding:"omitempty,oneof=draft published cancelled completed"`
+}
+
+type listEventsResponse struct {
+	Events   []*database.Event `json:"events"`
+	Metadata database.Metadata `json:"metadata"`
+}
+
+type eventResponse struct {
+	*database.Event
+	CommentCount int `json:"commentCount"`
+}
+
+// GetEvents returns a page of events
 //
-//	@Summary		Returns all events
-//	@Description	Returns all events
+//	@Summary		Returns a page of events
+//	@Description	Returns a page of events, optionally filtered by date range, location, owner and status. Drafts, unlisted and private events are only listed for their owner.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Success		200		{object}	[]database.Event
+//	@Param			limit		query		int		false	"Page size (1-100, default 20)"
+//	@Param			cursor		query		string	false	"Cursor returned as nextCursor by the previous page"
+//	@Param			from		query		string	false	"Earliest start date (YYYY-MM-DD, UTC)"
+//	@Param			to			query		string	false	"Latest start date (YYYY-MM-DD, UTC)"
+//	@Param			location	query		string	false	"Location contains"
+//	@Param			owner		query		int		false	"Owner ID"
+//	@Param			sort		query		string	false	"Sort order"	Enums(date, -date, name, -name)
+//	@Param			status		query		string	false	"Status"		Enums(draft, published, cancelled, completed)
+//	@Success		200			{object}	listEventsResponse
 //	@Router			/api/v1/events [get]
 func (app *application) getAllEvents(c *gin.Context) {
-	events, err := app.models.Events.GetAll()
+	var request listEventsRequest
+
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.Limit == 0 {
+		request.Limit = 20
+	}
+
+	user := app.GetUserFromContext(c)
+
+	filters := database.EventFilters{
+		Location:   request.Location,
+		OwnerId:    request.OwnerId,
+		Status:     request.Status,
+		ViewerId:   user.Id,
+		ShowHidden: hasRole(user, database.RoleModerator, database.RoleAdmin),
+		Sort:       request.Sort,
+		Limit:      request.Limit,
+		Cursor:     request.Cursor,
+	}
+
+	if request.From != "" {
+		filters.From, _ = time.Parse(time.DateOnly, request.From)
+	}
+	if request.To != "" {
+		to, _ := time.Parse(time.DateOnly, request.To)
+		filters.To = to.AddDate(0, 0, 1)
+	}
+
+	events, metadata, err := app.models.Events.GetAll(c.Request.Context(), filters)
+
+	if errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive events"})
+		return
 	}
 
-	c.JSON(http.StatusOK, events)
+	c.JSON(http.StatusOK, listEventsResponse{Events: events, Metadata: metadata})
+}
+
+type searchEventsRequest struct {
+	Q     string `form:"q" binding:"required,min=2"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// SearchEvents searches events
+//
+//	@Summary		Searches events
+//	@Description	Full-text search over event name, description and location, ranked by relevance
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query		string	true	"Search query"
+//	@Param			limit	query		int		false	"Maximum number of results (1-100, default 20)"
+//	@Success		200		{object}	[]database.EventSearchResult
+//	@Router			/api/v1/events/search [get]
+func (app *application) searchEvents(c *gin.Context) {
+	var request searchEventsRequest
+
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.Limit == 0 {
+		request.Limit = 20
+	}
+
+	results, err := app.models.Events.Search(c.Request.Context(), request.Q, request.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
 }
 
 // GetEvent returns a single event
 //
 //	@Summary		Returns a single event
-//	@Description	Returns a single event
+//	@Description	Returns a single event. A private event is only returned to its owner, its attendees and with a valid invite token. commentCount is the number of comments, replies included.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Event ID"
-//	@Success		200	{object}	database.Event
+//	@Param			id				path		int		true	"Event ID"
+//	@Param			invite			query		string	false	"Invite token of a private event"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy, answered with 304 if it is still current"
+//	@Success		200				{object}	eventResponse
+//	@Header			200				{string}	ETag	"Weak tag of the version and the number of comments, send it in If-None-Match, or in If-Match when updating"
 //	@Router			/api/v1/events/{id} [get]
 func (app *application) getEvent(c *gin.Context) {
+	if idParam, ok := strings.CutSuffix(c.Param("id"), ".ics"); ok {
+		app.exportEvent(c, idParam)
+		return
+	}
+
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
 	}
 
-	event, err := app.models.Events.Get(id)
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
 
 	if event == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
 	}
+	if !app.requireView(c, event) {
+		return
+	}
+
+	app.writeEvent(c, http.StatusOK, event)
+}
 
+func (app *application) writeEvent(c *gin.Context, status int, event *database.Event) {
+	comments, err := app.models.Comments.Count(c.Request.Context(), event.Id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive comments"})
+		return
+	}
+
+	etag := eventResponseETag(event, comments)
+	c.Header("ETag", etag)
+	if header := c.GetHeader("If-None-Match"); c.Request.Method == http.MethodGet && header != "" && matchesETag(header, etag) {
+		c.Status(http.StatusNotModified)
+		return
 	}
 
-	c.JSON(http.StatusOK, event)
+	c.JSON(status, eventResponse{Event: event, CommentCount: comments})
 }
 
+// writeEvent answers with the event, its number of comments and its ETag,
+// the same way for GET and for every change to a single event. A GET whose
+// If-None-Match names the current tag gets 304 without a body.
+
 // CreateEvent creates a new event
 //
 //	@Summary		Creates a new event
-//	@Description	Creates a new event
+//	@Description	Creates a new event. It is a draft that only the owner can see unless status is published, drafts are published with POST /events/{id}/publish.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
@@ -79,72 +223,188 @@ func (app *application) createEvent(c *gin.Context) {
 		return
 	}
 
+	if !app.validateRecurrence(c, &event) {
+		return
+	}
+
+	switch event.Status {
+	case "":
+		event.Status = database.EventDraft
+	case database.EventDraft, database.EventPublished:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A new event is either a draft or published"})
+		return
+	}
+
 	user := app.GetUserFromContext(c)
 	event.OwnerId = user.Id
+	event.SeriesId = nil
+	event.OccurrenceDate = ""
+	event.CancellationReason = ""
+	event.CancelledAt = nil
+
+	notifications := app.notifications()
+	notifications.add(database.WebhookEventCreated, &event, nil)
 
-	err := app.models.Events.Insert(&event)
+	err := app.models.Events.Insert(c.Request.Context(), &event, notifications.enqueue)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
 		return
 	}
 
+	notifications.send()
+
 	c.JSON(http.StatusCreated, event)
 }
 
 // UpdateEvent updates an existing event
 //
 //	@Summary		Updates an existing event
-//	@Description	Updates an existing event
+//	@Description	Replaces an existing event. Send the ETag from GET in If-Match to make sure nobody changed the event in the meantime, only its version is compared and a mismatch is answered with 412.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Event ID"
-//	@Param			event	body		database.Event	true	"Event"
-//	@Success		200	{object}	database.Event
+//	@Param			id			path		int				true	"Event ID"
+//	@Param			If-Match	header		string			false	"ETag of the version the change is based on"
+//	@Param			event		body		database.Event	true	"Event"
+//	@Success		200			{object}	eventResponse
+//	@Header			200			{string}	ETag	"Weak tag of the new version and the number of comments"
 //	@Router			/api/v1/events/{id} [put]
 //	@Security		BearerAuth
 func (app *application) updateEvent(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+	existingEvent, ok := app.authorizeEvent(c, actionUpdateEvent)
+	if !ok {
 		return
 	}
 
-	user := app.GetUserFromContext(c)
-	existingEvent, err := app.models.Events.Get(id)
+	if !app.checkIfMatch(c, existingEvent) {
+		return
+	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+	updatedEvent := &database.Event{}
+
+	if err := c.ShouldBindJSON(updatedEvent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if existingEvent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	app.saveEvent(c, existingEvent, updatedEvent)
+}
+
+// PatchEvent partially updates an existing event
+//
+//	@Summary		Partially updates an existing event
+//	@Description	Applies a JSON Merge Patch (RFC 7396): only the fields in the body change, null removes optional fields like capacity. The result is validated like a full update. Send the ETag from GET in If-Match, only its version is compared and a mismatch is answered with 412.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int				true	"Event ID"
+//	@Param			If-Match	header		string			false	"ETag of the version the change is based on"
+//	@Param			patch		body		database.Event	true	"The fields to change"
+//	@Success		200			{object}	eventResponse
+//	@Header			200			{string}	ETag	"Weak tag of the new version and the number of comments"
+//	@Router			/api/v1/events/{id} [patch]
+//	@Security		BearerAuth
+func (app *application) patchEvent(c *gin.Context) {
+	existingEvent, ok := app.authorizeEvent(c, actionUpdateEvent)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(c, existingEvent) {
+		return
+	}
+
+	var patch interface{}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The patch must be a JSON object"})
+		return
+	}
+
+	var document interface{}
+	current, err := json.Marshal(existingEvent)
+	if err == nil {
+		err = json.Unmarshal(current, &document)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
 		return
 	}
 
-	if existingEvent.OwnerId != user.Id {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to update this event"})
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
 		return
 	}
 
 	updatedEvent := &database.Event{}
+	if err := json.Unmarshal(merged, updatedEvent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err := c.ShouldBindJSON(updatedEvent); err != nil {
+	if err := binding.Validator.ValidateStruct(updatedEvent); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	updatedEvent.Id = id
+	app.saveEvent(c, existingEvent, updatedEvent)
+}
+
+/*
+The patch is applied to the JSON form of the stored event, so a field
+behaves exactly as in the body of a PUT. Fields the client cannot change,
+like id, ownerId or version, are taken from the stored event by saveEvent
+whatever the patch says.
+*/
+
+func (app *application) saveEvent(c *gin.Context, existingEvent, updatedEvent *database.Event) {
+	if !app.validateRecurrence(c, updatedEvent) {
+		return
+	}
+
+	if existingEvent.SeriesId != nil {
+		if updatedEvent.Rrule != "" || len(updatedEvent.ExDates) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A single occurrence cannot recur"})
+			return
+		}
+		updatedEvent.Modified = true
+	}
 
-	if err := app.models.Events.Update(updatedEvent); err != nil {
+	updatedEvent.Id = existingEvent.Id
+	updatedEvent.OwnerId = existingEvent.OwnerId
+	updatedEvent.SeriesId = existingEvent.SeriesId
+	updatedEvent.OccurrenceDate = existingEvent.OccurrenceDate
+	updatedEvent.Version = existingEvent.Version
+	updatedEvent.Status = existingEvent.Status
+	updatedEvent.CancellationReason = existingEvent.CancellationReason
+	updatedEvent.CancelledAt = existingEvent.CancelledAt
+
+	notifications := app.notifications()
+	notifications.add(database.WebhookEventUpdated, updatedEvent, nil)
+
+	err := app.models.Events.Update(c.Request.Context(), updatedEvent, notifications.enqueue)
+	if errors.Is(err, database.ErrEditConflict) {
+		app.preconditionFailed(c, nil)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
 		return
 	}
 
-	c.JSON(http.StatusOK, updatedEvent)
+	notifications.send()
+
+	app.writeEvent(c, http.StatusOK, updatedEvent)
 }
 
+// saveEvent is the part of updateEvent and patchEvent that stores the new
+// version of the event and writes the response.
+
 // DeleteEvent deletes an existing event
 //
 //	@Summary		Deletes an existing event
@@ -157,32 +417,20 @@ func (app *application) updateEvent(c *gin.Context) {
 //	@Router			/api/v1/events/{id} [delete]
 //	@Security		BearerAuth
 func (app *application) deleteEvent(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Error": "Invalid event ID"})
-	}
-
-	user := app.GetUserFromContext(c)
-	existingEvent, err := app.models.Events.Get(id)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"Error": "Failed to retreive event"})
+	existingEvent, ok := app.authorizeEvent(c, actionDeleteEvent)
+	if !ok {
 		return
 	}
 
-	if existingEvent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"Error": "Event not found"})
-		return
-	}
+	notifications := app.notifications()
+	notifications.add(database.WebhookEventDeleted, existingEvent, nil)
 
-	if existingEvent.OwnerId != user.Id {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to delete this event"})
+	if err := app.models.Events.Delete(c.Request.Context(), existingEvent.Id, notifications.enqueue); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
 		return
 	}
 
-	if err := app.models.Events.Delete(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
-	}
+	notifications.send()
 
 	c.JSON(http.StatusNoContent, nil)
 }
@@ -194,8 +442,9 @@ func (app *application) deleteEvent(c *gin.Context) {
 //	@Tags			attendees
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Event ID"
-//	@Success		200	{object}	[]database.User
+//	@Param			id		path		int		true	"Event ID"
+//	@Param			invite	query		string	false	"Invite token of a private event"
+//	@Success		200		{object}	[]database.User
 //	@Router			/api/v1/events/{id}/attendees [get]
 func (app *application) getAttendeesForEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -204,7 +453,20 @@ func (app *application) getAttendeesForEvent(c *gin.Context) {
 		return
 	}
 
-	users, err := app.models.Attendees.GetAttendeesByEvent(id)
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.requireView(c, event) {
+		return
+	}
+
+	users, err := app.models.Attendees.GetAttendeesByEvent(c.Request.Context(), event.Id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to to retreive attendees for events"})
 		return
@@ -213,40 +475,80 @@ func (app *application) getAttendeesForEvent(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
+// GetWaitlistForEvent returns the waitlist of a given event
+//
+//	@Summary		Returns the waitlist of a given event
+//	@Description	Returns the users waiting for a spot at a full event, ordered by position
+//	@Tags			attendees
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Event ID"
+//	@Param			invite	query		string	false	"Invite token of a private event"
+//	@Success		200		{object}	[]database.WaitlistEntry
+//	@Router			/api/v1/events/{id}/waitlist [get]
+func (app *application) getWaitlistForEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.requireView(c, event) {
+		return
+	}
+
+	entries, err := app.models.Waitlist.GetByEvent(c.Request.Context(), event.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive waitlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
 // AddAttendeeToEvent adds an attendee to an event
 // @Summary		Adds an attendee to an event
-// @Description	Adds an attendee to an event
+// @Description	Adds an attendee to an event, or to its waitlist if the event is full
 // @Tags			attendees
 // @Accept			json
 // @Produce		json
 // @Param			id	path		int	true	"Event ID"
 // @Param			userId	path		int	true	"User ID"
 // @Success		201		{object}	database.Attendee
+// @Success		202		{object}	database.WaitlistEntry	"The event is full, the user was put on the waitlist"
 // @Router			/api/v1/events/{id}/attendees/{userId} [post]
 // @Security		BearerAuth
 func (app *application) addAttendeeToEvent(c *gin.Context) {
-	eventId, err := strconv.Atoi(c.Param("id"))
+	userId, err := strconv.Atoi(c.Param("userId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event Id"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user Id"})
 		return
 	}
 
-	userId, err := strconv.Atoi(c.Param("userId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user Id"})
+	event, ok := app.authorizeEvent(c, actionManageAttendees)
+	if !ok {
 		return
 	}
 
-	event, err := app.models.Events.Get(eventId)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+	if event.IsRecurring() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attendees are added to a single occurrence of a recurring event"})
 		return
 	}
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+
+	if !app.requireOpen(c, event) {
+		return
 	}
 
-	userToAdd, err := app.models.Users.Get(userId)
+	userToAdd, err := app.models.Users.Get(c.Request.Context(), userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive user"})
 		return
@@ -254,44 +556,47 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 
 	if userToAdd == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-	}
-
-	user := app.GetUserFromContext(c)
-
-	if event.OwnerId != user.Id {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to add an attendee"})
 		return
 	}
 
-	existingAttendee, err := app.models.Attendees.GetByEventAndAttendee(event.Id, userToAdd.Id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive attendee"})
-		return
+	attendee := database.Attendee{
+		EventId: event.Id,
+		UserId:  userToAdd.Id,
 	}
-	if existingAttendee != nil {
+
+	notifications := app.notifications()
+
+	entry, err := app.models.Attendees.InsertOrWaitlist(c.Request.Context(), &attendee, notifications.enqueueRSVPs(event))
+	if errors.Is(err, database.ErrDuplicateAttendee) {
 		c.JSON(http.StatusConflict, gin.H{"error": "Attendee already exists"})
 		return
 	}
 
-	attendee := database.Attendee{
-		EventId: event.Id,
-		UserId:  userToAdd.Id,
+	if errors.Is(err, database.ErrAlreadyWaitlisted) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Attendee is already on the waitlist"})
+		return
 	}
 
-	_, err = app.models.Attendees.Insert(&attendee)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add  attendee"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, attendee)
+	if entry != nil {
+		c.JSON(http.StatusAccepted, entry)
+		return
+	}
+
+	notifications.send()
+
+	c.JSON(http.StatusCreated, app.withTicket(&attendee))
 
 }
 
 // GetEventsByAttendee returns all events for a given attendee
 //
 //	@Summary		Returns all events for a given attendee
-//	@Description	Returns all events for a given attendee
+//	@Description	Returns all events for a given attendee. Other users only see the events they would find in the list of all events: public events that are no longer drafts and the events they own or are a member of.
 //	@Tags			attendees
 //	@Accept			json
 //	@Produce		json
@@ -304,18 +609,39 @@ func (app *application) getEventsByAttendee(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attendee id"})
 		return
 	}
-	events, err := app.models.Attendees.GetEventsByAttendee(id)
+	events, err := app.models.Attendees.GetEventsByAttendee(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
 		return
 	}
 
+	if app.GetUserFromContext(c).Id != id {
+		listed := []*database.Event{}
+		for _, event := range events {
+			ok, err := app.listedTo(c, event)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+				return
+			}
+			if ok {
+				listed = append(listed, event)
+			}
+		}
+		events = listed
+	}
+
 	c.JSON(http.StatusOK, events)
 }
 
+/*
+Like the list of all events, the events of an attendee are filtered with
+listedTo for other users, nobody should learn about an unlisted or private
+event from the profile of somebody who attends it.
+*/
+
 // DeleteAttendeeFromEvent deletes an attendee from an event
 // @Summary		Deletes an attendee from an event
-// @Description	Deletes an attendee from an event
+// @Description	Deletes an attendee from an event and gives the free spot to the first user on the waitlist
 // @Tags			attendees
 // @Accept			json
 // @Produce		json
@@ -325,39 +651,27 @@ func (app *application) getEventsByAttendee(c *gin.Context) {
 // @Router			/api/v1/events/{id}/attendees/{userId} [delete]
 // @Security		BearerAuth
 func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
-		return
-	}
-
 	userId, err := strconv.Atoi(c.Param("userId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
 		return
 	}
 
-	event, err := app.models.Events.Get(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
-		return
-	}
-
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	event, ok := app.authorizeEvent(c, actionManageAttendees)
+	if !ok {
 		return
 	}
 
-	user := app.GetUserFromContext(c)
-	if event.OwnerId != user.Id {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "You are not authorized to delete an attendeeFromEvent"})
-	}
+	notifications := app.notifications()
 
-	err = app.models.Attendees.Delete(userId, id)
+	_, err = app.models.Attendees.Delete(c.Request.Context(), userId, event.Id, notifications.enqueueRSVPs(event))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attendee"})
 		return
 	}
 
+	notifications.send()
+	app.recheckAccess(userId)
+
 	c.JSON(http.StatusNoContent, nil)
 }
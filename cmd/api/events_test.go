@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/schlafer/EventApp/internal/database"
+)
+
+func TestAddAttendeeConcurrently(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, `, "capacity": 1`)
+		attendee, _ := server.newUser(t, "Attendee")
+
+		path := fmt.Sprintf("/api/v1/events/%d/attendees/%d", eventId, attendee.Id)
+		statuses := addConcurrently(t, server, ownerToken, []string{path}, 20)
+
+		if statuses[http.StatusCreated] != 1 || statuses[http.StatusConflict] != 19 || len(statuses) != 2 {
+			t.Errorf("statuses = %v, want one 201 and 19 409", statuses)
+		}
+
+		rsvps, err := server.app.models.Attendees.GetRSVPsByEvent(context.Background(), eventId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rsvps) != 1 || rsvps[0].UserId != attendee.Id {
+			t.Errorf("attendee rows = %v, want exactly one", rsvps)
+		}
+	})
+}
+
+func TestAddAttendeesConcurrentlyRespectsCapacity(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, `, "capacity": 3`)
+
+		var paths []string
+		for i := range 12 {
+			user, _ := server.newUser(t, fmt.Sprintf("Attendee%d", i))
+			paths = append(paths, fmt.Sprintf("/api/v1/events/%d/attendees/%d", eventId, user.Id))
+		}
+
+		statuses := addConcurrently(t, server, ownerToken, paths, 2)
+
+		if statuses[http.StatusCreated] != 3 || statuses[http.StatusAccepted] != 9 || statuses[http.StatusConflict] != 12 {
+			t.Errorf("statuses = %v, want three 201, nine 202 and a 409 for every repeated request", statuses)
+		}
+
+		users, err := server.app.models.Attendees.GetAttendeesByEvent(context.Background(), eventId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 3 {
+			t.Errorf("going = %d, want the capacity of 3", len(users))
+		}
+	})
+}
+
+func addConcurrently(t *testing.T, server *testServer, token string, paths []string, repeat int) map[int]int {
+	t.Helper()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		start    = make(chan struct{})
+		statuses = map[int]int{}
+	)
+
+	for _, path := range paths {
+		for range repeat {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+
+				request, err := http.NewRequest(http.MethodPost, server.URL+path, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				request.Header.Set("Authorization", "Bearer "+token)
+
+				response, err := server.Client().Do(request)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				response.Body.Close()
+
+				mu.Lock()
+				statuses[response.StatusCode]++
+				mu.Unlock()
+			}()
+		}
+	}
+
+	close(start)
+	wg.Wait()
+
+	return statuses
+}
+
+/*
+addConcurrently sends every POST repeat times, all at once, and counts the
+status codes of the answers. The requests only wait for each other in the
+database, so they race exactly like requests of different clients would.
+*/
+
+func TestEventETag(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, token := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, token, "")
+		path := fmt.Sprintf("/api/v1/events/%d", eventId)
+
+		send := func(method, header, etag, body string) *http.Response {
+			request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Authorization", "Bearer "+token)
+			request.Header.Set("Content-Type", "application/merge-patch+json")
+			if header != "" {
+				request.Header.Set(header, etag)
+			}
+
+			response, err := server.Client().Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { response.Body.Close() })
+			return response
+		}
+
+		response := server.do(t, http.MethodPost, path+"/comments", token, `{"body": "See you there"}`)
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("commenting = %s", response.Status)
+		}
+
+		response = send(http.MethodGet, "", "", "")
+		if etag := response.Header.Get("ETag"); etag != `W/"1-1"` {
+			t.Errorf("ETag of GET = %s, want the version of the event and its comments", etag)
+		}
+
+		response = send(http.MethodPatch, "If-Match", `"1.garbage"`, `{"location": "Hamburg"}`)
+		if response.StatusCode != http.StatusPreconditionFailed {
+			t.Errorf("PATCH with a made up ETag = %s, want 412", response.Status)
+		}
+
+		response = send(http.MethodPatch, "If-Match", `W/"1-1"`, `{"location": "Hamburg"}`)
+		if response.StatusCode != http.StatusOK || response.Header.Get("ETag") != `W/"2-1"` {
+			t.Fatalf("PATCH = %s with ETag %s, want 200 with W/\"2-1\"", response.Status, response.Header.Get("ETag"))
+		}
+		var patched eventResponse
+		decodeJSON(t, response, &patched)
+		if patched.CommentCount != 1 || patched.Location != "Hamburg" {
+			t.Errorf("PATCH answered %+v with commentCount %d, want the changed event with 1 comment", patched.Event, patched.CommentCount)
+		}
+
+		response = send(http.MethodGet, "If-None-Match", `W/"2-1"`, "")
+		if response.StatusCode != http.StatusNotModified {
+			t.Errorf("GET with the ETag of PATCH = %s, want 304", response.Status)
+		}
+
+		response = server.do(t, http.MethodPost, path+"/comments", token, `{"body": "Bring a laptop"}`)
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("commenting = %s", response.Status)
+		}
+
+		tests := []struct {
+			name   string
+			method string
+			header string
+			etag   string
+			body   string
+			status int
+		}{
+			{"GET with the ETag from before the comment", http.MethodGet, "If-None-Match", `W/"2-1"`, "", http.StatusOK},
+			{"GET with the ETag after the comment", http.MethodGet, "If-None-Match", `W/"2-2"`, "", http.StatusNotModified},
+			{"PATCH with the version and an old comment count", http.MethodPatch, "If-Match", `W/"2-1"`, `{"location": "Berlin"}`, http.StatusOK},
+			{"PATCH with the old version", http.MethodPatch, "If-Match", `W/"2-2"`, `{"location": "Bremen"}`, http.StatusPreconditionFailed},
+			{"PATCH with the plain version", http.MethodPatch, "If-Match", `"3"`, `{"location": "Bremen"}`, http.StatusOK},
+		}
+
+		for _, tc := range tests {
+			if response := send(tc.method, tc.header, tc.etag, tc.body); response.StatusCode != tc.status {
+				t.Errorf("%s = %s, want %d", tc.name, response.Status, tc.status)
+			}
+		}
+	})
+}
+
+// A comment does not change the version of the event, only the weak tag of
+// GET, so If-None-Match sees it and If-Match does not.
+
+func TestEventsByAttendeeOnlyListsVisibleEvents(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		ctx := context.Background()
+		_, ownerToken := server.newUser(t, "Owner")
+		attendee, attendeeToken := server.newUser(t, "Attendee")
+		_, strangerToken := server.newUser(t, "Stranger")
+		coHost, coHostToken := server.newUser(t, "CoHost")
+		admin, adminToken := server.newUser(t, "Admin")
+		if err := server.app.models.Users.SetRole(ctx, admin.Id, database.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+
+		events := map[string]int{
+			"public":   server.newEvent(t, ownerToken, ""),
+			"unlisted": server.newEvent(t, ownerToken, `, "visibility": "unlisted"`),
+			"private":  server.newEvent(t, ownerToken, `, "visibility": "private"`),
+			"draft":    server.newEvent(t, ownerToken, `, "status": "draft"`),
+		}
+		for _, eventId := range events {
+			if _, err := server.app.models.Attendees.Insert(ctx, &database.Attendee{EventId: eventId, UserId: attendee.Id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := server.app.models.Members.Insert(ctx, &database.Member{EventId: events["private"], UserId: coHost.Id, Role: database.MemberCoHost})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			viewer string
+			token  string
+			want   []string
+		}{
+			{"the attendee", attendeeToken, []string{"public", "unlisted", "private", "draft"}},
+			{"the owner", ownerToken, []string{"public", "unlisted", "private", "draft"}},
+			{"an admin", adminToken, []string{"public", "unlisted", "private", "draft"}},
+			{"a co-host of the private event", coHostToken, []string{"public", "private"}},
+			{"another user", strangerToken, []string{"public"}},
+			{"an anonymous visitor", "", []string{"public"}},
+		}
+
+		for _, tc := range tests {
+			response := server.do(t, http.MethodGet, fmt.Sprintf("/api/v1/attendees/%d/events", attendee.Id), tc.token, "")
+			if response.StatusCode != http.StatusOK {
+				t.Fatalf("events of the attendee for %s = %s", tc.viewer, response.Status)
+			}
+			var listed []database.Event
+			decodeJSON(t, response, &listed)
+
+			got := map[int]bool{}
+			for _, event := range listed {
+				got[event.Id] = true
+			}
+			want := map[int]bool{}
+			for _, name := range tc.want {
+				want[events[name]] = true
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("events of the attendee for %s = %v, want %v", tc.viewer, listed, tc.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				log.Print(fmt.Errorf("background task panicked: %v", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+/*
+background runs fn in its own goroutine so slow work like sending an email
+does not block the response. A panic in the task is logged instead of
+crashing the whole server. Every task is tracked in app.wg so a graceful
+shutdown can wait for it to finish.
+*/
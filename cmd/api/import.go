@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+	"github.com/schlafer/EventApp/internal/ical"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type importEventsRequest struct {
+	Format  string `form:"format" binding:"omitempty,oneof=csv ics"`
+	DryRun  bool   `form:"dryRun"`
+	Publish bool   `form:"publish"`
+}
+
+type importRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type importEventsResponse struct {
+	DryRun   bool              `json:"dryRun"`
+	Imported int               `json:"imported"`
+	Events   []*database.Event `json:"events"`
+	Errors   []importRowError  `json:"errors,omitempty"`
+}
+
+type importRow struct {
+	Row   int
+	Event *database.Event
+	Err   error
+}
+
+const (
+	maxImportSize = 5 << 20
+	maxImportRows = 1000
+)
+
+var errUnknownImportFormat = errors.New("unknown import format, use csv or ics")
+
+/*
+An import file is read into one importRow per event. Row is the line of
+the CSV file or the position of the VEVENT in the .ics file, so errors can
+point at the right place. A file that cannot be read at all is an error of
+its own and no rows are returned.
+*/
+
+func importFormat(format, filename, contentType string) (string, error) {
+	if format != "" {
+		return format, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return "csv", nil
+	case ".ics", ".ical":
+		return "ics", nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return "csv", nil
+	case "text/calendar":
+		return "ics", nil
+	}
+
+	return "", errUnknownImportFormat
+}
+
+// importFormat picks the format from the explicit format, the file
+// extension or the content type, in that order.
+
+func readImport(r io.Reader, format string) ([]importRow, error) {
+	var (
+		rows []importRow
+		err  error
+	)
+
+	switch format {
+	case "csv":
+		rows, err = readCSVImport(r)
+	case "ics":
+		rows, err = readICSImport(r)
+	default:
+		return nil, errUnknownImportFormat
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the file contains no events")
+	}
+
+	return rows, nil
+}
+
+var importColumns = map[string]bool{
+	"name":        true,
+	"description": true,
+	"starts_at":   true,
+	"ends_at":     true,
+	"timezone":    true,
+	"location":    true,
+	"capacity":    true,
+	"rrule":       true,
+	"exdates":     true,
+}
+
+func readCSVImport(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !importColumns[name] {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range []string{"name", "description", "starts_at", "ends_at", "location"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var rows []importRow
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := importRow{Row: line, Event: &database.Event{
+			Name:        field("name"),
+			Description: field("description"),
+			Timezone:    field("timezone"),
+			Location:    field("location"),
+			Rrule:       field("rrule"),
+		}}
+
+		for _, name := range []string{"starts_at", "ends_at"} {
+			value := field(name)
+			if value == "" || row.Err != nil {
+				continue
+			}
+
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				row.Err = fmt.Errorf("invalid %s %q, use RFC 3339 like 2026-11-05T18:00:00+01:00", name, value)
+			} else if name == "starts_at" {
+				row.Event.StartsAt = parsed
+			} else {
+				row.Event.EndsAt = parsed
+			}
+		}
+
+		if exdates := field("exdates"); exdates != "" {
+			row.Event.ExDates = strings.FieldsFunc(exdates, func(r rune) bool {
+				return r == ',' || r == ';' || r == ' '
+			})
+		}
+
+		if capacity := field("capacity"); capacity != "" && row.Err == nil {
+			value, err := strconv.Atoi(capacity)
+			if err != nil {
+				row.Err = fmt.Errorf("invalid capacity %q", capacity)
+			} else {
+				row.Event.Capacity = &value
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+/*
+The CSV file needs a header row, the columns can come in any order and
+timezone, rrule, exdates and capacity are optional. starts_at and ends_at
+are RFC 3339 timestamps, like in the JSON API. The excluded dates of a
+series share one column, separated by commas, semicolons or spaces.
+*/
+
+func readICSImport(r io.Reader) ([]importRow, error) {
+	parsed, err := ical.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]importRow, 0, len(parsed))
+
+	for _, entry := range parsed {
+		row := importRow{Row: entry.Row, Err: entry.Err}
+
+		switch {
+		case row.Err != nil:
+		case !entry.Event.RecurrenceId.IsZero():
+			row.Err = errors.New("occurrences of a recurring event (RECURRENCE-ID) cannot be imported")
+		case entry.Event.Start.IsZero():
+			row.Err = errors.New("DTSTART is required")
+		default:
+			zone := entry.Event.Start.Location()
+			row.Event = &database.Event{
+				Name:        entry.Event.Summary,
+				Description: entry.Event.Description,
+				StartsAt:    entry.Event.Start,
+				EndsAt:      entry.Event.End,
+				Timezone:    zone.String(),
+				Location:    entry.Event.Location,
+				Rrule:       entry.Event.RRule,
+			}
+			for _, exdate := range entry.Event.ExDates {
+				row.Event.ExDates = append(row.Event.ExDates, exdate.In(zone).Format(time.DateOnly))
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readICSImport turns every VEVENT into an event. Edited occurrences of a
+// series cannot be represented by a single row, so they are reported.
+
+func validateImport(rows []importRow) ([]*database.Event, []importRowError) {
+	events := []*database.Event{}
+	var rowErrors []importRowError
+
+	for _, row := range rows {
+		err := row.Err
+		if err == nil {
+			err = binding.Validator.ValidateStruct(row.Event)
+		}
+		if err == nil {
+			row.Event.Rrule, err = database.NormalizeRule(row.Event.Rrule)
+		}
+
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: row.Row, Error: err.Error()})
+			continue
+		}
+
+		events = append(events, row.Event)
+	}
+
+	return events, rowErrors
+}
+
+/*
+validateImport runs every event through the binding tags of
+database.Event, the same validation createEvent gets from ShouldBindJSON,
+and through the recurrence rule check. All rows are checked so a file can
+be fixed in one go.
+*/
+
+func (app *application) applyImport(ctx context.Context, rows []importRow, ownerId int, publish, dryRun bool) (*importEventsResponse, error) {
+	events, rowErrors := validateImport(rows)
+
+	response := &importEventsResponse{DryRun: dryRun, Events: events, Errors: rowErrors}
+	if len(rowErrors) > 0 {
+		response.Events = []*database.Event{}
+		return response, nil
+	}
+
+	status := database.EventDraft
+	if publish {
+		status = database.EventPublished
+	}
+
+	for _, event := range events {
+		event.OwnerId = ownerId
+		event.Status = status
+	}
+
+	if dryRun {
+		return response, nil
+	}
+
+	if err := app.models.Events.InsertMany(ctx, events); err != nil {
+		return nil, err
+	}
+
+	response.Imported = len(events)
+	return response, nil
+}
+
+/*
+applyImport is shared by the endpoint and the import command. Nothing is
+inserted when a single row is invalid, and InsertMany stores the events in
+one transaction, so an import either lands completely or not at all.
+Imported events are drafts unless publish is set, so they can be
+reviewed before anybody sees them.
+*/
+
+// ImportEvents creates events from an iCalendar or CSV file
+//
+//	@Summary		Creates events from an iCalendar or CSV file
+//	@Description	Upload the file as multipart form field "file" or as the request body. The CSV file needs a header with the columns name, description, starts_at, ends_at (RFC 3339), location and optionally timezone, capacity, rrule and exdates. Every row is validated like createEvent; if one row is invalid nothing is imported and the errors are returned per row with status 422.
+//	@Tags			events
+//	@Accept			mpfd,text/csv,text/calendar
+//	@Produce		json
+//	@Param			file	formData	file	false	"The .ics or .csv file"
+//	@Param			format	query		string	false	"File format, detected from the file name or content type by default"	Enums(csv, ics)
+//	@Param			dryRun	query		bool	false	"Only validate the file"
+//	@Param			publish	query		bool	false	"Publish the events right away instead of importing them as drafts"
+//	@Success		201		{object}	importEventsResponse
+//	@Success		200		{object}	importEventsResponse	"Dry run"
+//	@Router			/api/v1/events/import [post]
+//	@Security		BearerAuth
+func (app *application) importEvents(c *gin.Context) {
+	var request importEventsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var (
+		body        io.Reader = c.Request.Body
+		filename    string
+		contentType = c.ContentType()
+	)
+
+	if contentType == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The file must be sent in the form field file"})
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the file"})
+			return
+		}
+		defer file.Close()
+
+		body = file
+		filename = header.Filename
+		contentType = header.Header.Get("Content-Type")
+	}
+
+	format, err := importFormat(request.Format, filename, contentType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := readImport(body, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows) > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d events can be imported at once", maxImportRows)})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	response, err := app.applyImport(c.Request.Context(), rows, user.Id, request.Publish, request.DryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import events"})
+		return
+	}
+
+	switch {
+	case len(response.Errors) > 0:
+		c.JSON(http.StatusUnprocessableEntity, response)
+	case request.DryRun:
+		c.JSON(http.StatusOK, response)
+	default:
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+/*
+The import is limited to 5MB and 1000 events per request, bigger
+migrations can use the import command of the api binary, see cli.go.
+*/
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/schlafer/EventApp/internal/database"
+)
+
+const importHeader = "name,description,starts_at,ends_at,location,rrule\n"
+
+func calendarFile(events ...string) string {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0"}
+	for _, event := range events {
+		lines = append(lines, "BEGIN:VEVENT")
+		lines = append(lines, strings.Split(event, "\n")...)
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// calendarFile wraps VEVENTs, their properties separated by newlines,
+// into an .ics file with CRLF line endings.
+
+func TestImportEvents(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		meetup := "UID:1@example.com\nSUMMARY:Go meetup\nDESCRIPTION:Talks about Go and databases\nLOCATION:Berlin\nDTSTART:20261105T180000Z\nDTEND:20261105T210000Z"
+
+		tests := []struct {
+			name        string
+			contentType string
+			query       string
+			body        string
+			status      int
+			errorRows   []int
+			stored      int
+		}{
+			{
+				"valid CSV", "text/csv", "",
+				importHeader +
+					"Go meetup,Talks about Go and databases,2026-11-05T18:00:00Z,2026-11-05T21:00:00Z,Berlin,\n" +
+					"Go workshop,Hands-on Go for beginners,2026-11-12T18:00:00+01:00,2026-11-12T21:00:00+01:00,Hamburg,FREQ=WEEKLY;COUNT=3\n",
+				http.StatusCreated, nil, 2,
+			},
+			{
+				"CSV with invalid rows", "text/csv", "",
+				importHeader +
+					"Go meetup,Talks about Go and databases,2026-11-05T18:00:00Z,2026-11-05T21:00:00Z,Berlin,\n" +
+					"Go meetup,Talks about Go and databases,5.11.2026 18:00,2026-11-05T21:00:00Z,Berlin,\n" +
+					"Go meetup,Talks about Go and databases,2026-11-05T18:00:00Z,2026-11-05T21:00:00Z,,\n" +
+					"Go meetup,Talks about Go and databases,2026-11-05T18:00:00Z,2026-11-05T21:00:00Z,Berlin,FREQ=HOURLY\n",
+				http.StatusUnprocessableEntity, []int{3, 4, 5}, 0,
+			},
+			{
+				"dry run", "text/csv", "?dryRun=true",
+				importHeader + "Go meetup,Talks about Go and databases,2026-11-05T18:00:00Z,2026-11-05T21:00:00Z,Berlin,\n",
+				http.StatusOK, nil, 0,
+			},
+			{
+				"valid calendar", "text/calendar", "",
+				calendarFile(meetup, strings.Replace(meetup, "1@", "2@", 1)+"\nRRULE:FREQ=WEEKLY;COUNT=2"),
+				http.StatusCreated, nil, 2,
+			},
+			{
+				"calendar with an edited occurrence", "text/calendar", "",
+				calendarFile(meetup, meetup+"\nRECURRENCE-ID:20261112T180000Z", "UID:3@example.com\nSUMMARY:No start\nLOCATION:Berlin"),
+				http.StatusUnprocessableEntity, []int{2, 3}, 0,
+			},
+		}
+
+		for i, tc := range tests {
+			user, token := server.newUser(t, fmt.Sprintf("Importer%d", i))
+
+			request, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/events/import"+tc.query, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Authorization", "Bearer "+token)
+			request.Header.Set("Content-Type", tc.contentType)
+
+			response, err := server.Client().Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result importEventsResponse
+			decodeJSON(t, response, &result)
+			response.Body.Close()
+
+			if response.StatusCode != tc.status {
+				t.Errorf("%s: import = %s, want %d", tc.name, response.Status, tc.status)
+			}
+
+			var rows []int
+			for _, rowError := range result.Errors {
+				rows = append(rows, rowError.Row)
+			}
+			if fmt.Sprint(rows) != fmt.Sprint(tc.errorRows) {
+				t.Errorf("%s: errors = %+v, want rows %v", tc.name, result.Errors, tc.errorRows)
+			}
+
+			_, metadata, err := server.app.models.Events.GetAll(context.Background(),
+				database.EventFilters{OwnerId: user.Id, ShowHidden: true, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if metadata.Total != tc.stored || result.Imported != tc.stored {
+				t.Errorf("%s: %d events stored and %d imported, want %d", tc.name, metadata.Total, result.Imported, tc.stored)
+			}
+		}
+	})
+}
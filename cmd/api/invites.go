@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createInviteRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt" example:"2026-12-01T00:00:00Z"`
+	MaxUses   *int       `json:"maxUses" binding:"omitempty,min=1"`
+}
+
+type inviteResponse struct {
+	*database.Invite
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+func (app *application) signInvite(eventId, inviteId int) string {
+	return app.signPayload("invite", fmt.Sprintf("%d.%d", eventId, inviteId))
+}
+
+func (app *application) parseInvite(token string) (int, int, bool) {
+	payload, ok := app.verifyPayload("invite", token)
+	if !ok {
+		return 0, 0, false
+	}
+
+	var eventId, inviteId int
+	if _, err := fmt.Sscanf(payload, "%d.%d", &eventId, &inviteId); err != nil {
+		return 0, 0, false
+	}
+
+	return eventId, inviteId, true
+}
+
+/*
+An invite token is the id of the event and of the invite, signed with
+signPayload. The signature makes the ids unguessable without storing a
+secret per invite, and lets us reject forged tokens before touching the
+database. Expiry and uses live in the database, so they can change after
+the link was handed out.
+*/
+
+func (app *application) inviteResponse(invite *database.Invite) inviteResponse {
+	token := app.signInvite(invite.EventId, invite.Id)
+
+	return inviteResponse{
+		Invite: invite,
+		Token:  token,
+		URL:    fmt.Sprintf("%s/api/v1/events/%d?invite=%s", app.baseURL, invite.EventId, token),
+	}
+}
+
+func (app *application) inviteFromRequest(c *gin.Context, eventId int) (*database.Invite, error) {
+	token := c.Query("invite")
+	if token == "" {
+		return nil, nil
+	}
+
+	tokenEventId, inviteId, ok := app.parseInvite(token)
+	if !ok || tokenEventId != eventId {
+		return nil, nil
+	}
+
+	invite, err := app.models.Invites.Get(c.Request.Context(), inviteId)
+	if err != nil || invite == nil {
+		return nil, err
+	}
+	if invite.EventId != eventId || !invite.Valid(time.Now()) {
+		return nil, nil
+	}
+
+	return invite, nil
+}
+
+// inviteFromRequest returns the invite of the invite query parameter if
+// it is a valid invite for the event, nil otherwise.
+
+// CreateInvite creates an invite link for an event
+//
+//	@Summary		Creates an invite link for an event
+//	@Description	Everybody with the link can see the event, even if it is private, and sign up for it. Every sign up uses the link once. Without expiresAt and maxUses the link works until it is deleted.
+//	@Tags			invites
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Event ID"
+//	@Param			invite	body		createInviteRequest	true	"Expiry and maximum number of uses"
+//	@Success		201		{object}	inviteResponse
+//	@Router			/api/v1/events/{id}/invites [post]
+//	@Security		BearerAuth
+func (app *application) createInvite(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionManageInvites)
+	if !ok {
+		return
+	}
+
+	if event.SeriesId != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invites are created for the whole series"})
+		return
+	}
+
+	var request createInviteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"})
+		return
+	}
+
+	invite := &database.Invite{
+		EventId:   event.Id,
+		CreatedBy: app.GetUserFromContext(c).Id,
+		ExpiresAt: request.ExpiresAt,
+		MaxUses:   request.MaxUses,
+	}
+
+	if err := app.models.Invites.Insert(c.Request.Context(), invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, app.inviteResponse(invite))
+}
+
+// GetInvites returns the invite links of an event
+//
+//	@Summary		Returns the invite links of an event
+//	@Description	Returns every invite of the event with its link and how often it was used
+//	@Tags			invites
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	[]inviteResponse
+//	@Router			/api/v1/events/{id}/invites [get]
+//	@Security		BearerAuth
+func (app *application) getInvites(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionManageInvites)
+	if !ok {
+		return
+	}
+
+	invites, err := app.models.Invites.GetByEvent(c.Request.Context(), event.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive invites"})
+		return
+	}
+
+	responses := make([]inviteResponse, len(invites))
+	for i, invite := range invites {
+		responses[i] = app.inviteResponse(invite)
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// DeleteInvite revokes an invite link
+//
+//	@Summary		Revokes an invite link
+//	@Description	The link stops working, users that already signed up through it keep seeing the event
+//	@Tags			invites
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	int	true	"Event ID"
+//	@Param			inviteId	path	int	true	"Invite ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/invites/{inviteId} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteInvite(c *gin.Context) {
+	inviteId, err := strconv.Atoi(c.Param("inviteId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite id"})
+		return
+	}
+
+	event, ok := app.authorizeEvent(c, actionManageInvites)
+	if !ok {
+		return
+	}
+
+	deleted, err := app.models.Invites.Delete(c.Request.Context(), event.Id, inviteId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete invite"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type cancelEventRequest struct {
+	Reason string `json:"reason" binding:"required,min=3,max=500"`
+}
+
+func (app *application) requireOpen(c *gin.Context, event *database.Event) bool {
+	if !event.IsOpen() {
+		c.JSON(http.StatusConflict, gin.H{"error": "The event is " + event.Status + ", nobody can sign up for it"})
+		return false
+	}
+	return true
+}
+
+// requireOpen guards the endpoints that sign users up for an event,
+// only published events take attendees.
+
+func (app *application) transitionEvent(c *gin.Context, status, reason string) {
+	event, ok := app.authorizeEvent(c, actionChangeStatus)
+	if !ok {
+		return
+	}
+
+	if status == database.EventCompleted && time.Now().Before(event.StartsAt) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An event can only be completed once it has started"})
+		return
+	}
+
+	kind := database.WebhookEventUpdated
+	if status == database.EventCancelled {
+		kind = database.WebhookEventCancelled
+	}
+
+	notifications := app.notifications()
+	notifications.add(kind, event, nil)
+
+	err := app.models.Events.SetStatus(c.Request.Context(), event, status, reason, notifications.enqueue)
+	if errors.Is(err, database.ErrInvalidTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A " + event.Status + " event cannot be " + status})
+		return
+	}
+	if errors.Is(err, database.ErrEditConflict) {
+		app.preconditionFailed(c, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change the status of the event"})
+		return
+	}
+
+	notifications.send()
+
+	app.writeEvent(c, http.StatusOK, event)
+}
+
+/*
+transitionEvent is shared by the status endpoints. Which transitions are
+allowed is decided by database.CanTransition, a forbidden one is answered
+with 409 and the event stays as it is. Webhooks and streams learn about a
+cancellation as event.cancelled, so receivers can tell their users, and
+about every other transition as event.updated.
+*/
+
+// PublishEvent publishes a draft
+//
+//	@Summary		Publishes a draft
+//	@Description	Makes a draft visible to everybody and opens it for RSVPs
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	eventResponse
+//	@Router			/api/v1/events/{id}/publish [post]
+//	@Security		BearerAuth
+func (app *application) publishEvent(c *gin.Context) {
+	app.transitionEvent(c, database.EventPublished, "")
+}
+
+// CancelEvent cancels a published event
+//
+//	@Summary		Cancels a published event
+//	@Description	Closes the event for RSVPs. Unlike deleting it, the event, its attendees and the reason stay available.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Event ID"
+//	@Param			cancel	body		cancelEventRequest	true	"Why the event is cancelled"
+//	@Success		200		{object}	eventResponse
+//	@Router			/api/v1/events/{id}/cancel [post]
+//	@Security		BearerAuth
+func (app *application) cancelEvent(c *gin.Context) {
+	var request cancelEventRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	app.transitionEvent(c, database.EventCancelled, request.Reason)
+}
+
+// CompleteEvent marks a published event as completed
+//
+//	@Summary		Marks a published event as completed
+//	@Description	Closes the event for RSVPs once it has started. Events are also completed automatically after they ended.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	eventResponse
+//	@Router			/api/v1/events/{id}/complete [post]
+//	@Security		BearerAuth
+func (app *application) completeEvent(c *gin.Context) {
+	app.transitionEvent(c, database.EventCompleted, "")
+}
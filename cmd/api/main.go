@@ -1,14 +1,21 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Embeds the time zone database, the runtime image has none
 
 	_ "github.com/joho/godotenv/autoload" // Automatically loads environment variables
-	_ "github.com/mattn/go-sqlite3"
 	_ "github.com/schlafer/EventApp/docs"
 	"github.com/schlafer/EventApp/internal/database"
 	"github.com/schlafer/EventApp/internal/env"
+	"github.com/schlafer/EventApp/internal/hub"
+	"github.com/schlafer/EventApp/internal/mailer"
 )
 
 // @title EventApp API Documentation
@@ -23,30 +30,124 @@ import (
 // @security BearerAuth
 
 type application struct {
-	port      int
-	jwtSecret string
-	models    database.Models
+	port              int
+	baseURL           string
+	jwtSecret         string
+	accessTokenTTL    time.Duration
+	refreshTokenTTL   time.Duration
+	passwordResetTTL  time.Duration
+	verificationTTL   time.Duration
+	emailVerification string
+	shutdownTimeout   time.Duration
+	cleanupInterval   time.Duration
+	webhookInterval   time.Duration
+	webhookRetryBase  time.Duration
+	webhookClient     *http.Client
+	webhookWake       chan struct{}
+	hub               *hub.Hub
+	chat              *hub.Hub
+	models            database.Models
+	mailer            mailer.Mailer
+	wg                sync.WaitGroup
 }
 
 func main() {
 
-	db, err := sql.Open("sqlite3", "./data.db")
+	db, dialect, err := database.Open(env.GetEnvString("DATABASE_URL", "sqlite://./data.db"))
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	models := database.NewModels(db)
+	timeouts, err := database.ParseTimeouts(
+		env.GetEnvDuration("DB_TIMEOUT", 3*time.Second),
+		env.GetEnvString("DB_TIMEOUTS", ""),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	models := database.NewModels(db, dialect, timeouts)
 
 	app := &application{
-		port:      env.GetEnvInt("PORT", 8080),
-		jwtSecret: env.GetEnvString("JWT_SECRET", "123secret"),
-		models:    models,
+		port:              env.GetEnvInt("PORT", 8080),
+		baseURL:           strings.TrimSuffix(env.GetEnvString("BASE_URL", "http://localhost:8080"), "/"),
+		jwtSecret:         env.GetEnvString("JWT_SECRET", "123secret"),
+		accessTokenTTL:    env.GetEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		refreshTokenTTL:   env.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		passwordResetTTL:  env.GetEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		verificationTTL:   env.GetEnvDuration("EMAIL_VERIFICATION_TTL", 72*time.Hour),
+		emailVerification: env.GetEnvString("EMAIL_VERIFICATION", verificationOff),
+		shutdownTimeout:   env.GetEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		cleanupInterval:   env.GetEnvDuration("CLEANUP_INTERVAL", time.Hour),
+		webhookInterval:   env.GetEnvDuration("WEBHOOK_INTERVAL", 10*time.Second),
+		webhookRetryBase:  env.GetEnvDuration("WEBHOOK_RETRY_BASE", 30*time.Second),
+		webhookClient:     newWebhookClient(),
+		webhookWake:       make(chan struct{}, 1),
+		hub:               hub.New(streamHistory, streamBuffer),
+		chat:              hub.New(0, chatBuffer),
+		models:            models,
+		mailer:            newMailer(),
 	}
 
-	if err := serve(app); err != nil {
+	if err := bootstrapAdmin(app, env.GetEnvString("ADMIN_EMAIL", "")); err != nil {
 		log.Fatal(err)
 	}
+
+	if len(os.Args) > 1 {
+		if err := app.runCommand(os.Args[1:]); err != nil {
+			log.Print(err)
+			db.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := serve(app); err != nil {
+		log.Print(err)
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+func bootstrapAdmin(app *application, email string) error {
+	if email == "" {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	user, err := app.models.Users.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		log.Printf("ADMIN_EMAIL %s does not belong to a registered user", email)
+		return nil
+	}
+
+	if user.Role == database.RoleAdmin {
+		return nil
+	}
+
+	log.Printf("Promoting %s to admin", email)
+	return app.models.Users.SetRole(ctx, user.Id, database.RoleAdmin)
+}
+
+func newMailer() mailer.Mailer {
+	host := env.GetEnvString("SMTP_HOST", "")
+	if host == "" {
+		return mailer.NewLogMailer(os.Stdout)
+	}
+
+	return &mailer.SMTPMailer{
+		Host:     host,
+		Port:     env.GetEnvInt("SMTP_PORT", 587),
+		Username: env.GetEnvString("SMTP_USERNAME", ""),
+		Password: env.GetEnvString("SMTP_PASSWORD", ""),
+		From:     env.GetEnvString("SMTP_FROM", "EventApp <no-reply@eventapp.local>"),
+	}
 }
 
 /*
@@ -55,4 +156,17 @@ create an application struct and start the server using the serve function.
 The application struct will be used to pass the dependencies around
 without having global variables.
 We then start the server using the serve function.
+Emails are delivered over SMTP when SMTP_HOST is set, otherwise they are
+written to stdout so the app can be used without a mail server.
+EMAIL_VERIFICATION decides who has to confirm their email address:
+off lets everybody in, events only refuses unverified users when they
+create an event, all refuses them on every authenticated route.
+ADMIN_EMAIL promotes an existing user to admin on startup, this is how the
+first admin is created, every other role is managed through the admin routes.
+DATABASE_URL selects the storage: a postgres:// URL uses PostgreSQL,
+anything else is the path of an SQLite database file.
+BASE_URL is the address clients reach the API at, it is used for links
+that leave the API, like the URL of a calendar feed.
+Started with arguments, the binary runs a command like import instead
+of the server, see cli.go.
 */
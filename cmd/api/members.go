@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type addMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=co-host check-in viewer"`
+}
+
+type memberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=co-host check-in viewer"`
+}
+
+type transferOwnershipRequest struct {
+	UserId int `json:"userId" binding:"required,min=1"`
+}
+
+func (app *application) notifyMember(user *database.User, event *database.Event, subject, message string) {
+	app.background(func() {
+		body := fmt.Sprintf("Hi %s,\n\n%s\n\n%s/api/v1/events/%d", user.Name, message, app.baseURL, event.Id)
+
+		if err := app.mailer.Send(user.Email, subject, body); err != nil {
+			log.Print(err)
+		}
+	})
+}
+
+// notifyMember emails a user that their role in an event changed, in the
+// background like the verification email.
+
+// GetMembers returns the members of an event
+//
+//	@Summary		Returns the members of an event
+//	@Description	Returns the co-hosts, check-in staff and viewers of an event. The owner and every member can see them.
+//	@Tags			members
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	[]database.Member
+//	@Router			/api/v1/events/{id}/members [get]
+//	@Security		BearerAuth
+func (app *application) getMembers(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionViewMembers)
+	if !ok {
+		return
+	}
+
+	members, err := app.models.Members.GetByEvent(c.Request.Context(), seriesIdOf(event))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// AddMember adds a member to an event
+//
+//	@Summary		Adds a member to an event
+//	@Description	Gives a registered user a role in the event and emails them about it. co-host can edit the event and manage attendees, check-in can see and check in attendees, viewer can only see the event. Only the owner and admins can add members.
+//	@Tags			members
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Event ID"
+//	@Param			member	body		addMemberRequest	true	"Email of the user and role"
+//	@Success		201		{object}	database.Member
+//	@Router			/api/v1/events/{id}/members [post]
+//	@Security		BearerAuth
+func (app *application) addMember(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionManageMembers)
+	if !ok {
+		return
+	}
+
+	var request addMemberRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(c.Request.Context(), request.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Id == event.OwnerId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner cannot be a member of their own event"})
+		return
+	}
+
+	addedBy := app.GetUserFromContext(c).Id
+	member := &database.Member{
+		EventId: seriesIdOf(event),
+		UserId:  user.Id,
+		Name:    user.Name,
+		Email:   user.Email,
+		Role:    request.Role,
+		AddedBy: &addedBy,
+	}
+
+	err = app.models.Members.Insert(c.Request.Context(), member)
+	if errors.Is(err, database.ErrDuplicateMember) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The user is already a member, change the role instead"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	app.notifyMember(user, event, "You were added to an event on EventApp",
+		fmt.Sprintf("you were added to %q as %s.", event.Name, member.Role))
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// UpdateMember changes the role of a member
+//
+//	@Summary		Changes the role of a member
+//	@Description	Only the owner and admins can change roles. A co-host that gets another role loses the webhooks they created for the event.
+//	@Tags			members
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Event ID"
+//	@Param			userId	path		int					true	"User ID"
+//	@Param			member	body		memberRoleRequest	true	"New role"
+//	@Success		200		{object}	database.Member
+//	@Router			/api/v1/events/{id}/members/{userId} [put]
+//	@Security		BearerAuth
+func (app *application) updateMember(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	event, ok := app.authorizeEvent(c, actionManageMembers)
+	if !ok {
+		return
+	}
+
+	var request memberRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := app.models.Members.SetRole(c.Request.Context(), seriesIdOf(event), userId, request.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	app.recheckAccess(userId)
+
+	member, err := app.models.Members.Get(c.Request.Context(), seriesIdOf(event), userId)
+	if err != nil || member == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember removes a member from an event
+//
+//	@Summary		Removes a member from an event
+//	@Description	The owner and admins can remove any member, every member can remove themselves. The webhooks the member created for the event are deactivated.
+//	@Tags			members
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int	true	"Event ID"
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/members/{userId} [delete]
+//	@Security		BearerAuth
+func (app *application) removeMember(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	action := actionManageMembers
+	if userId == app.GetUserFromContext(c).Id {
+		action = actionViewMembers
+	}
+
+	event, ok := app.authorizeEvent(c, action)
+	if !ok {
+		return
+	}
+
+	deleted, err := app.models.Members.Delete(c.Request.Context(), seriesIdOf(event), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	app.recheckAccess(userId)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+/*
+Members always belong to the series, the endpoints accept the id of an
+occurrence as well. Leaving an event only needs the permission to see its
+members, which every member has, everybody else needs the permission to
+manage the members.
+*/
+
+// TransferOwnership gives an event to another user
+//
+//	@Summary		Gives an event to another user
+//	@Description	Makes another user the owner of the event and its occurrences. The previous owner stays on as a co-host. Only the owner and admins can transfer an event.
+//	@Tags			members
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int							true	"Event ID"
+//	@Param			If-Match	header		string						false	"ETag of the version the change is based on"
+//	@Param			transfer	body		transferOwnershipRequest	true	"The new owner"
+//	@Success		200			{object}	eventResponse
+//	@Header			200			{string}	ETag	"Weak tag of the new version and the number of comments"
+//	@Router			/api/v1/events/{id}/transfer [post]
+//	@Security		BearerAuth
+func (app *application) transferOwnership(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionTransferOwnership)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(c, event) {
+		return
+	}
+
+	if event.SeriesId != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An occurrence is transferred together with its series"})
+		return
+	}
+
+	var request transferOwnershipRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.UserId == event.OwnerId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The user already owns the event"})
+		return
+	}
+
+	newOwner, err := app.models.Users.Get(c.Request.Context(), request.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive user"})
+		return
+	}
+	if newOwner == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	notifications := app.notifications()
+	notifications.add(database.WebhookEventUpdated, event, nil)
+
+	err = app.models.Members.TransferOwnership(c.Request.Context(), event, newOwner.Id, notifications.enqueue)
+	if errors.Is(err, database.ErrEditConflict) {
+		app.preconditionFailed(c, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer event"})
+		return
+	}
+
+	notifications.send()
+
+	app.notifyMember(newOwner, event, "You are the new owner of an event on EventApp",
+		fmt.Sprintf("%q was transferred to you, you are its owner now.", event.Name))
+
+	app.writeEvent(c, http.StatusOK, event)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/schlafer/EventApp/internal/database"
+)
+
+func (s *testServer) addMember(t *testing.T, ownerToken string, eventId int, user *database.User, role string) {
+	t.Helper()
+
+	path := fmt.Sprintf("/api/v1/events/%d/members", eventId)
+	body := fmt.Sprintf(`{"email": %q, "role": %q}`, user.Email, role)
+	if response := s.do(t, http.MethodPost, path, ownerToken, body); response.StatusCode != http.StatusCreated {
+		t.Fatalf("adding %s as %s = %s", user.Name, role, response.Status)
+	}
+}
+
+func TestMemberRoles(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		path := fmt.Sprintf("/api/v1/events/%d", eventId)
+
+		tokens := map[string]string{"owner": ownerToken}
+		for _, role := range []string{database.MemberCoHost, database.MemberCheckIn, database.MemberViewer} {
+			user, token := server.newUser(t, role)
+			server.addMember(t, ownerToken, eventId, user, role)
+			tokens[role] = token
+		}
+		other, token := server.newUser(t, "Other")
+		tokens["other"] = token
+
+		tests := []struct {
+			method string
+			path   string
+			body   string
+			status map[string]int
+		}{
+			{http.MethodPatch, path, `{"location": "Hamburg"}`, map[string]int{
+				"owner": 200, database.MemberCoHost: 200, database.MemberCheckIn: 403, database.MemberViewer: 403, "other": 403}},
+			{http.MethodGet, path + "/rsvps", "", map[string]int{
+				"owner": 200, database.MemberCoHost: 200, database.MemberCheckIn: 200, database.MemberViewer: 200, "other": 403}},
+			{http.MethodGet, path + "/check-ins", "", map[string]int{
+				"owner": 200, database.MemberCoHost: 200, database.MemberCheckIn: 200, database.MemberViewer: 200, "other": 403}},
+			{http.MethodGet, path + "/members", "", map[string]int{
+				"owner": 200, database.MemberCoHost: 200, database.MemberCheckIn: 200, database.MemberViewer: 200, "other": 403}},
+			{http.MethodPost, path + "/members", fmt.Sprintf(`{"email": %q, "role": "viewer"}`, other.Email), map[string]int{
+				database.MemberCoHost: 403, database.MemberCheckIn: 403, database.MemberViewer: 403, "other": 403}},
+			{http.MethodPost, path + "/transfer", fmt.Sprintf(`{"userId": %d}`, other.Id), map[string]int{
+				database.MemberCoHost: 403, database.MemberCheckIn: 403, database.MemberViewer: 403, "other": 403}},
+			{http.MethodDelete, path, "", map[string]int{
+				database.MemberCoHost: 403, database.MemberCheckIn: 403, database.MemberViewer: 403, "other": 403}},
+		}
+
+		for _, tc := range tests {
+			for who, status := range tc.status {
+				response := server.do(t, tc.method, tc.path, tokens[who], tc.body)
+				if response.StatusCode != status {
+					t.Errorf("%s %s as %s = %s, want %d", tc.method, tc.path, who, response.Status, status)
+				}
+			}
+		}
+	})
+}
+
+func TestMemberRoleChanges(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		member, memberToken := server.newUser(t, "Member")
+		server.addMember(t, ownerToken, eventId, member, database.MemberViewer)
+
+		path := fmt.Sprintf("/api/v1/events/%d", eventId)
+		memberPath := fmt.Sprintf("%s/members/%d", path, member.Id)
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			token  string
+			body   string
+			status int
+		}{
+			{"a viewer edits the event", http.MethodPatch, path, memberToken, `{"location": "Hamburg"}`, http.StatusForbidden},
+			{"the member promotes themselves", http.MethodPut, memberPath, memberToken, `{"role": "co-host"}`, http.StatusForbidden},
+			{"the owner promotes the member", http.MethodPut, memberPath, ownerToken, `{"role": "co-host"}`, http.StatusOK},
+			{"a co-host edits the event", http.MethodPatch, path, memberToken, `{"location": "Hamburg"}`, http.StatusOK},
+			{"the owner gives an unknown role", http.MethodPut, memberPath, ownerToken, `{"role": "owner"}`, http.StatusBadRequest},
+			{"the member leaves", http.MethodDelete, memberPath, memberToken, "", http.StatusNoContent},
+			{"the former member edits the event", http.MethodPatch, path, memberToken, `{"location": "Berlin"}`, http.StatusForbidden},
+			{"the owner removes the former member", http.MethodDelete, memberPath, ownerToken, "", http.StatusNotFound},
+		}
+
+		for _, tc := range tests {
+			if response := server.do(t, tc.method, tc.path, tc.token, tc.body); response.StatusCode != tc.status {
+				t.Errorf("%s = %s, want %d", tc.name, response.Status, tc.status)
+			}
+		}
+	})
+}
+
+func TestTransferOwnership(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		owner, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		newOwner, newOwnerToken := server.newUser(t, "NewOwner")
+		server.addMember(t, ownerToken, eventId, newOwner, database.MemberCheckIn)
+
+		path := fmt.Sprintf("/api/v1/events/%d", eventId)
+		transfer := func(userId int) string { return fmt.Sprintf(`{"userId": %d}`, userId) }
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			token  string
+			body   string
+			status int
+		}{
+			{"transfer to the owner", http.MethodPost, path + "/transfer", ownerToken, transfer(owner.Id), http.StatusBadRequest},
+			{"transfer to an unknown user", http.MethodPost, path + "/transfer", ownerToken, transfer(newOwner.Id + 1000), http.StatusNotFound},
+			{"transfer to the member", http.MethodPost, path + "/transfer", ownerToken, transfer(newOwner.Id), http.StatusOK},
+			{"the previous owner transfers again", http.MethodPost, path + "/transfer", ownerToken, transfer(owner.Id), http.StatusForbidden},
+			{"the previous owner edits as co-host", http.MethodPatch, path, ownerToken, `{"location": "Hamburg"}`, http.StatusOK},
+			{"the previous owner deletes the event", http.MethodDelete, path, ownerToken, "", http.StatusForbidden},
+			{"the new owner cannot be removed as a member", http.MethodDelete, fmt.Sprintf("%s/members/%d", path, newOwner.Id), newOwnerToken, "", http.StatusNotFound},
+			{"the new owner removes the previous owner", http.MethodDelete, fmt.Sprintf("%s/members/%d", path, owner.Id), newOwnerToken, "", http.StatusNoContent},
+			{"the previous owner edits again", http.MethodPatch, path, ownerToken, `{"location": "Berlin"}`, http.StatusForbidden},
+			{"the new owner deletes the event", http.MethodDelete, path, newOwnerToken, "", http.StatusNoContent},
+		}
+
+		for _, tc := range tests {
+			if response := server.do(t, tc.method, tc.path, tc.token, tc.body); response.StatusCode != tc.status {
+				t.Errorf("%s = %s, want %d", tc.name, response.Status, tc.status)
+			}
+		}
+	})
+}
+
+func TestCoHostKeepsTheOccurrencesAfterASplit(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		seriesId := server.newEvent(t, ownerToken, `, "rrule": "FREQ=WEEKLY;COUNT=4"`)
+		coHost, coHostToken := server.newUser(t, "CoHost")
+		server.addMember(t, ownerToken, seriesId, coHost, database.MemberCoHost)
+
+		occurrence := func(name, date string) string {
+			return fmt.Sprintf(`{"name": %q, "description": "Talks about Go and databases", "location": "Hamburg",
+				"startsAt": "%sT18:00:00Z", "endsAt": "%sT21:00:00Z"}`, name, date, date)
+		}
+
+		response := server.do(t, http.MethodPut, fmt.Sprintf("/api/v1/events/%d/occurrences/2026-11-19?scope=following", seriesId),
+			ownerToken, occurrence("Go meetup in Hamburg", "2026-11-19"))
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("splitting the series = %s", response.Status)
+		}
+		var following database.Event
+		decodeJSON(t, response, &following)
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			body   string
+		}{
+			{"edits the following series", http.MethodPatch, fmt.Sprintf("/api/v1/events/%d", following.Id), `{"capacity": 30}`},
+			{"edits an occurrence of it", http.MethodPut, fmt.Sprintf("/api/v1/events/%d/occurrences/2026-11-26?scope=this", following.Id), occurrence("Go meetup special", "2026-11-26")},
+			{"edits an occurrence of the first series", http.MethodPut, fmt.Sprintf("/api/v1/events/%d/occurrences/2026-11-12?scope=this", seriesId), occurrence("Go meetup special", "2026-11-12")},
+			{"sees the members of the following series", http.MethodGet, fmt.Sprintf("/api/v1/events/%d/members", following.Id), ""},
+		}
+
+		for _, tc := range tests {
+			if response := server.do(t, tc.method, tc.path, coHostToken, tc.body); response.StatusCode != http.StatusOK {
+				t.Errorf("the co-host %s = %s, want 200", tc.name, response.Status)
+			}
+		}
+	})
+}
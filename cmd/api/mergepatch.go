@@ -0,0 +1,30 @@
+package main
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+/*
+mergePatch applies a JSON Merge Patch (RFC 7396) to a decoded JSON value:
+members of the patch replace the members of the target, objects are merged
+recursively and null removes a member. Anything that is not an object,
+arrays included, replaces the target as a whole.
+*/
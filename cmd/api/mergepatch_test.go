@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tc := range tests {
+		var target, patch interface{}
+		if err := json.Unmarshal([]byte(tc.target), &target); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tc.patch), &patch); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := json.Marshal(mergePatch(target, patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("mergePatch(%s, %s) = %s, want %s", tc.target, tc.patch, got, tc.want)
+		}
+	}
+}
+
+// The cases are the examples of RFC 7396, appendix A.
+
+func TestPatchEventRemovesMembersWithNull(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, token := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, token, `, "capacity": 20, "rrule": "FREQ=WEEKLY;COUNT=4", "exdates": ["2026-11-12"]`)
+		path := fmt.Sprintf("/api/v1/events/%d", eventId)
+
+		tests := []struct {
+			patch   string
+			status  int
+			check   func(event eventResponse) bool
+			explain string
+		}{
+			{`{"capacity": null}`, http.StatusOK, func(e eventResponse) bool { return e.Capacity == nil && e.Location == "Berlin" }, "no capacity, the rest unchanged"},
+			{`{"exdates": null}`, http.StatusOK, func(e eventResponse) bool { return len(e.ExDates) == 0 && e.Rrule != "" }, "no excluded dates, the rule unchanged"},
+			{`{"location": "Hamburg", "description": null}`, http.StatusBadRequest, nil, "the required description cannot be removed"},
+			{`{"location": "Hamburg"}`, http.StatusOK, func(e eventResponse) bool { return e.Location == "Hamburg" && e.Capacity == nil }, "the new location, still no capacity"},
+		}
+
+		for _, tc := range tests {
+			response := server.do(t, http.MethodPatch, path, token, tc.patch)
+			if response.StatusCode != tc.status {
+				t.Errorf("PATCH %s = %s, want %d", tc.patch, response.Status, tc.status)
+				continue
+			}
+			if tc.check == nil {
+				continue
+			}
+
+			var event eventResponse
+			decodeJSON(t, response, &event)
+			if !tc.check(event) {
+				t.Errorf("PATCH %s = %+v, want %s", tc.patch, event.Event, tc.explain)
+			}
+		}
+	})
+}
@@ -6,11 +6,67 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/websocket"
 )
 
 func (app *application) AuthMiddleware() gin.HandlerFunc {
+	return app.authenticate(true)
+}
+
+func (app *application) OptionalAuthMiddleware() gin.HandlerFunc {
+	return app.authenticate(false)
+}
+
+// OptionalAuthMiddleware is used on public routes that show more to a
+// signed in user, like drafts to their owner. Requests without an
+// Authorization header pass as anonymous, a header that is sent has to be valid.
+
+func protocolToken(r *http.Request) string {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if token, ok := strings.CutPrefix(protocol, tokenProtocolPrefix); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+/*
+Browsers cannot set headers on a WebSocket handshake, but they can offer
+subprotocols, which are sent in the Sec-WebSocket-Protocol header. So a
+browser passes the access token as the subprotocol access_token.<token>,
+next to chatProtocol, which the server picks, so the token is never sent
+back. The token is not accepted in the URL, where it would end up in the
+request log, the browser history and the logs of every proxy in between.
+*/
+
+func redactAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if query.Has("access_token") {
+			query.Set("access_token", "REDACTED")
+			c.Request.URL.RawQuery = query.Encode()
+		}
+
+		c.Next()
+	}
+}
+
+// redactAccessToken runs before the request logger, so a client that
+// still sends the token in the access_token query parameter does not leave
+// it in the log.
+
+func (app *application) authenticate(required bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && websocket.IsWebSocketUpgrade(c.Request) {
+			if token := protocolToken(c.Request); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
+		if authHeader == "" && !required {
+			c.Next()
+			return
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			c.Abort()
@@ -44,21 +100,72 @@ func (app *application) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		userId := claims["userId"].(float64)
+		userId, _ := claims["userId"].(float64)
+		sessionId, ok := claims["sid"].(float64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
 
-		user, err := app.models.Users.Get(int(userId))
-		if err != nil {
+		session, err := app.models.Sessions.Get(c.Request.Context(), int(sessionId))
+		if err != nil || session == nil || !session.Active() || session.UserId != int(userId) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
+		user, err := app.models.Users.Get(c.Request.Context(), int(userId))
+		if err != nil || user == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
 			c.Abort()
 			return
 		}
 
+		if user.SuspendedAt != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your account has been suspended"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user", user)
+		c.Set("sessionId", session.Id)
 
 		c.Next()
 	}
 }
 
+func (app *application) RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := app.GetUserFromContext(c)
+		if user.VerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address first"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail runs after the AuthMiddleware and refuses users
+// that did not confirm their email address yet.
+
+func (app *application) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasRole(app.GetUserFromContext(c), roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access this resource"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole runs after the AuthMiddleware and only lets users
+// with one of the given roles through.
+
 /*
 Retrieve the Authorization Header: The middleware starts by reading
 the Authorization header from the incoming request.
@@ -81,12 +188,19 @@ ensuring it hasn’t been tampered with.
 Handle Invalid Tokens: If the token is invalid or an error occurs during parsing,
 the middleware responds with a 401 Unauthorized status and aborts the request.
 
+Check the Session: Every access token carries the id of the session
+it was issued for (the sid claim). If that session was revoked by a logout
+or has expired, the token is rejected even though its signature is valid.
+
 Extract User Information: If the token is valid,
 the middleware extracts the user ID from the token’s claims
 and retrieves the corresponding user from the database.
-The user is then set in the request context using c.Set("user", user).
+The user is then set in the request context using c.Set("user", user),
+together with the session id for the logout handlers.
 This allows other handlers in the chain to access the authenticated user.
 
+Suspended users are refused with a 403 even if their token is still valid.
+
 Allow the Request to Proceed: If the token is valid,
 the middleware calls c.Next(), allowing the request to proceed
 to the next handler in the chain.
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type listOccurrencesRequest struct {
+	From string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To   string `form:"to" binding:"omitempty,datetime=2006-01-02"`
+}
+
+type updateOccurrenceRequest struct {
+	Scope string `form:"scope" binding:"omitempty,oneof=this following"`
+}
+
+const maxOccurrenceWindow = 366 * 24 * time.Hour
+
+func (app *application) validateRecurrence(c *gin.Context, event *database.Event) bool {
+	rule, err := database.NormalizeRule(event.Rrule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	event.Rrule = rule
+	return true
+}
+
+func (app *application) requireRecurring(c *gin.Context, event *database.Event) bool {
+	if !event.IsRecurring() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event is not recurring"})
+		return false
+	}
+	return true
+}
+
+/*
+validateRecurrence checks the rrule of an event from a request body and
+stores it in canonical form. requireRecurring guards the endpoints that
+only make sense for a series. Both write the error response themselves.
+*/
+
+// GetOccurrences returns the occurrences of an event
+//
+//	@Summary		Returns the occurrences of an event
+//	@Description	Expands a recurring event into the occurrences that start between from and to, both whole days in UTC (default: the next 3 months, at most one year). The date of an occurrence is its local date in the time zone of the event. Occurrences with an id of 0 have not been stored yet.
+//	@Tags			occurrences
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Event ID"
+//	@Param			from	query		string	false	"First date (YYYY-MM-DD), default today"
+//	@Param			to		query		string	false	"Last date (YYYY-MM-DD)"
+//	@Param			invite	query		string	false	"Invite token of a private event"
+//	@Success		200		{object}	[]database.Occurrence
+//	@Router			/api/v1/events/{id}/occurrences [get]
+func (app *application) getOccurrences(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var request listOccurrencesRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if request.From != "" {
+		from, _ = time.Parse(time.DateOnly, request.From)
+	}
+
+	to := from.AddDate(0, 3, 0)
+	if request.To != "" {
+		to, _ = time.Parse(time.DateOnly, request.To)
+	}
+
+	if to.Before(from) || to.Sub(from) > maxOccurrenceWindow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from and at most one year later"})
+		return
+	}
+
+	to = to.AddDate(0, 0, 1).Add(-time.Second)
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.requireView(c, event) {
+		return
+	}
+
+	occurrences, err := app.models.Events.GetOccurrences(c.Request.Context(), event, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive occurrences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrences)
+}
+
+// CreateOccurrence stores a single occurrence of a recurring event
+//
+//	@Summary		Stores a single occurrence of a recurring event
+//	@Description	Returns the occurrence of the series on the given date as an event of its own, creating it if needed. Use its id to RSVP or to add attendees to this occurrence only.
+//	@Tags			occurrences
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Event ID of the series"
+//	@Param			date	path		string	true	"Occurrence date (YYYY-MM-DD)"
+//	@Param			invite	query		string	false	"Invite token of a private event"
+//	@Success		200		{object}	database.Event
+//	@Router			/api/v1/events/{id}/occurrences/{date} [post]
+//	@Security		BearerAuth
+func (app *application) createOccurrence(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	series, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
+	if series == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.requireView(c, series) {
+		return
+	}
+
+	if !app.requireRecurring(c, series) {
+		return
+	}
+
+	occurrence, err := app.models.Events.GetOrCreateOccurrence(c.Request.Context(), series, c.Param("date"))
+	if errors.Is(err, database.ErrNoOccurrence) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The event does not occur on this date"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store occurrence"})
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrence)
+}
+
+// UpdateOccurrence updates occurrences of a recurring event
+//
+//	@Summary		Updates occurrences of a recurring event
+//	@Description	scope=this (default) changes only the occurrence on the given date. scope=following ends the series the day before and starts a new series with the given details, keeping the rest of the rule unless a new rrule is sent.
+//	@Tags			occurrences
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Event ID of the series"
+//	@Param			date	path		string			true	"Occurrence date (YYYY-MM-DD)"
+//	@Param			scope	query		string			false	"Which occurrences to change"	Enums(this, following)
+//	@Param			event	body		database.Event	true	"Event"
+//	@Success		200		{object}	database.Event
+//	@Router			/api/v1/events/{id}/occurrences/{date} [put]
+//	@Security		BearerAuth
+func (app *application) updateOccurrence(c *gin.Context) {
+	series, ok := app.authorizeEvent(c, actionUpdateEvent)
+	if !ok {
+		return
+	}
+
+	if !app.requireRecurring(c, series) {
+		return
+	}
+
+	var request updateOccurrenceRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := &database.Event{}
+	if err := c.ShouldBindJSON(updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !app.validateRecurrence(c, updated) {
+		return
+	}
+
+	date := c.Param("date")
+
+	occurs, err := series.OccursOn(date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to expand the series"})
+		return
+	}
+	if !occurs {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The event does not occur on this date"})
+		return
+	}
+
+	updated.OwnerId = series.OwnerId
+	updated.Status = series.Status
+	if updated.Timezone == "" {
+		updated.Timezone = series.Timezone
+	}
+	if updated.Visibility == "" {
+		updated.Visibility = series.Visibility
+	}
+
+	notifications := app.notifications()
+
+	if request.Scope == "following" {
+		if series.StartDate() == date {
+			if updated.Rrule == "" {
+				updated.Rrule = series.Rrule
+			}
+			if updated.ExDates == nil {
+				updated.ExDates = series.ExDates
+			}
+			updated.Id = series.Id
+			updated.Version = series.Version
+			notifications.add(database.WebhookEventUpdated, updated, nil)
+			err = app.models.Events.Update(c.Request.Context(), updated, notifications.enqueue)
+		} else {
+			notifications.add(database.WebhookEventUpdated, series, nil)
+			notifications.add(database.WebhookEventCreated, updated, nil)
+			err = app.models.Events.SplitSeries(c.Request.Context(), series, date, updated, notifications.enqueue)
+		}
+
+		if errors.Is(err, database.ErrEditConflict) {
+			app.preconditionFailed(c, nil)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+			return
+		}
+
+		notifications.send()
+
+		c.JSON(http.StatusOK, updated)
+		return
+	}
+
+	if updated.Rrule != "" || len(updated.ExDates) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A single occurrence cannot recur"})
+		return
+	}
+
+	occurrence, err := app.models.Events.GetOrCreateOccurrence(c.Request.Context(), series, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store occurrence"})
+		return
+	}
+
+	updated.Id = occurrence.Id
+	updated.SeriesId = occurrence.SeriesId
+	updated.OccurrenceDate = occurrence.OccurrenceDate
+	updated.Modified = true
+	updated.Version = occurrence.Version
+	updated.Status = occurrence.Status
+
+	notifications.add(database.WebhookEventUpdated, updated, nil)
+
+	err = app.models.Events.Update(c.Request.Context(), updated, notifications.enqueue)
+	if errors.Is(err, database.ErrEditConflict) {
+		app.preconditionFailed(c, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+
+	notifications.send()
+
+	c.JSON(http.StatusOK, updated)
+}
+
+/*
+Occurrences stay in the time zone and visibility of the series unless the
+body names them.
+Editing "this occurrence" stores the occurrence and marks it as modified,
+so later changes to the series no longer overwrite it. Editing "this and
+following" from the first date of the series is the same as editing the
+whole series, otherwise the series is split in two.
+*/
+
+// DeleteOccurrence removes a single occurrence of a recurring event
+//
+//	@Summary		Removes a single occurrence of a recurring event
+//	@Description	Adds the date to the excluded dates (EXDATE) of the series and removes the attendees of that occurrence
+//	@Tags			occurrences
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int		true	"Event ID of the series"
+//	@Param			date	path	string	true	"Occurrence date (YYYY-MM-DD)"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/occurrences/{date} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteOccurrence(c *gin.Context) {
+	series, ok := app.authorizeEvent(c, actionUpdateEvent)
+	if !ok {
+		return
+	}
+
+	if !app.requireRecurring(c, series) {
+		return
+	}
+
+	notifications := app.notifications()
+	notifications.add(database.WebhookEventUpdated, series, nil)
+
+	err := app.models.Events.CancelOccurrence(c.Request.Context(), series, c.Param("date"), notifications.enqueue)
+	if errors.Is(err, database.ErrNoOccurrence) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The event does not occur on this date"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove occurrence"})
+		return
+	}
+
+	notifications.send()
+
+	c.JSON(http.StatusNoContent, nil)
+}
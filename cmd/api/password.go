@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// ForgotPassword sends a password reset token
+//
+//	@Summary		Requests a password reset
+//	@Description	Emails a single-use password reset token to the user. The response is the same whether the email is registered or not.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			user	body	forgotPasswordRequest	true	"User"
+//	@Success		202
+//	@Router			/api/v1/auth/forgot-password [post]
+func (app *application) forgotPassword(c *gin.Context) {
+	var request forgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accepted := gin.H{"message": "If the email is registered, a reset token is on its way"}
+
+	user, err := app.models.Users.GetByEmail(c.Request.Context(), request.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	token, hash, err := generateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	err = app.models.Tokens.Insert(c.Request.Context(), user.Id, hash, database.ScopePasswordReset, time.Now().Add(app.passwordResetTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	app.background(func() {
+		body := fmt.Sprintf(
+			"Hi %s,\n\nuse the following token to reset your password:\n\n%s\n\nThe token expires in %s and can only be used once.\nIf you did not ask for a new password you can ignore this email.",
+			user.Name, token, app.passwordResetTTL,
+		)
+
+		if err := app.mailer.Send(user.Email, "Reset your EventApp password", body); err != nil {
+			log.Print(err)
+		}
+	})
+
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+// ResetPassword sets a new password
+//
+//	@Summary		Resets the password
+//	@Description	Consumes a password reset token and sets a new password. All sessions of the user are logged out.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			reset	body	resetPasswordRequest	true	"Reset token and new password"
+//	@Success		204
+//	@Router			/api/v1/auth/reset-password [post]
+func (app *application) resetPassword(c *gin.Context) {
+	var request resetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	userId, err := app.models.Tokens.Consume(c.Request.Context(), hashToken(request.Token), database.ScopePasswordReset)
+	if errors.Is(err, database.ErrInvalidToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	if err := app.models.Users.UpdatePassword(c.Request.Context(), userId, string(hashedPassword)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	if err := app.models.Tokens.DeleteAllForUser(c.Request.Context(), userId, database.ScopePasswordReset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	if err := app.models.Sessions.RevokeAllForUser(c.Request.Context(), userId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	app.recheckAccess(userId)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+/*
+The password reset works in two steps. forgotPassword creates a random
+token, stores its hash and emails the plain token to the user. The
+response never tells whether an account exists for the email address.
+resetPassword redeems the token and stores the new bcrypt hash. Once the
+password is changed every other reset token and every session of the user
+is invalidated, so whoever knew the old password is logged out, and
+recheckAccess closes the chats and streams they still have open.
+*/
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type eventAction string
+
+const (
+	actionUpdateEvent       eventAction = "update this event"
+	actionDeleteEvent       eventAction = "delete this event"
+	actionManageAttendees   eventAction = "manage the attendees of this event"
+	actionViewRSVPs         eventAction = "see the RSVPs of this event"
+	actionViewEvent         eventAction = "see this event"
+	actionChangeStatus      eventAction = "change the status of this event"
+	actionManageInvites     eventAction = "manage the invites of this event"
+	actionViewMembers       eventAction = "see the members of this event"
+	actionManageMembers     eventAction = "manage the members of this event"
+	actionTransferOwnership eventAction = "transfer this event"
+	actionCheckIn           eventAction = "check in the attendees of this event"
+	actionManageWebhooks    eventAction = "manage the webhooks of this event"
+	actionModerateChat      eventAction = "moderate the chat of this event"
+	actionModerateComments  eventAction = "moderate the comments of this event"
+)
+
+func hasRole(user *database.User, roles ...string) bool {
+	return slices.Contains(roles, user.Role)
+}
+
+func isOwner(user *database.User, event *database.Event) bool {
+	return user.Id != 0 && event.OwnerId == user.Id
+}
+
+func isMember(member string, roles ...string) bool {
+	if len(roles) == 0 {
+		return member != ""
+	}
+	return slices.Contains(roles, member)
+}
+
+// hasRole checks the role of the user in the app, isMember the role the
+// user has in the event, any role if none is given.
+
+var eventPolicies = map[eventAction]func(user *database.User, member string, event *database.Event) bool{
+	actionUpdateEvent: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member, database.MemberCoHost) || hasRole(user, database.RoleModerator, database.RoleAdmin)
+	},
+	actionDeleteEvent: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || hasRole(user, database.RoleModerator, database.RoleAdmin)
+	},
+	actionManageAttendees: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member, database.MemberCoHost) || hasRole(user, database.RoleAdmin)
+	},
+	actionViewRSVPs: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member) || hasRole(user, database.RoleAdmin)
+	},
+	actionViewEvent: func(user *database.User, member string, event *database.Event) bool {
+		if isOwner(user, event) || isMember(member) || hasRole(user, database.RoleModerator, database.RoleAdmin) {
+			return true
+		}
+		return event.Status != database.EventDraft && event.Visibility != database.VisibilityPrivate
+	},
+	actionChangeStatus: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member, database.MemberCoHost) || hasRole(user, database.RoleModerator, database.RoleAdmin)
+	},
+	actionManageInvites: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member, database.MemberCoHost) || hasRole(user, database.RoleAdmin)
+	},
+	actionViewMembers: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member) || hasRole(user, database.RoleModerator, database.RoleAdmin)
+	},
+	actionManageMembers: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || hasRole(user, database.RoleAdmin)
+	},
+	actionTransferOwnership: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || hasRole(user, database.RoleAdmin)
+	},
+	actionCheckIn: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member, database.MemberCoHost, database.MemberCheckIn) || hasRole(user, database.RoleAdmin)
+	},
+	actionManageWebhooks: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member, database.MemberCoHost) || hasRole(user, database.RoleAdmin)
+	},
+	actionModerateChat: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member, database.MemberCoHost) || hasRole(user, database.RoleModerator, database.RoleAdmin)
+	},
+	actionModerateComments: func(user *database.User, member string, event *database.Event) bool {
+		return isOwner(user, event) || isMember(member, database.MemberCoHost) || hasRole(user, database.RoleModerator, database.RoleAdmin)
+	},
+}
+
+/*
+eventPolicies is the single place that decides who may do what with an event.
+The owner may do everything with their own event, moderators may edit and
+remove any event to keep content clean, and admins may do everything.
+member is the role the user has in the event, see database.Member.
+Co-hosts may do what the owner does except deleting the event, managing
+its members and giving it away. Every member may see the event and its
+RSVPs, check-in staff may only check attendees in and viewers may not
+change anything. Moderators may also keep the chats and comments of all events clean.
+Everybody may see an event once it is no longer a draft, unless it is
+private. Who else may see a private event depends on the database, that is
+decided by visibleTo.
+*/
+
+func (app *application) can(user *database.User, member string, action eventAction, event *database.Event) bool {
+	policy, ok := eventPolicies[action]
+	return ok && policy(user, member, event)
+}
+
+func (app *application) authorizeEvent(c *gin.Context, action eventAction) (*database.Event, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil, false
+	}
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return nil, false
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+
+	member, _, ok := app.viewEvent(c, event)
+	if !ok {
+		return nil, false
+	}
+
+	if !app.can(app.GetUserFromContext(c), member, action, event) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to " + string(action)})
+		return nil, false
+	}
+
+	return event, true
+}
+
+/*
+authorizeEvent loads the event from the :id parameter and checks that the
+current user may perform the action on it. If anything is wrong it writes
+the error response itself, so handlers only have to return when ok is false.
+A draft or private event the user may not see is answered with 404, like
+an event that does not exist, so they do not leak through the ids.
+*/
+
+func seriesIdOf(event *database.Event) int {
+	if event.SeriesId != nil {
+		return *event.SeriesId
+	}
+	return event.Id
+}
+
+// seriesIdOf returns the id of the series of an occurrence and the id of
+// any other event. Members, invites and sign ups of a series count for all
+// its occurrences, so they are looked up by this id.
+
+func (app *application) memberRole(c *gin.Context, event *database.Event) (string, error) {
+	user := app.GetUserFromContext(c)
+	if user.Id == 0 || isOwner(user, event) {
+		return "", nil
+	}
+	return app.models.Members.GetRole(c.Request.Context(), seriesIdOf(event), user.Id)
+}
+
+func (app *application) visibleTo(c *gin.Context, event *database.Event, member string) (bool, *database.Invite, error) {
+	user := app.GetUserFromContext(c)
+
+	if app.can(user, member, actionViewEvent, event) {
+		return true, nil, nil
+	}
+	if event.Status == database.EventDraft {
+		return false, nil, nil
+	}
+
+	if user.Id != 0 {
+		signedUp, err := app.models.Attendees.IsSignedUp(c.Request.Context(), seriesIdOf(event), user.Id)
+		if err != nil || signedUp {
+			return signedUp, nil, err
+		}
+	}
+
+	invite, err := app.inviteFromRequest(c, seriesIdOf(event))
+	if err != nil || invite == nil {
+		return false, nil, err
+	}
+
+	return true, invite, nil
+}
+
+/*
+visibleTo decides if the current user may see a private event: besides
+the owner, its members, moderators and admins, the users that signed up
+for it and requests that carry a valid invite for it in the invite query
+parameter. The invite is returned when it is the only reason the event is
+visible.
+*/
+
+func (app *application) listedTo(c *gin.Context, event *database.Event) (bool, error) {
+	if event.Status != database.EventDraft && event.Visibility == database.VisibilityPublic {
+		return true, nil
+	}
+
+	user := app.GetUserFromContext(c)
+	if isOwner(user, event) || hasRole(user, database.RoleModerator, database.RoleAdmin) {
+		return true, nil
+	}
+
+	member, err := app.memberRole(c, event)
+	return isMember(member), err
+}
+
+/*
+listedTo decides if an event shows up in a list for the current user, the
+same rule GetAll applies in SQL: public events once they are no longer
+drafts, and every event the user owns or is a member of. Moderators and
+admins see all of them, like with ShowHidden. Unlike visibleTo it does not
+count sign ups and invites, an unlisted or private event is only found
+through its link.
+*/
+
+func (app *application) viewEvent(c *gin.Context, event *database.Event) (string, *database.Invite, bool) {
+	member, err := app.memberRole(c, event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return "", nil, false
+	}
+
+	visible, invite, err := app.visibleTo(c, event, member)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return "", nil, false
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return "", nil, false
+	}
+
+	return member, invite, true
+}
+
+func (app *application) requireView(c *gin.Context, event *database.Event) bool {
+	_, _, ok := app.viewEvent(c, event)
+	return ok
+}
+
+// viewEvent answers 404 if the current user may not see the event and
+// otherwise returns the role of the user in the event and the invite that
+// made it visible, if any. requireView is used by every handler that
+// returns an event or something about it.
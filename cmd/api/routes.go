@@ -3,6 +3,8 @@ package main
 import (
 	"net/http"
 
+	"github.com/schlafer/EventApp/internal/database"
+
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -10,27 +12,113 @@ import (
 
 func (app *application) routes() http.Handler {
 
-	g := gin.Default()
+	g := gin.New()
+	g.Use(redactAccessToken(), gin.Logger(), gin.Recovery())
 	v1 := g.Group("/api/v1")
+
+	publicGroup := v1.Group("/")
+	publicGroup.Use(app.OptionalAuthMiddleware())
 	{
-		v1.GET("/events", app.getAllEvents)
-		v1.GET("/events/:id", app.getEvent)
-		v1.GET("/events/:id/attendees", app.getAttendeesForEvent)
-		v1.GET("/attendees/:id/events", app.getEventsByAttendee)
+		publicGroup.GET("/events", app.getAllEvents)
+		publicGroup.GET("/events/search", app.searchEvents)
+		publicGroup.GET("/events/stream", app.streamEvents)
+		publicGroup.GET("/events/:id", app.getEvent)
+		publicGroup.GET("/events/:id/attendees", app.getAttendeesForEvent)
+		publicGroup.GET("/events/:id/waitlist", app.getWaitlistForEvent)
+		publicGroup.GET("/events/:id/occurrences", app.getOccurrences)
+		publicGroup.GET("/events/:id/stream", app.streamEvent)
+		publicGroup.GET("/events/:id/comments", app.getComments)
+		publicGroup.GET("/attendees/:id/events", app.getEventsByAttendee)
+	}
+
+	{
+		v1.GET("/calendar/:token", app.getCalendarFeed)
 
 		v1.POST("/register", app.registerUser)
 		v1.POST("/login", app.login)
+		v1.POST("/auth/refresh", app.refresh)
+		v1.POST("/auth/forgot-password", app.forgotPassword)
+		v1.POST("/auth/reset-password", app.resetPassword)
+		v1.POST("/auth/verify-email", app.verifyEmail)
+		v1.POST("/auth/resend-verification", app.resendVerification)
 	}
 
 	authGroup := v1.Group("/")
 	authGroup.Use(app.AuthMiddleware())
+	if app.emailVerification == verificationAll {
+		authGroup.Use(app.RequireVerifiedEmail())
+	}
+
+	createEventHandlers := []gin.HandlerFunc{app.createEvent}
+	importEventsHandlers := []gin.HandlerFunc{app.importEvents}
+	if app.emailVerification == verificationEvents {
+		createEventHandlers = append([]gin.HandlerFunc{app.RequireVerifiedEmail()}, createEventHandlers...)
+		importEventsHandlers = append([]gin.HandlerFunc{app.RequireVerifiedEmail()}, importEventsHandlers...)
+	}
+
 	{
-		authGroup.POST("/events", app.createEvent)
+		authGroup.POST("/auth/logout", app.logout)
+		authGroup.POST("/auth/logout-all", app.logoutAll)
+
+		authGroup.POST("/events", createEventHandlers...)
+		authGroup.POST("/events/import", importEventsHandlers...)
 		authGroup.PUT("/events/:id", app.updateEvent)
+		authGroup.PATCH("/events/:id", app.patchEvent)
 		authGroup.DELETE("/events/:id", app.deleteEvent)
+		authGroup.POST("/events/:id/publish", app.publishEvent)
+		authGroup.POST("/events/:id/cancel", app.cancelEvent)
+		authGroup.POST("/events/:id/complete", app.completeEvent)
+		authGroup.POST("/events/:id/invites", app.createInvite)
+		authGroup.GET("/events/:id/invites", app.getInvites)
+		authGroup.DELETE("/events/:id/invites/:inviteId", app.deleteInvite)
+		authGroup.GET("/events/:id/members", app.getMembers)
+		authGroup.POST("/events/:id/members", app.addMember)
+		authGroup.PUT("/events/:id/members/:userId", app.updateMember)
+		authGroup.DELETE("/events/:id/members/:userId", app.removeMember)
+		authGroup.POST("/events/:id/transfer", app.transferOwnership)
 		authGroup.POST("/events/:id/attendees/:userId", app.addAttendeeToEvent)
 		authGroup.DELETE("/events/:id/attendees/:userId", app.deleteAttendeeFromEvent)
+		authGroup.POST("/events/:id/rsvp", app.rsvpToEvent)
+		authGroup.DELETE("/events/:id/rsvp", app.cancelRSVP)
+		authGroup.GET("/events/:id/rsvps", app.getRSVPsForEvent)
+		authGroup.GET("/events/:id/ticket", app.getTicket)
+		authGroup.GET("/events/:id/ticket.png", app.getTicketQR)
+		authGroup.POST("/events/:id/check-in", app.checkIn)
+		authGroup.GET("/events/:id/check-ins", app.getCheckIns)
+		authGroup.POST("/events/:id/webhooks", app.createEventWebhook)
+		authGroup.GET("/events/:id/webhooks", app.getEventWebhooks)
+		authGroup.GET("/events/:id/chat", app.joinChat)
+		authGroup.GET("/events/:id/chat/messages", app.getChatMessages)
+		authGroup.DELETE("/events/:id/chat/messages/:messageId", app.deleteChatMessage)
+		authGroup.GET("/events/:id/chat/mutes", app.getChatMutes)
+		authGroup.POST("/events/:id/chat/mutes", app.muteChatUser)
+		authGroup.DELETE("/events/:id/chat/mutes/:userId", app.unmuteChatUser)
+		authGroup.POST("/events/:id/comments", app.createComment)
+		authGroup.PATCH("/events/:id/comments/:commentId", app.updateComment)
+		authGroup.DELETE("/events/:id/comments/:commentId", app.deleteComment)
+		authGroup.POST("/events/:id/occurrences/:date", app.createOccurrence)
+		authGroup.PUT("/events/:id/occurrences/:date", app.updateOccurrence)
+		authGroup.DELETE("/events/:id/occurrences/:date", app.deleteOccurrence)
+		authGroup.POST("/calendar/feed", app.createCalendarFeed)
+		authGroup.DELETE("/calendar/feed", app.deleteCalendarFeed)
+		authGroup.POST("/webhooks", app.createWebhook)
+		authGroup.GET("/webhooks", app.getWebhooks)
+		authGroup.GET("/webhooks/:id", app.getWebhook)
+		authGroup.DELETE("/webhooks/:id", app.deleteWebhook)
+		authGroup.GET("/webhooks/:id/deliveries", app.getWebhookDeliveries)
 	}
+	adminGroup := authGroup.Group("/admin")
+	adminGroup.Use(app.RequireRole(database.RoleModerator, database.RoleAdmin))
+	{
+		adminGroup.GET("/users", app.getAllUsers)
+		adminGroup.POST("/users/:id/suspend", app.suspendUser)
+		adminGroup.DELETE("/users/:id/suspend", app.unsuspendUser)
+		adminGroup.PUT("/users/:id/role", app.RequireRole(database.RoleAdmin), app.setUserRole)
+		adminGroup.DELETE("/users/:id", app.RequireRole(database.RoleAdmin), app.deleteUser)
+		adminGroup.GET("/events", app.getAllEvents)
+		adminGroup.DELETE("/events/:id", app.deleteEvent)
+	}
+
 	g.GET("/swagger/*any", func(c *gin.Context) {
 		if c.Request.RequestURI == "/swagger/" {
 			c.Redirect(302, "/swagger/index.html")
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rsvpRequest struct {
+	Status string `json:"status" binding:"required,oneof=going maybe declined"`
+}
+
+type rsvpSummaryResponse struct {
+	Going      int                       `json:"going"`
+	Maybe      int                       `json:"maybe"`
+	Declined   int                       `json:"declined"`
+	Waitlisted int                       `json:"waitlisted"`
+	RSVPs      []*database.RSVP          `json:"rsvps"`
+	Waitlist   []*database.WaitlistEntry `json:"waitlist"`
+}
+
+// RSVPToEvent sets the RSVP of the current user
+//
+//	@Summary		RSVPs to an event
+//	@Description	Sets the answer of the current user to going, maybe or declined. If the event is full, going puts the user on the waitlist. The response carries the signed ticket of the attendee. To sign up for a private event, pass the token of an invite link; it is used once unless the user had already signed up, and only if the RSVP is saved.
+//	@Tags			rsvp
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Event ID"
+//	@Param			rsvp	body		rsvpRequest	true	"RSVP"
+//	@Param			invite	query		string		false	"Invite token of a private event"
+//	@Success		200		{object}	database.Attendee	"The RSVP of the user was changed"
+//	@Success		201		{object}	database.Attendee	"The user answered for the first time"
+//	@Success		202		{object}	database.WaitlistEntry	"The event is full, the user was put on the waitlist"
+//	@Router			/api/v1/events/{id}/rsvp [post]
+//	@Security		BearerAuth
+func (app *application) rsvpToEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var request rsvpRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	_, invite, ok := app.viewEvent(c, event)
+	if !ok {
+		return
+	}
+
+	if event.IsRecurring() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "RSVPs are given to a single occurrence of a recurring event"})
+		return
+	}
+
+	if !app.requireOpen(c, event) {
+		return
+	}
+
+	inviteId := 0
+	if invite != nil {
+		inviteId = invite.Id
+	}
+
+	user := app.GetUserFromContext(c)
+
+	previous, err := app.models.Attendees.GetByEventAndAttendee(c.Request.Context(), event.Id, user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save RSVP"})
+		return
+	}
+
+	notifications := app.notifications()
+
+	attendee, entry, err := app.models.Attendees.SetStatus(c.Request.Context(), event.Id, user.Id, request.Status, inviteId,
+		notifications.enqueueRSVPs(event))
+	if errors.Is(err, database.ErrInvalidToken) {
+		c.JSON(http.StatusGone, gin.H{"error": "The invite link has expired or was used up"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save RSVP"})
+		return
+	}
+
+	if entry != nil {
+		c.JSON(http.StatusAccepted, entry)
+		return
+	}
+
+	notifications.send()
+	if attendee.Status == database.StatusDeclined {
+		app.recheckAccess(user.Id)
+	}
+
+	if previous == nil {
+		c.JSON(http.StatusCreated, app.withTicket(attendee))
+		return
+	}
+
+	c.JSON(http.StatusOK, app.withTicket(attendee))
+}
+
+// CancelRSVP removes the RSVP of the current user
+//
+//	@Summary		Cancels the RSVP to an event
+//	@Description	Removes the current user from the attendees and the waitlist of an event
+//	@Tags			rsvp
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"Event ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/rsvp [delete]
+//	@Security		BearerAuth
+func (app *application) cancelRSVP(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	notifications := app.notifications()
+
+	_, err = app.models.Attendees.Delete(c.Request.Context(), user.Id, event.Id, notifications.enqueueRSVPs(event))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel RSVP"})
+		return
+	}
+
+	notifications.send()
+	app.recheckAccess(user.Id)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetRSVPsForEvent returns the RSVP breakdown of an event
+//
+//	@Summary		Returns the RSVP breakdown of an event
+//	@Description	Returns the number of users per RSVP status, every answer and the waitlist. Only the event owner and admins can see it.
+//	@Tags			rsvp
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	rsvpSummaryResponse
+//	@Router			/api/v1/events/{id}/rsvps [get]
+//	@Security		BearerAuth
+func (app *application) getRSVPsForEvent(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionViewRSVPs)
+	if !ok {
+		return
+	}
+
+	rsvps, err := app.models.Attendees.GetRSVPsByEvent(c.Request.Context(), event.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive RSVPs"})
+		return
+	}
+
+	waitlist, err := app.models.Waitlist.GetByEvent(c.Request.Context(), event.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive waitlist"})
+		return
+	}
+
+	summary := rsvpSummaryResponse{
+		Waitlisted: len(waitlist),
+		RSVPs:      rsvps,
+		Waitlist:   waitlist,
+	}
+
+	for _, rsvp := range rsvps {
+		switch rsvp.Status {
+		case database.StatusGoing:
+			summary.Going++
+		case database.StatusMaybe:
+			summary.Maybe++
+		case database.StatusDeclined:
+			summary.Declined++
+		}
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+/*
+These handlers let a logged in user answer an invitation themselves
+instead of relying on the event owner to add them.
+The acting user is always taken from the context, so a user can only
+change their own RSVP. The breakdown is reserved for the event owner
+and admins because it contains the email addresses of the attendees.
+*/
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -16,15 +21,79 @@ func serve(app *application) error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	server.RegisterOnShutdown(app.hub.Close)
+	server.RegisterOnShutdown(app.chat.Close)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	app.startWorkers(workersCtx)
+
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		log.Printf("Caught signal %s, shutting down server (timeout %s)", s, app.shutdownTimeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.shutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			shutdownError <- err
+			return
+		}
+
+		log.Print("Stopped accepting requests, waiting for background tasks")
+		stopWorkers()
+
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			shutdownError <- nil
+		case <-ctx.Done():
+			shutdownError <- errors.New("timed out waiting for background tasks")
+		}
+	}()
+
 	log.Printf("Starting server on port %d", app.port)
 
-	return server.ListenAndServe()
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-shutdownError; err != nil {
+		return err
+	}
+
+	log.Print("Stopped server")
+
+	return nil
 }
 
 /*
 The serve function sets up an HTTP server with specific configurations
 like address, handler, and timeouts.
 It uses the routes function to get the handler (Gin instance) for the server.
-The server is started with ListenAndServe,
-if there is an error it will log the error and exit the program.
+Before the server starts we launch the background workers.
+
+A goroutine waits for SIGINT or SIGTERM. When one arrives, Shutdown stops
+accepting new connections and waits for in-flight requests to finish.
+Event streams and chat connections never finish on their own, so Shutdown
+closes both hubs first, which ends them.
+Then the workers are cancelled and we wait for every background task,
+for example emails that are still being sent. All of this has to fit into
+the SHUTDOWN_TIMEOUT, after that we give up and return an error.
+
+ListenAndServe returns http.ErrServerClosed as soon as Shutdown is called,
+so we wait for the result of the shutdown goroutine before returning.
+Only then does main return and close the database connection.
 */
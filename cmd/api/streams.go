@@ -0,0 +1,237 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+	"github.com/schlafer/EventApp/internal/hub"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	streamRetry     = 3000
+	streamHistory   = 1000
+	streamBuffer    = 64
+)
+
+func (app *application) publish(kind string, event *database.Event, data gin.H) {
+	payload, err := notificationPayload(kind, event, data)
+	if err != nil {
+		log.Printf("Failed to publish %s of event %d: %v", kind, event.Id, err)
+		return
+	}
+
+	message := hub.Message{
+		Type:    kind,
+		EventId: event.Id,
+		Public:  event.Status != database.EventDraft && event.Visibility == database.VisibilityPublic,
+		Data:    payload,
+	}
+	if event.SeriesId != nil {
+		message.SeriesId = *event.SeriesId
+	}
+
+	app.hub.Publish(message)
+}
+
+/*
+publish hands a change to the streams, with the same payload the webhooks
+get. Whether the event is public is decided from the event as it is after
+the change, the global stream only carries public events.
+*/
+
+func (app *application) stream(c *gin.Context, filter func(hub.Message) bool, revoked func(hub.Message) bool) {
+	subscription, missed, resumed := app.hub.Subscribe(filter, c.GetHeader("Last-Event-ID"))
+	defer app.hub.Unsubscribe(subscription)
+
+	var rechecks <-chan hub.Message
+	if user := app.GetUserFromContext(c); revoked != nil && user.Id != 0 {
+		recheck, _, _ := app.chat.Subscribe(func(message hub.Message) bool {
+			return message.Type == chatRecheck && recheckedUser(message) == user.Id
+		}, "")
+		defer app.chat.Unsubscribe(recheck)
+		rechecks = recheck.Messages()
+	}
+
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to lift the write deadline of a stream: %v", err)
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.Render(-1, sse.Event{
+		Event: "ready",
+		Retry: streamRetry,
+		Data:  gin.H{"resumed": resumed, "missed": len(missed)},
+	})
+	for _, message := range missed {
+		c.Render(-1, sse.Event{Id: message.Id, Event: message.Type, Data: message.Data})
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case message, ok := <-subscription.Messages():
+			if !ok {
+				return
+			}
+			if revoked != nil && revoked(message) {
+				closeStream(c)
+				return
+			}
+			c.Render(-1, sse.Event{Id: message.Id, Event: message.Type, Data: message.Data})
+		case message, ok := <-rechecks:
+			if !ok {
+				return
+			}
+			if revoked(message) {
+				closeStream(c)
+				return
+			}
+			continue
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+/*
+stream serves the messages of the hub as Server-Sent Events until the
+client goes away or the server shuts down. The first event is always
+ready: resumed is false when the client sent a Last-Event-ID the hub
+cannot continue from, the client should then load the state again instead
+of trusting its copy. Every message carries its id, so EventSource sends
+the last one back when it reconnects and gets what it missed. A comment
+line every streamHeartbeat keeps proxies from closing an idle connection
+and lets the server notice clients that are gone. The server's write
+timeout does not apply to streams.
+
+revoked, when it is not nil, is asked about every message before it goes
+out and about every recheckAccess of the user, a stream it says true for
+ends with a revoked event. Without a user there is nothing to recheck but
+the messages.
+*/
+
+func closeStream(c *gin.Context) {
+	c.Render(-1, sse.Event{Event: "revoked", Data: gin.H{"error": "You can no longer see this event"}})
+	c.Writer.Flush()
+}
+
+// closeStream tells the client why its stream ends. EventSource reconnects
+// when the response ends, that request fails and it gives up.
+
+// StreamEvents streams the changes to all public events
+//
+//	@Summary		Streams the changes to all public events
+//	@Description	Server-Sent Events stream of event.created, event.updated, event.cancelled, event.deleted, attendee.joined and attendee.left for every public event. The data of each event is the same JSON a webhook gets. Reconnect with Last-Event-ID to get the events that were missed; if the first event, ready, says resumed is false, they could not be told and the state has to be loaded again.
+//	@Tags			streams
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		string	false	"Id of the last event the client received"
+//	@Success		200				{string}	string	"Stream of events"
+//	@Router			/api/v1/events/stream [get]
+func (app *application) streamEvents(c *gin.Context) {
+	app.stream(c, func(message hub.Message) bool {
+		return message.Public
+	}, nil)
+}
+
+// StreamEvent streams the changes to a single event
+//
+//	@Summary		Streams the changes to a single event
+//	@Description	Like /events/stream, but for one event and its occurrences, whatever its visibility, for everybody who may see the event. Access is checked again when the event is updated and when the RSVP, the member role or the account of the user changes; a stream that lost its access ends with a revoked event.
+//	@Tags			streams
+//	@Produce		text/event-stream
+//	@Param			id				path		int		true	"Event ID"
+//	@Param			invite			query		string	false	"Invite token of a private event"
+//	@Param			Last-Event-ID	header		string	false	"Id of the last event the client received"
+//	@Success		200				{string}	string	"Stream of events"
+//	@Router			/api/v1/events/{id}/stream [get]
+func (app *application) streamEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.requireView(c, event) {
+		return
+	}
+
+	app.stream(c, func(message hub.Message) bool {
+		return message.EventId == event.Id || message.SeriesId == event.Id
+	}, func(message hub.Message) bool {
+		if message.Type != chatRecheck && (message.Type != database.WebhookEventUpdated || message.EventId != event.Id) {
+			return false
+		}
+		visible, err := app.stillVisible(c, event.Id)
+		return err == nil && !visible
+	})
+}
+
+/*
+Access to an event is checked when the stream is opened, like a GET of the
+event, and again with stillVisible when the event was updated, its
+visibility may have changed, or when recheckAccess says that the user's
+RSVP, member role, account or sessions changed. The update that hides the event is
+not sent to a stream that may no longer see it.
+*/
+
+func (app *application) stillVisible(c *gin.Context, eventId int) (bool, error) {
+	ctx := c.Request.Context()
+
+	event, err := app.models.Events.Get(ctx, eventId)
+	if err != nil || event == nil {
+		return false, err
+	}
+
+	if user := app.GetUserFromContext(c); user.Id != 0 {
+		session, err := app.models.Sessions.Get(ctx, app.GetSessionIdFromContext(c))
+		if err != nil || session == nil || !session.Active() || session.UserId != user.Id {
+			return false, err
+		}
+
+		user, err := app.models.Users.Get(ctx, user.Id)
+		if err != nil || user == nil || user.SuspendedAt != nil {
+			return false, err
+		}
+		c.Set("user", user)
+	}
+
+	member, err := app.memberRole(c, event)
+	if err != nil {
+		return false, err
+	}
+
+	visible, _, err := app.visibleTo(c, event, member)
+	return visible, err
+}
+
+// stillVisible loads the event, the session and the user of the stream
+// again, the ones of the request may be long out of date, and keeps the
+// fresh user in the context for visibleTo. A stream whose session was
+// revoked by a logout ends like one that may no longer see the event.
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+)
+
+func (s *testServer) openStream(t *testing.T, eventId int, token string) <-chan string {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/events/%d/stream", s.URL, eventId), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := s.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("opening the stream = %s", response.Status)
+	}
+
+	events := make(chan string, streamBuffer)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), "event:"); ok {
+				events <- strings.TrimSpace(name)
+			}
+		}
+	}()
+
+	expectStream(t, events, "ready")
+	return events
+}
+
+// openStream opens the stream of an event and returns the names of the
+// events it sends, the channel is closed when the stream ends.
+
+func expectStream(t *testing.T, events <-chan string, name string) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got, ok := <-events:
+			if !ok {
+				t.Fatalf("stream ended, want %s", name)
+			}
+			if got != name {
+				continue
+			}
+			if name != "revoked" {
+				return
+			}
+			if _, ok := <-events; ok {
+				t.Fatal("stream goes on after revoked")
+			}
+			return
+		case <-timeout:
+			t.Fatalf("stream sent no %s", name)
+		}
+	}
+}
+
+// expectStream waits for the next event called name, skipping the others,
+// and after a revoked event for the end of the stream.
+
+func TestStreamRechecksAccess(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		path := fmt.Sprintf("/api/v1/events/%d", eventId)
+
+		_, attendeeToken := server.newUser(t, "Attendee")
+		if response := server.do(t, http.MethodPost, path+"/rsvp", attendeeToken, `{"status": "going"}`); response.StatusCode != http.StatusCreated {
+			t.Fatalf("RSVP = %s", response.Status)
+		}
+		member, memberToken := server.newUser(t, "Member")
+		server.addMember(t, ownerToken, eventId, member, database.MemberViewer)
+		_, strangerToken := server.newUser(t, "Stranger")
+
+		streams := map[string]<-chan string{
+			"attendee": server.openStream(t, eventId, attendeeToken),
+			"member":   server.openStream(t, eventId, memberToken),
+			"stranger": server.openStream(t, eventId, strangerToken),
+		}
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			token  string
+			body   string
+			status int
+			events map[string]string
+		}{
+			{"the event becomes private", http.MethodPatch, path, ownerToken, `{"visibility": "private"}`, http.StatusOK,
+				map[string]string{"attendee": database.WebhookEventUpdated, "member": database.WebhookEventUpdated, "stranger": "revoked"}},
+			{"the attendee cancels", http.MethodDelete, path + "/rsvp", attendeeToken, "", http.StatusNoContent,
+				map[string]string{"attendee": "revoked"}},
+			{"the event is updated", http.MethodPatch, path, ownerToken, `{"location": "Hamburg"}`, http.StatusOK,
+				map[string]string{"member": database.WebhookEventUpdated}},
+			{"the member is removed", http.MethodDelete, fmt.Sprintf("%s/members/%d", path, member.Id), ownerToken, "", http.StatusNoContent,
+				map[string]string{"member": "revoked"}},
+		}
+
+		for _, tc := range tests {
+			if response := server.do(t, tc.method, tc.path, tc.token, tc.body); response.StatusCode != tc.status {
+				t.Fatalf("%s = %s, want %d", tc.name, response.Status, tc.status)
+			}
+			for who, name := range tc.events {
+				expectStream(t, streams[who], name)
+			}
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+type checkInRequest struct {
+	Ticket string `json:"ticket" binding:"required"`
+}
+
+type checkInResponse struct {
+	UserId      int        `json:"userId"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	CheckedInAt *time.Time `json:"checkedInAt"`
+}
+
+type checkInSummaryResponse struct {
+	Going     int              `json:"going"`
+	CheckedIn int              `json:"checkedIn"`
+	Remaining int              `json:"remaining"`
+	Attendees []*database.RSVP `json:"attendees"`
+}
+
+func (app *application) signTicket(attendee *database.Attendee) string {
+	return app.signPayload("ticket", fmt.Sprintf("%d.%d.%d", attendee.EventId, attendee.Id, attendee.UserId))
+}
+
+func (app *application) parseTicket(token string) (int, int, int, bool) {
+	payload, ok := app.verifyPayload("ticket", token)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	var eventId, attendeeId, userId int
+	if _, err := fmt.Sscanf(payload, "%d.%d.%d", &eventId, &attendeeId, &userId); err != nil {
+		return 0, 0, 0, false
+	}
+
+	return eventId, attendeeId, userId, true
+}
+
+/*
+A ticket is the id of the event, of the attendee row and of the user,
+signed with signPayload like an invite. Every attendee row gets one as soon
+as it is created, nothing is stored for it. Cancelling the RSVP deletes
+the row, so the old ticket stops working, and signing up again issues a
+new one.
+*/
+
+func (app *application) withTicket(attendee *database.Attendee) *database.Attendee {
+	if attendee != nil {
+		attendee.Ticket = app.signTicket(attendee)
+	}
+	return attendee
+}
+
+func (app *application) ticketOfUser(c *gin.Context) (*database.Attendee, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return nil, false
+	}
+
+	user := app.GetUserFromContext(c)
+
+	attendee, err := app.models.Attendees.GetByEventAndAttendee(c.Request.Context(), id, user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive ticket"})
+		return nil, false
+	}
+	if attendee == nil || attendee.Status != database.StatusGoing {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have no ticket for this event"})
+		return nil, false
+	}
+
+	return app.withTicket(attendee), true
+}
+
+// ticketOfUser returns the attendee row of the current user with its
+// ticket, only attendees that are going need one to get in. Whoever signed
+// up for an event may see it, so the event itself is not loaded.
+
+// GetTicket returns the ticket of the current user
+//
+//	@Summary		Returns the ticket of the current user
+//	@Description	Returns the signed ticket of the current user for an event they are going to. Staff scan it at the entrance to check the user in.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	database.Attendee
+//	@Router			/api/v1/events/{id}/ticket [get]
+//	@Security		BearerAuth
+func (app *application) getTicket(c *gin.Context) {
+	attendee, ok := app.ticketOfUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, attendee)
+}
+
+// GetTicketQR returns the ticket of the current user as a QR code
+//
+//	@Summary		Returns the ticket of the current user as a QR code
+//	@Description	Returns the same ticket as /ticket, encoded as a QR code PNG to show at the entrance
+//	@Tags			tickets
+//	@Produce		png
+//	@Param			id		path		int	true	"Event ID"
+//	@Param			size	query		int	false	"Width and height in pixels, 256 by default"
+//	@Success		200		{file}		binary
+//	@Router			/api/v1/events/{id}/ticket.png [get]
+//	@Security		BearerAuth
+func (app *application) getTicketQR(c *gin.Context) {
+	attendee, ok := app.ticketOfUser(c)
+	if !ok {
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", "256"))
+	if err != nil || size < 64 || size > 1024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 64 and 1024"})
+		return
+	}
+
+	png, err := qrcode.Encode(attendee.Ticket, qrcode.Medium, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render ticket"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// The QR code holds nothing but the ticket, any scanner app can read it
+// and hand it to the check-in endpoint.
+
+// CheckIn checks an attendee in with their ticket
+//
+//	@Summary		Checks an attendee in with their ticket
+//	@Description	Validates the ticket and records when the attendee arrived. A ticket can be used once, scanning it again returns 409 with the time of the first check-in. The owner, co-hosts, check-in staff and admins can check attendees in.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Event ID"
+//	@Param			ticket	body		checkInRequest	true	"The scanned ticket"
+//	@Success		200		{object}	checkInResponse
+//	@Router			/api/v1/events/{id}/check-in [post]
+//	@Security		BearerAuth
+func (app *application) checkIn(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionCheckIn)
+	if !ok {
+		return
+	}
+
+	var request checkInRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	eventId, attendeeId, userId, ok := app.parseTicket(request.Ticket)
+	if !ok || eventId != event.Id {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The ticket is not valid for this event"})
+		return
+	}
+
+	if event.Status != database.EventPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "The event is " + event.Status + ", nobody can check in"})
+		return
+	}
+
+	user, err := app.models.Users.Get(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The ticket is not valid for this event"})
+		return
+	}
+
+	attendee, err := app.models.Attendees.CheckIn(c.Request.Context(), event.Id, attendeeId, userId)
+	switch {
+	case errors.Is(err, database.ErrAlreadyCheckedIn):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       user.Name + " is already checked in",
+			"checkedInAt": attendee.CheckedInAt,
+		})
+		return
+	case errors.Is(err, database.ErrNotGoing):
+		c.JSON(http.StatusConflict, gin.H{"error": user.Name + " is no longer going to this event"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+		return
+	case attendee == nil:
+		c.JSON(http.StatusNotFound, gin.H{"error": "The ticket is not valid for this event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, checkInResponse{
+		UserId:      user.Id,
+		Name:        user.Name,
+		Email:       user.Email,
+		CheckedInAt: attendee.CheckedInAt,
+	})
+}
+
+/*
+The signature is checked before the database is touched, so a forged or
+mistyped ticket never costs a query. A ticket of another event is refused
+even if the user also goes to this one, staff at the wrong entrance should
+notice. The response carries the name of the attendee so the staff can
+compare it with the person in front of them.
+*/
+
+// GetCheckIns returns the live check-in count of an event
+//
+//	@Summary		Returns the live check-in count of an event
+//	@Description	Returns how many attendees are going, how many of them already checked in and every attendee with the time they arrived. Poll it to follow the entrance.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	checkInSummaryResponse
+//	@Router			/api/v1/events/{id}/check-ins [get]
+//	@Security		BearerAuth
+func (app *application) getCheckIns(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionViewRSVPs)
+	if !ok {
+		return
+	}
+
+	attendees, err := app.models.Attendees.GetCheckIns(c.Request.Context(), event.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive check-ins"})
+		return
+	}
+
+	summary := checkInSummaryResponse{
+		Going:     len(attendees),
+		Attendees: attendees,
+	}
+	for _, attendee := range attendees {
+		if attendee.CheckedInAt != nil {
+			summary.CheckedIn++
+		}
+	}
+	summary.Remaining = summary.Going - summary.CheckedIn
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/schlafer/EventApp/internal/database"
+)
+
+func TestParseTicket(t *testing.T) {
+	app := &application{jwtSecret: "test-secret"}
+	other := &application{jwtSecret: "other-secret"}
+
+	ticket := app.signTicket(&database.Attendee{EventId: 1, Id: 2, UserId: 3})
+	payload, signature, _ := strings.Cut(ticket, ".2.3.")
+
+	tests := []struct {
+		name   string
+		ticket string
+		ok     bool
+	}{
+		{"a signed ticket", ticket, true},
+		{"another attendee", payload + ".5.3." + signature, false},
+		{"another event", strings.Replace(ticket, "1.", "4.", 1), false},
+		{"a changed signature", ticket[:len(ticket)-1] + "A", false},
+		{"no signature", "1.2.3", false},
+		{"signed with another secret", other.signTicket(&database.Attendee{EventId: 1, Id: 2, UserId: 3}), false},
+		{"an invite", app.signPayload("invite", "1.2.3"), false},
+		{"empty", "", false},
+	}
+
+	for _, tc := range tests {
+		eventId, attendeeId, userId, ok := app.parseTicket(tc.ticket)
+		if ok != tc.ok {
+			t.Errorf("parseTicket of %s = %v, want %v", tc.name, ok, tc.ok)
+		}
+		if ok && (eventId != 1 || attendeeId != 2 || userId != 3) {
+			t.Errorf("parseTicket of %s = %d, %d, %d, want 1, 2, 3", tc.name, eventId, attendeeId, userId)
+		}
+	}
+}
+
+func (s *testServer) ticket(t *testing.T, eventId int, token string) string {
+	t.Helper()
+
+	path := fmt.Sprintf("/api/v1/events/%d/rsvp", eventId)
+	if response := s.do(t, http.MethodPost, path, token, `{"status": "going"}`); response.StatusCode != http.StatusCreated {
+		t.Fatalf("RSVP = %s", response.Status)
+	}
+
+	response := s.do(t, http.MethodGet, fmt.Sprintf("/api/v1/events/%d/ticket", eventId), token, "")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("getting the ticket = %s", response.Status)
+	}
+	var attendee database.Attendee
+	decodeJSON(t, response, &attendee)
+	return attendee.Ticket
+}
+
+// ticket signs the user of token up for the event and returns their ticket.
+
+func TestCheckIn(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		otherEventId := server.newEvent(t, ownerToken, "")
+		staff, staffToken := server.newUser(t, "Staff")
+		server.addMember(t, ownerToken, eventId, staff, database.MemberCheckIn)
+
+		_, attendeeToken := server.newUser(t, "Attendee")
+		ticket := server.ticket(t, eventId, attendeeToken)
+		otherTicket := server.ticket(t, otherEventId, attendeeToken)
+
+		_, declinedToken := server.newUser(t, "Declined")
+		declinedTicket := server.ticket(t, eventId, declinedToken)
+		path := fmt.Sprintf("/api/v1/events/%d/rsvp", eventId)
+		if response := server.do(t, http.MethodPost, path, declinedToken, `{"status": "declined"}`); response.StatusCode != http.StatusOK {
+			t.Fatalf("declining = %s", response.Status)
+		}
+
+		checkIn := fmt.Sprintf("/api/v1/events/%d/check-in", eventId)
+		body := func(ticket string) string { return fmt.Sprintf(`{"ticket": %q}`, ticket) }
+
+		tests := []struct {
+			name   string
+			token  string
+			ticket string
+			status int
+		}{
+			{"the attendee checks themselves in", attendeeToken, ticket, http.StatusForbidden},
+			{"a forged ticket", staffToken, strings.Replace(ticket, ".", ".9", 1), http.StatusNotFound},
+			{"a ticket of another event", staffToken, otherTicket, http.StatusNotFound},
+			{"a declined attendee", staffToken, declinedTicket, http.StatusConflict},
+			{"the ticket", staffToken, ticket, http.StatusOK},
+			{"the ticket again", staffToken, ticket, http.StatusConflict},
+			{"the ticket again by the owner", ownerToken, ticket, http.StatusConflict},
+		}
+
+		for _, tc := range tests {
+			if response := server.do(t, http.MethodPost, checkIn, tc.token, body(tc.ticket)); response.StatusCode != tc.status {
+				t.Errorf("checking in %s = %s, want %d", tc.name, response.Status, tc.status)
+			}
+		}
+
+		response := server.do(t, http.MethodGet, fmt.Sprintf("/api/v1/events/%d/check-ins", eventId), staffToken, "")
+		var summary checkInSummaryResponse
+		decodeJSON(t, response, &summary)
+		if summary.Going != 1 || summary.CheckedIn != 1 || summary.Remaining != 0 {
+			t.Errorf("check-ins = %+v, want the one attendee checked in", summary)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func generateToken() (string, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(bytes)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+/*
+generateToken creates a random opaque token and returns it together with
+its SHA-256 hash. The plain token is handed to the client once, only the
+hash is stored, so we can look the token up without being able to
+reconstruct it from the database.
+*/
+
+func (app *application) signPayload(purpose, payload string) string {
+	mac := hmac.New(sha256.New, []byte(app.jwtSecret))
+	mac.Write([]byte(purpose + ":" + payload))
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (app *application) verifyPayload(purpose, token string) (string, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return "", false
+	}
+
+	payload := token[:i]
+	if !hmac.Equal([]byte(token), []byte(app.signPayload(purpose, payload))) {
+		return "", false
+	}
+
+	return payload, true
+}
+
+/*
+signPayload appends an HMAC of the payload to it, verifyPayload checks it
+and returns the payload. Such tokens are not secret, their content can be
+read, but nobody without the secret can make one up. The purpose is part
+of the signature, so a token signed for one purpose, like an invite, can
+never pass as another one, like a ticket.
+*/
+
+func (app *application) issueTokens(ctx context.Context, userId int) (*loginResponse, error) {
+	refreshToken, refreshHash, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := app.models.Sessions.Insert(ctx, userId, refreshHash, time.Now().Add(app.refreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.signAccessToken(userId, session.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(app.accessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (app *application) signAccessToken(userId, sessionId int) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": userId,
+		"sid":    sessionId,
+		"exp":    time.Now().Add(app.accessTokenTTL).Unix(),
+	})
+
+	return token.SignedString([]byte(app.jwtSecret))
+}
+
+/*
+issueTokens starts a new session for the user and returns a short-lived
+access token together with the refresh token of the session.
+The access token is a JWT that contains the user id and the session id (sid),
+the AuthMiddleware uses the sid to reject tokens of revoked sessions.
+*/
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	verificationOff    = "off"
+	verificationEvents = "events"
+	verificationAll    = "all"
+)
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type resendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+func (app *application) sendVerificationEmail(ctx context.Context, user *database.User) error {
+	token, hash, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Tokens.Insert(ctx, user.Id, hash, database.ScopeEmailVerification, time.Now().Add(app.verificationTTL))
+	if err != nil {
+		return err
+	}
+
+	app.background(func() {
+		body := fmt.Sprintf(
+			"Hi %s,\n\nwelcome to EventApp! Please confirm your email address with the following token:\n\n%s\n\nThe token expires in %s.",
+			user.Name, token, app.verificationTTL,
+		)
+
+		if err := app.mailer.Send(user.Email, "Confirm your EventApp email address", body); err != nil {
+			log.Print(err)
+		}
+	})
+
+	return nil
+}
+
+/*
+sendVerificationEmail creates a verification token for the user and
+emails it in the background. It is called after registration and when
+the user asks for a new token.
+*/
+
+// VerifyEmail confirms the email address of a user
+//
+//	@Summary		Verifies an email address
+//	@Description	Consumes the verification token that was emailed after registration
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	body	verifyEmailRequest	true	"Verification token"
+//	@Success		204
+//	@Router			/api/v1/auth/verify-email [post]
+func (app *application) verifyEmail(c *gin.Context) {
+	var request verifyEmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId, err := app.models.Tokens.Consume(c.Request.Context(), hashToken(request.Token), database.ScopeEmailVerification)
+	if errors.Is(err, database.ErrInvalidToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	if err := app.models.Users.MarkVerified(c.Request.Context(), userId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	if err := app.models.Tokens.DeleteAllForUser(c.Request.Context(), userId, database.ScopeEmailVerification); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ResendVerification sends a new verification token
+//
+//	@Summary		Resends the verification email
+//	@Description	Emails a new verification token if the address belongs to an unverified account. The response is the same in every case.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			user	body	resendVerificationRequest	true	"User"
+//	@Success		202
+//	@Router			/api/v1/auth/resend-verification [post]
+func (app *application) resendVerification(c *gin.Context) {
+	var request resendVerificationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(c.Request.Context(), request.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	if user != nil && user.VerifiedAt == nil {
+		if err := app.sendVerificationEmail(c.Request.Context(), user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account needs verification, a new token is on its way"})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/schlafer/EventApp/internal/database/dbtest"
+	"github.com/schlafer/EventApp/internal/mailer"
+)
+
+func TestEmailVerificationGating(t *testing.T) {
+	tests := []struct {
+		mode          string
+		createEvent   int
+		listWebhooks  int
+		verifiedEvent int
+	}{
+		{verificationOff, http.StatusCreated, http.StatusOK, http.StatusCreated},
+		{verificationEvents, http.StatusForbidden, http.StatusOK, http.StatusCreated},
+		{verificationAll, http.StatusForbidden, http.StatusForbidden, http.StatusCreated},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.mode, func(t *testing.T) {
+			dbtest.ForEachDialect(t, func(t *testing.T, db *sql.DB, dialect string) {
+				server := newTestServer(t, db, dialect, func(app *application) {
+					app.emailVerification = tc.mode
+				})
+
+				user, token := server.newUser(t, "Owner")
+				body := `{"name": "Go meetup", "description": "Talks about Go and databases", "location": "Berlin",
+					"startsAt": "2026-11-05T18:00:00Z", "endsAt": "2026-11-05T21:00:00Z"}`
+
+				if response := server.do(t, http.MethodPost, "/api/v1/events", token, body); response.StatusCode != tc.createEvent {
+					t.Errorf("creating an event unverified = %s, want %d", response.Status, tc.createEvent)
+				}
+				if response := server.do(t, http.MethodGet, "/api/v1/webhooks", token, ""); response.StatusCode != tc.listWebhooks {
+					t.Errorf("listing webhooks unverified = %s, want %d", response.Status, tc.listWebhooks)
+				}
+
+				if err := server.app.models.Users.MarkVerified(context.Background(), user.Id); err != nil {
+					t.Fatal(err)
+				}
+				if response := server.do(t, http.MethodPost, "/api/v1/events", token, body); response.StatusCode != tc.verifiedEvent {
+					t.Errorf("creating an event verified = %s, want %d", response.Status, tc.verifiedEvent)
+				}
+			})
+		})
+	}
+}
+
+var verificationToken = regexp.MustCompile(`token:\n\n(\S+)\n`)
+
+func TestRegistrationEmailsAVerificationToken(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		var mails bytes.Buffer
+		server.app.mailer = mailer.NewLogMailer(&mails)
+
+		response := server.do(t, http.MethodPost, "/api/v1/register", "",
+			`{"email": "new@example.com", "password": "password", "name": "New"}`)
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("registering = %s", response.Status)
+		}
+		server.app.wg.Wait()
+
+		match := verificationToken.FindStringSubmatch(mails.String())
+		if match == nil {
+			t.Fatalf("emails = %q, want a verification token", mails.String())
+		}
+
+		tests := []struct {
+			token  string
+			status int
+		}{
+			{"not-a-token", http.StatusBadRequest},
+			{match[1], http.StatusNoContent},
+			{match[1], http.StatusBadRequest},
+		}
+
+		for _, tc := range tests {
+			response := server.do(t, http.MethodPost, "/api/v1/auth/verify-email", "", `{"token": "`+tc.token+`"}`)
+			if response.StatusCode != tc.status {
+				t.Errorf("verifying with %q = %s, want %d", tc.token, response.Status, tc.status)
+			}
+		}
+
+		user, err := server.app.models.Users.GetByEmail(context.Background(), "new@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.VerifiedAt == nil {
+			t.Error("the user is not verified")
+		}
+	})
+}
+
+type failingMailer struct{}
+
+func (failingMailer) Send(to, subject, body string) error {
+	return errors.New("the mail server is down")
+}
+
+func TestRegistrationSucceedsWhenTheEmailFails(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		server.app.mailer = failingMailer{}
+
+		response := server.do(t, http.MethodPost, "/api/v1/register", "",
+			`{"email": "new@example.com", "password": "password", "name": "New"}`)
+		if response.StatusCode != http.StatusCreated {
+			t.Errorf("registering = %s, want 201", response.Status)
+		}
+	})
+}
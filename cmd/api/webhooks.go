@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	webhookMaxAttempts  = 10
+	webhookMaxBackoff   = 6 * time.Hour
+	webhookTimeout      = 10 * time.Second
+	webhookBatchSize    = 50
+	webhookLogLimit     = 100
+	webhookLogRetention = 30 * 24 * time.Hour
+)
+
+type createWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,http_url,max=2000" example:"https://example.com/hooks/eventapp"`
+	Events []string `json:"events" binding:"omitempty,dive,oneof=event.created event.updated event.cancelled event.deleted attendee.joined attendee.left"`
+}
+
+type webhookResponse struct {
+	*database.Webhook
+	Secret string `json:"secret"`
+}
+
+func notificationPayload(kind string, event *database.Event, data gin.H) ([]byte, error) {
+	if data == nil {
+		data = gin.H{}
+	}
+	data["event"] = event
+
+	return json.Marshal(gin.H{
+		"type":       kind,
+		"occurredAt": time.Now().UTC().Truncate(time.Second),
+		"data":       data,
+	})
+}
+
+type notification struct {
+	kind  string
+	event *database.Event
+	data  gin.H
+}
+
+type notifications struct {
+	app     *application
+	pending []notification
+}
+
+func (app *application) notifications() *notifications {
+	return &notifications{app: app}
+}
+
+func (n *notifications) add(kind string, event *database.Event, data gin.H) {
+	n.pending = append(n.pending, notification{kind: kind, event: event, data: data})
+}
+
+func (n *notifications) addRSVP(event *database.Event, userId int, before, after string) {
+	data := gin.H{"attendee": gin.H{"userId": userId, "status": after}}
+
+	switch {
+	case after == database.StatusGoing && before != database.StatusGoing:
+		n.add(database.WebhookAttendeeJoined, event, data)
+	case before == database.StatusGoing && after != database.StatusGoing:
+		n.add(database.WebhookAttendeeLeft, event, data)
+	}
+}
+
+/*
+notifications collects what a handler has to tell the webhooks and the
+open streams about a change. addRSVP reports an attendee as joined when
+they start going to the event and as left when they stop, whatever they
+answer instead. An attendee that is removed has the empty status afterwards.
+*/
+
+func (n *notifications) enqueue(ctx context.Context, tx *sql.Tx) error {
+	for _, pending := range n.pending {
+		payload, err := notificationPayload(pending.kind, pending.event, pending.data)
+		if err != nil {
+			return err
+		}
+
+		err = n.app.models.Webhooks.Enqueue(ctx, tx, &database.Notification{
+			Type:     pending.kind,
+			EventId:  pending.event.Id,
+			SeriesId: pending.event.SeriesId,
+			OwnerId:  pending.event.OwnerId,
+			Payload:  payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *notifications) enqueueRSVPs(event *database.Event) database.EnqueueRSVPs {
+	return func(ctx context.Context, tx *sql.Tx, changes []database.RSVPChange) error {
+		for _, change := range changes {
+			n.addRSVP(event, change.UserId, change.Before, change.After)
+		}
+		return n.enqueue(ctx, tx)
+	}
+}
+
+func (n *notifications) send() {
+	for _, pending := range n.pending {
+		n.app.publish(pending.kind, pending.event, pending.data)
+	}
+
+	if len(n.pending) > 0 {
+		n.app.wakeWebhooks()
+	}
+}
+
+/*
+enqueue is passed to the model that stores the change and writes the
+notifications to the webhook outbox inside its transaction, so they are
+stored together with the change or not at all, and a crash cannot lose
+them. If they cannot be written, the change is rolled back and the
+request fails. The payload is a snapshot of the event as it is at that
+moment, after the change. enqueueRSVPs does the same for attendees, it
+turns the RSVPChanges of the model into joined and left notifications.
+Once the change is committed the handler calls send, which passes the
+notifications to the open streams and wakes up the webhook worker so
+they go out right away.
+*/
+
+func (app *application) wakeWebhooks() {
+	select {
+	case app.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+/*
+Every webhook request carries an X-EventApp-Signature header of the form
+t=<unix time>,v1=<hex HMAC-SHA256>. The HMAC is computed with the secret of
+the webhook over the timestamp, a dot and the raw body. Receivers should
+compute it themselves, compare it in constant time and refuse timestamps
+that are too old, so a captured request cannot be replayed later.
+*/
+
+func webhookBackoff(attempts int, base time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxBackoff)
+}
+
+// webhookBackoff doubles the wait after every failed attempt, starting at
+// base and never waiting longer than webhookMaxBackoff.
+
+func (app *application) deliverWebhook(ctx context.Context, delivery *database.WebhookDelivery) {
+	webhook, err := app.models.Webhooks.Get(ctx, delivery.WebhookId)
+	if err != nil || webhook == nil {
+		return
+	}
+
+	claimed, err := app.models.Webhooks.Claim(ctx, delivery, time.Now().Add(2*webhookTimeout))
+	if err != nil || !claimed {
+		return
+	}
+
+	statusCode, err := app.postWebhook(ctx, webhook, delivery)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	delivery.ResponseStatus = nil
+	if statusCode != 0 {
+		delivery.ResponseStatus = &statusCode
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = database.DeliveryDelivered
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = database.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.LastError = err.Error()
+	default:
+		next := now.Add(webhookBackoff(delivery.Attempts, app.webhookRetryBase))
+		delivery.NextAttemptAt = &next
+		delivery.LastError = err.Error()
+	}
+
+	if err := app.models.Webhooks.SaveAttempt(ctx, delivery); err != nil {
+		log.Printf("Failed to save attempt of webhook delivery %d: %v", delivery.Id, err)
+	}
+}
+
+func (app *application) postWebhook(ctx context.Context, webhook *database.Webhook, delivery *database.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "EventApp-Webhooks/1.0")
+	request.Header.Set("X-EventApp-Event", delivery.Type)
+	request.Header.Set("X-EventApp-Delivery", strconv.Itoa(delivery.Id))
+	request.Header.Set("X-EventApp-Signature", signWebhook(webhook.Secret, time.Now().Unix(), delivery.Payload))
+
+	response, err := app.webhookClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
+
+/*
+deliverWebhook makes one attempt to send a delivery. Any 2xx answer counts
+as delivered, everything else, including timeouts, is retried with
+exponential backoff until webhookMaxAttempts attempts failed. The delivery
+id is sent along so receivers can drop a notification they already got;
+a delivery can arrive twice if the server crashed right after sending it.
+*/
+
+func (app *application) authorizeWebhook(c *gin.Context) (*database.Webhook, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook id"})
+		return nil, false
+	}
+
+	webhook, err := app.models.Webhooks.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive webhook"})
+		return nil, false
+	}
+
+	user := app.GetUserFromContext(c)
+	if webhook == nil || (webhook.UserId != user.Id && !hasRole(user, database.RoleAdmin)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// authorizeWebhook loads the webhook of the :id parameter, only the user
+// that created it and admins can see and delete it.
+
+func (app *application) insertWebhook(c *gin.Context, eventId *int) {
+	var request createWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(request.Events) == 0 {
+		request.Events = database.WebhookTypes
+	}
+
+	if err := checkWebhookURL(c.Request.Context(), request.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, _, err := generateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	webhook := &database.Webhook{
+		UserId:  app.GetUserFromContext(c).Id,
+		EventId: eventId,
+		URL:     request.URL,
+		Events:  request.Events,
+		Secret:  "whsec_" + secret,
+	}
+
+	if err := app.models.Webhooks.Insert(c.Request.Context(), webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhookResponse{Webhook: webhook, Secret: webhook.Secret})
+}
+
+// CreateWebhook subscribes to the events of the current user
+//
+//	@Summary		Subscribes to the events of the current user
+//	@Description	Sends the chosen notifications about every event the current user owns to url, all of them if events is empty. URLs whose host resolves to a loopback, link-local or private address are refused. The response contains the secret the requests are signed with, it is not shown again.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhook	body		createWebhookRequest	true	"Target URL and notifications"
+//	@Success		201		{object}	webhookResponse
+//	@Router			/api/v1/webhooks [post]
+//	@Security		BearerAuth
+func (app *application) createWebhook(c *gin.Context) {
+	app.insertWebhook(c, nil)
+}
+
+// CreateEventWebhook subscribes to a single event
+//
+//	@Summary		Subscribes to a single event
+//	@Description	Sends the chosen notifications about the event and its occurrences to url, all of them if events is empty. The owner, co-hosts and admins can subscribe. URLs whose host resolves to a loopback, link-local or private address are refused. The response contains the secret the requests are signed with, it is not shown again.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Event ID"
+//	@Param			webhook	body		createWebhookRequest	true	"Target URL and notifications"
+//	@Success		201		{object}	webhookResponse
+//	@Router			/api/v1/events/{id}/webhooks [post]
+//	@Security		BearerAuth
+func (app *application) createEventWebhook(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionManageWebhooks)
+	if !ok {
+		return
+	}
+
+	eventId := seriesIdOf(event)
+	app.insertWebhook(c, &eventId)
+}
+
+// GetWebhooks returns the webhooks of the current user
+//
+//	@Summary		Returns the webhooks of the current user
+//	@Description	Returns every webhook the current user created, the ones of single events included
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]database.Webhook
+//	@Router			/api/v1/webhooks [get]
+//	@Security		BearerAuth
+func (app *application) getWebhooks(c *gin.Context) {
+	webhooks, err := app.models.Webhooks.GetByUser(c.Request.Context(), app.GetUserFromContext(c).Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetEventWebhooks returns the webhooks of an event
+//
+//	@Summary		Returns the webhooks of an event
+//	@Description	Returns the webhooks that subscribed to this event, whoever created them
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	[]database.Webhook
+//	@Router			/api/v1/events/{id}/webhooks [get]
+//	@Security		BearerAuth
+func (app *application) getEventWebhooks(c *gin.Context) {
+	event, ok := app.authorizeEvent(c, actionManageWebhooks)
+	if !ok {
+		return
+	}
+
+	webhooks, err := app.models.Webhooks.GetByEvent(c.Request.Context(), seriesIdOf(event))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook returns a webhook
+//
+//	@Summary		Returns a webhook
+//	@Description	Only the user that created the webhook and admins can see it
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Webhook ID"
+//	@Success		200	{object}	database.Webhook
+//	@Router			/api/v1/webhooks/{id} [get]
+//	@Security		BearerAuth
+func (app *application) getWebhook(c *gin.Context) {
+	webhook, ok := app.authorizeWebhook(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook deletes a webhook
+//
+//	@Summary		Deletes a webhook
+//	@Description	Stops the notifications, deliveries that were not sent yet are dropped together with the delivery log
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"Webhook ID"
+//	@Success		204
+//	@Router			/api/v1/webhooks/{id} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteWebhook(c *gin.Context) {
+	webhook, ok := app.authorizeWebhook(c)
+	if !ok {
+		return
+	}
+
+	if _, err := app.models.Webhooks.Delete(c.Request.Context(), webhook.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook
+//
+//	@Summary		Returns the delivery log of a webhook
+//	@Description	Returns the last 100 deliveries of the webhook, newest first, with their status, the number of attempts, the last response and when the next attempt is due
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Webhook ID"
+//	@Success		200	{object}	[]database.WebhookDelivery
+//	@Router			/api/v1/webhooks/{id}/deliveries [get]
+//	@Security		BearerAuth
+func (app *application) getWebhookDeliveries(c *gin.Context) {
+	webhook, ok := app.authorizeWebhook(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := app.models.Webhooks.GetDeliveries(c.Request.Context(), webhook.Id, webhookLogLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/schlafer/EventApp/internal/database"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+
+	for _, tc := range tests {
+		if got := publicAddress(netip.MustParseAddr(tc.addr)); got != tc.public {
+			t.Errorf("publicAddress(%s) = %v, want %v", tc.addr, got, tc.public)
+		}
+	}
+}
+
+func TestCreateWebhookRefusesInternalTargets(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, token := server.newUser(t, "Owner")
+
+		tests := []struct {
+			url    string
+			status int
+		}{
+			{"http://127.0.0.1:8080/hooks", http.StatusBadRequest},
+			{"http://localhost/hooks", http.StatusBadRequest},
+			{"http://169.254.169.254/latest/meta-data/", http.StatusBadRequest},
+			{"http://10.0.0.1/hooks", http.StatusBadRequest},
+			{"http://[::1]/hooks", http.StatusBadRequest},
+			{"https://93.184.215.14/hooks", http.StatusCreated},
+		}
+
+		for _, tc := range tests {
+			response := server.do(t, http.MethodPost, "/api/v1/webhooks", token, `{"url": "`+tc.url+`"}`)
+			if response.StatusCode != tc.status {
+				t.Errorf("registering %s = %s, want %d", tc.url, response.Status, tc.status)
+			}
+		}
+	})
+}
+
+func TestWebhookClientRefusesInternalTargets(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the webhook reached a loopback address")
+	}))
+	defer receiver.Close()
+
+	response, err := newWebhookClient().Post(receiver.URL, "application/json", nil)
+	if err == nil {
+		response.Body.Close()
+	}
+	if !errors.Is(err, errWebhookTarget) {
+		t.Errorf("posting to %s = %v, want errWebhookTarget", receiver.URL, err)
+	}
+}
+
+// The receiver listens on 127.0.0.1 like a service that is only reachable
+// from the server, the client has to refuse it even though nothing checked
+// the URL before.
+
+func TestCancelEventNotifiesWebhooks(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, token := server.newUser(t, "Owner")
+
+		response := server.do(t, http.MethodPost, "/api/v1/webhooks", token,
+			`{"url": "https://93.184.215.14/hooks", "events": ["event.updated", "event.cancelled"]}`)
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("registering the webhook = %s", response.Status)
+		}
+		var webhook webhookResponse
+		decodeJSON(t, response, &webhook)
+
+		eventId := server.newEvent(t, token, "")
+		response = server.do(t, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/cancel", eventId), token, `{"reason": "Rain"}`)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("cancelling the event = %s", response.Status)
+		}
+
+		ctx := context.Background()
+		if _, err := server.app.models.Webhooks.Dispatch(ctx, webhookBatchSize); err != nil {
+			t.Fatal(err)
+		}
+		deliveries, err := server.app.models.Webhooks.GetDeliveries(ctx, webhook.Id, webhookLogLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 1 || deliveries[0].Type != database.WebhookEventCancelled {
+			t.Errorf("deliveries = %v, want one event.cancelled", deliveries)
+		}
+	})
+}
+
+func TestRemovedCoHostStopsReceivingWebhooks(t *testing.T) {
+	withTestServer(t, func(t *testing.T, server *testServer) {
+		_, ownerToken := server.newUser(t, "Owner")
+		eventId := server.newEvent(t, ownerToken, "")
+		coHost, coHostToken := server.newUser(t, "CoHost")
+		server.addMember(t, ownerToken, eventId, coHost, database.MemberCoHost)
+
+		response := server.do(t, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/webhooks", eventId), coHostToken,
+			`{"url": "https://93.184.215.14/hooks", "events": ["event.updated", "attendee.joined"]}`)
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("registering the webhook = %s", response.Status)
+		}
+		var webhook webhookResponse
+		decodeJSON(t, response, &webhook)
+
+		response = server.do(t, http.MethodDelete, fmt.Sprintf("/api/v1/events/%d/members/%d", eventId, coHost.Id), ownerToken, "")
+		if response.StatusCode != http.StatusNoContent {
+			t.Fatalf("removing the co-host = %s", response.Status)
+		}
+
+		_, attendeeToken := server.newUser(t, "Attendee")
+		response = server.do(t, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/rsvp", eventId), attendeeToken, `{"status": "going"}`)
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("RSVP = %s", response.Status)
+		}
+
+		ctx := context.Background()
+		if _, err := server.app.models.Webhooks.Dispatch(ctx, webhookBatchSize); err != nil {
+			t.Fatal(err)
+		}
+		deliveries, err := server.app.models.Webhooks.GetDeliveries(ctx, webhook.Id, webhookLogLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 0 {
+			t.Errorf("deliveries = %v, want none after the co-host was removed", deliveries)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+var errWebhookTarget = errors.New("webhooks cannot be sent to loopback, link-local or private addresses")
+
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+publicAddress reports whether a webhook may be sent to addr. Loopback,
+link-local, private, multicast and unspecified addresses are refused, so
+a webhook cannot be used to reach the server itself, the cloud metadata
+service at 169.254.169.254 or other machines of the internal network.
+So are the shared address space of carrier-grade NAT, the ranges
+reserved for protocols and benchmarks, and NAT64 addresses, which could
+wrap any of the others. IPv4 addresses mapped to IPv6 are checked as IPv4.
+*/
+
+func checkWebhookURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s", parsed.Hostname())
+	}
+
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return errWebhookTarget
+		}
+	}
+
+	return nil
+}
+
+// checkWebhookURL refuses a webhook URL whose host resolves to any address
+// that is not public, so the user learns about it when they register it.
+
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+
+			if !publicAddress(addr) {
+				return errWebhookTarget
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConnsPerHost: 2,
+		},
+	}
+}
+
+/*
+A host can resolve to a public address when the webhook is registered and
+to a private one when it is delivered, by changing its DNS records in
+between or by answering differently every time (DNS rebinding). That is
+why the client that delivers webhooks checks the address again right
+before it connects. Control runs after the host was resolved, on the
+address that is actually dialed, for the webhook URL and every redirect.
+The transport does not use the proxy of the environment, the address it
+checks would be the one of the proxy instead of the receiver.
+*/
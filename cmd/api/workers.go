@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+func (app *application) startWorkers(ctx context.Context) {
+	app.background(func() {
+		app.runCleanup(ctx, app.cleanupInterval)
+	})
+	app.background(func() {
+		app.runWebhooks(ctx, app.webhookInterval)
+	})
+}
+
+/*
+startWorkers launches the long running background workers. They all watch
+ctx and return once it is cancelled, which serve does on shutdown.
+*/
+
+func (app *application) runCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Print("Cleanup worker stopped")
+			return
+		case <-ticker.C:
+			sessions, err := app.models.Sessions.DeleteExpired(ctx)
+			if err != nil {
+				log.Printf("Cleanup of expired sessions failed: %v", err)
+			}
+
+			tokens, err := app.models.Tokens.DeleteExpired(ctx)
+			if err != nil {
+				log.Printf("Cleanup of expired tokens failed: %v", err)
+			}
+
+			if sessions > 0 || tokens > 0 {
+				log.Printf("Cleanup removed %d sessions and %d tokens", sessions, tokens)
+			}
+
+			completed, err := app.models.Events.CompleteEnded(ctx, time.Now())
+			if err != nil {
+				log.Printf("Completing ended events failed: %v", err)
+			}
+			if completed > 0 {
+				log.Printf("Cleanup completed %d ended events", completed)
+			}
+
+			deliveries, err := app.models.Webhooks.DeleteOldDeliveries(ctx, time.Now().Add(-webhookLogRetention))
+			if err != nil {
+				log.Printf("Cleanup of old webhook deliveries failed: %v", err)
+			}
+			if deliveries > 0 {
+				log.Printf("Cleanup removed %d old webhook deliveries", deliveries)
+			}
+		}
+	}
+}
+
+// runCleanup periodically deletes expired sessions and used or expired tokens,
+// marks published events that are over as completed and trims the webhook
+// delivery log.
+
+func (app *application) runWebhooks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			dispatched, err := app.models.Webhooks.Dispatch(ctx, webhookBatchSize)
+			if err != nil {
+				log.Printf("Dispatching webhooks failed: %v", err)
+			}
+			if err != nil || dispatched < webhookBatchSize {
+				break
+			}
+		}
+
+		deliveries, err := app.models.Webhooks.DueDeliveries(ctx, time.Now(), webhookBatchSize)
+		if err != nil {
+			log.Printf("Loading due webhook deliveries failed: %v", err)
+		}
+		for _, delivery := range deliveries {
+			app.deliverWebhook(ctx, delivery)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Print("Webhook worker stopped")
+			return
+		case <-ticker.C:
+		case <-app.webhookWake:
+		}
+	}
+}
+
+/*
+runWebhooks empties the outbox into deliveries and sends the ones that are
+due, once at startup to pick up what was left before a restart, then on
+every tick and whenever a handler queued a notification. Deliveries are
+sent one after the other, a slow receiver delays the others by at most
+webhookTimeout.
+*/
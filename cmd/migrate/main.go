@@ -6,8 +6,14 @@ import (
 	"os"
 
 	"github.com/golang-migrate/migrate/v4"
+	migratedb "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
 	"github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/joho/godotenv/autoload"
+
+	"github.com/schlafer/EventApp/internal/database"
+	"github.com/schlafer/EventApp/internal/env"
 )
 
 func main() {
@@ -17,23 +23,37 @@ func main() {
 
 	direction := os.Args[1]
 
-	db, err := sql.Open("sqlite3", "./data.db")
+	dialect, dsn := database.ParseURL(env.GetEnvString("DATABASE_URL", "sqlite://./data.db"))
+
+	db, err := sql.Open(dialect, dsn)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	instance, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	var (
+		instance migratedb.Driver
+		dir      string
+	)
+
+	switch dialect {
+	case database.DialectPostgres:
+		instance, err = postgres.WithInstance(db, &postgres.Config{})
+		dir = "cmd/migrate/migrations/postgres"
+	default:
+		instance, err = sqlite3.WithInstance(db, &sqlite3.Config{})
+		dir = "cmd/migrate/migrations/sqlite"
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	fSrc, err := (&file.File{}).Open("cmd/migrate/migrations")
+	fSrc, err := (&file.File{}).Open(dir)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	m, err := migrate.NewWithInstance("file", fSrc, "sqlite3", instance)
+	m, err := migrate.NewWithInstance("file", fSrc, dialect, instance)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,7 @@
+ALTER TABLE events ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', location), 'B') ||
+    setweight(to_tsvector('simple', description), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_events_search_vector ON events USING GIN (search_vector);
\ No newline at end of file
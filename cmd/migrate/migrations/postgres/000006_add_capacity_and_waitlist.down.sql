@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS waitlist;
+ALTER TABLE events DROP COLUMN IF EXISTS capacity;
\ No newline at end of file
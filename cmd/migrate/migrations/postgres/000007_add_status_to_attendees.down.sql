@@ -0,0 +1 @@
+ALTER TABLE attendees DROP COLUMN IF EXISTS status;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DELETE FROM attendees
+WHERE id NOT IN (
+    SELECT MIN(id) FROM attendees GROUP BY event_id, user_id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attendees_event_id_user_id ON attendees (event_id, user_id);
\ No newline at end of file
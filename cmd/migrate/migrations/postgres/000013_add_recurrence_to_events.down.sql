@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_events_series_id_occurrence_date;
+
+ALTER TABLE events DROP COLUMN IF EXISTS modified;
+ALTER TABLE events DROP COLUMN IF EXISTS occurrence_date;
+ALTER TABLE events DROP COLUMN IF EXISTS series_id;
+ALTER TABLE events DROP COLUMN IF EXISTS exdates;
+ALTER TABLE events DROP COLUMN IF EXISTS rrule;
\ No newline at end of file
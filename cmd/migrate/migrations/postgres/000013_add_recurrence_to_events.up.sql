@@ -0,0 +1,7 @@
+ALTER TABLE events ADD COLUMN rrule TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN exdates TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN series_id INTEGER REFERENCES events (id) ON DELETE CASCADE;
+ALTER TABLE events ADD COLUMN occurrence_date TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN modified BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_series_id_occurrence_date ON events (series_id, occurrence_date);
\ No newline at end of file
@@ -0,0 +1,12 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS date DATE;
+
+UPDATE events SET date = (starts_at AT TIME ZONE timezone)::date;
+
+ALTER TABLE events ALTER COLUMN date SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_events_starts_at;
+ALTER TABLE events DROP COLUMN IF EXISTS timezone;
+ALTER TABLE events DROP COLUMN IF EXISTS ends_at;
+ALTER TABLE events DROP COLUMN IF EXISTS starts_at;
+
+CREATE INDEX IF NOT EXISTS idx_events_date ON events (date, id);
\ No newline at end of file
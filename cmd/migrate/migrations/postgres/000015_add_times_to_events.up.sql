@@ -0,0 +1,15 @@
+ALTER TABLE events ADD COLUMN starts_at TIMESTAMPTZ;
+ALTER TABLE events ADD COLUMN ends_at TIMESTAMPTZ;
+ALTER TABLE events ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+UPDATE events SET
+    starts_at = date::timestamp AT TIME ZONE 'UTC',
+    ends_at = (date + 1)::timestamp AT TIME ZONE 'UTC';
+
+ALTER TABLE events ALTER COLUMN starts_at SET NOT NULL;
+ALTER TABLE events ALTER COLUMN ends_at SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_events_date;
+ALTER TABLE events DROP COLUMN date;
+
+CREATE INDEX IF NOT EXISTS idx_events_starts_at ON events (starts_at, id);
\ No newline at end of file
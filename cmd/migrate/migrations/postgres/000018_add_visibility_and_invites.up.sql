@@ -0,0 +1,15 @@
+ALTER TABLE events ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+
+CREATE TABLE IF NOT EXISTS event_invites (
+    id SERIAL PRIMARY KEY,
+    event_id INTEGER NOT NULL,
+    created_by INTEGER NOT NULL,
+    expires_at TIMESTAMPTZ,
+    max_uses INTEGER,
+    uses INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_invites_event_id ON event_invites (event_id);
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS event_members (
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL,
+    added_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (event_id, user_id),
+    FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (added_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_members_user_id ON event_members (user_id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+UPDATE webhooks
+SET events = BTRIM(REPLACE(',' || events || ',', ',event.cancelled,', ','), ',')
+WHERE ',' || events || ',' LIKE '%,event.cancelled,%';
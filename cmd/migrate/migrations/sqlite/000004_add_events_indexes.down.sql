@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_events_owner_id;
+DROP INDEX IF EXISTS idx_events_name;
+DROP INDEX IF EXISTS idx_events_date;
\ No newline at end of file
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_events_date ON events (date, id);
+CREATE INDEX IF NOT EXISTS idx_events_name ON events (name, id);
+CREATE INDEX IF NOT EXISTS idx_events_owner_id ON events (owner_id);
\ No newline at end of file
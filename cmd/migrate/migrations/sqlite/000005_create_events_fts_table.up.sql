@@ -0,0 +1,27 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS events_fts USING fts5 (
+    name,
+    description,
+    location,
+    content = 'events',
+    content_rowid = 'id'
+);
+
+INSERT INTO events_fts (rowid, name, description, location)
+SELECT id, name, description, location FROM events;
+
+CREATE TRIGGER IF NOT EXISTS events_fts_after_insert AFTER INSERT ON events BEGIN
+    INSERT INTO events_fts (rowid, name, description, location)
+    VALUES (new.id, new.name, new.description, new.location);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_fts_after_delete AFTER DELETE ON events BEGIN
+    INSERT INTO events_fts (events_fts, rowid, name, description, location)
+    VALUES ('delete', old.id, old.name, old.description, old.location);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_fts_after_update AFTER UPDATE OF name, description, location ON events BEGIN
+    INSERT INTO events_fts (events_fts, rowid, name, description, location)
+    VALUES ('delete', old.id, old.name, old.description, old.location);
+    INSERT INTO events_fts (rowid, name, description, location)
+    VALUES (new.id, new.name, new.description, new.location);
+END;
\ No newline at end of file
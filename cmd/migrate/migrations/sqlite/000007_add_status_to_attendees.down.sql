@@ -0,0 +1 @@
+ALTER TABLE attendees DROP COLUMN status;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE attendees ADD COLUMN status TEXT NOT NULL DEFAULT 'going' CHECK (status IN ('going', 'maybe', 'declined'));
\ No newline at end of file
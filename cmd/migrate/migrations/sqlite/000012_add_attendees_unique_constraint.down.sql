@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_attendees_event_id_user_id;
\ No newline at end of file
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_events_series_id_occurrence_date;
+
+ALTER TABLE events DROP COLUMN modified;
+ALTER TABLE events DROP COLUMN occurrence_date;
+ALTER TABLE events DROP COLUMN series_id;
+ALTER TABLE events DROP COLUMN exdates;
+ALTER TABLE events DROP COLUMN rrule;
\ No newline at end of file
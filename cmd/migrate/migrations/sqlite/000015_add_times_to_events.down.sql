@@ -0,0 +1,10 @@
+ALTER TABLE events ADD COLUMN date DATETIME NOT NULL DEFAULT '';
+
+UPDATE events SET date = date(starts_at);
+
+DROP INDEX IF EXISTS idx_events_starts_at;
+ALTER TABLE events DROP COLUMN timezone;
+ALTER TABLE events DROP COLUMN ends_at;
+ALTER TABLE events DROP COLUMN starts_at;
+
+CREATE INDEX IF NOT EXISTS idx_events_date ON events (date, id);
\ No newline at end of file
@@ -0,0 +1,12 @@
+ALTER TABLE events ADD COLUMN starts_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+ALTER TABLE events ADD COLUMN ends_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+ALTER TABLE events ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+UPDATE events SET
+    starts_at = strftime('%Y-%m-%d 00:00:00+00:00', date),
+    ends_at = strftime('%Y-%m-%d 00:00:00+00:00', date, '+1 day');
+
+DROP INDEX IF EXISTS idx_events_date;
+ALTER TABLE events DROP COLUMN date;
+
+CREATE INDEX IF NOT EXISTS idx_events_starts_at ON events (starts_at, id);
\ No newline at end of file
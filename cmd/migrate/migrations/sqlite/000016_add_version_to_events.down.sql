@@ -0,0 +1 @@
+ALTER TABLE events DROP COLUMN version;
\ No newline at end of file
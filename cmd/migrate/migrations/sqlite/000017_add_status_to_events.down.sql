@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_events_status;
+
+ALTER TABLE events DROP COLUMN cancelled_at;
+ALTER TABLE events DROP COLUMN cancellation_reason;
+ALTER TABLE events DROP COLUMN status;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE events ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+ALTER TABLE events ADD COLUMN cancellation_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN cancelled_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_events_status ON events (status);
\ No newline at end of file
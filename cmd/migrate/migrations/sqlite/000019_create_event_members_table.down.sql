@@ -0,0 +1 @@
+DROP TABLE IF EXISTS event_members;
\ No newline at end of file
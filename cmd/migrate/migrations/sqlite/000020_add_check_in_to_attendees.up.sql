@@ -0,0 +1 @@
+ALTER TABLE attendees ADD COLUMN checked_in_at DATETIME;
\ No newline at end of file
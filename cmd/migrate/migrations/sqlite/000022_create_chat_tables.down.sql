@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS chat_mutes;
+DROP TABLE IF EXISTS chat_messages;
\ No newline at end of file
@@ -0,0 +1,3 @@
+UPDATE webhooks
+SET events = TRIM(REPLACE(',' || events || ',', ',event.cancelled,', ','), ',')
+WHERE ',' || events || ',' LIKE '%,event.cancelled,%';
@@ -0,0 +1,4 @@
+UPDATE webhooks
+SET events = events || ',event.cancelled'
+WHERE ',' || events || ',' LIKE '%,event.updated,%'
+  AND ',' || events || ',' NOT LIKE '%,event.cancelled,%';
@@ -3,30 +3,68 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 )
 
 type AttendeeModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
 }
 
 type Attendee struct {
-	Id      int `json:"id"`
-	UserId  int `json:"userId"`
-	EventId int `json:"eventId"`
+	Id          int        `json:"id"`
+	UserId      int        `json:"userId"`
+	EventId     int        `json:"eventId"`
+	Status      string     `json:"status"`
+	CheckedInAt *time.Time `json:"checkedInAt,omitempty"`
+	Ticket      string     `json:"ticket,omitempty"`
 }
 
+const (
+	StatusGoing    = "going"
+	StatusMaybe    = "maybe"
+	StatusDeclined = "declined"
+)
+
+type RSVP struct {
+	UserId      int        `json:"userId"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Status      string     `json:"status"`
+	CheckedInAt *time.Time `json:"checkedInAt,omitempty"`
+}
+
+var (
+	ErrDuplicateAttendee = errors.New("attendee already exists")
+	ErrAlreadyWaitlisted = errors.New("attendee is already on the waitlist")
+	ErrAlreadyCheckedIn  = errors.New("attendee is already checked in")
+	ErrNotGoing          = errors.New("attendee is not going")
+)
+
 /*
-The Attendee struct includes three fields: Id, UserId, and EventId.
+The Attendee struct includes four fields: Id, UserId, EventId and Status.
 An attendee is a user that has signed up for an event. An event can have many attendees and an attendee can attend many events.
+The status is the answer of the user to the invitation: going, maybe or declined.
+Only attendees that are going take up a spot of the event capacity.
+CheckedInAt is set when the attendee shows up at the event. The Ticket is
+not stored, the handlers sign it from the ids of the attendee row.
 */
 
-func (m *AttendeeModel) Insert(attendee *Attendee) (*Attendee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *AttendeeModel) Insert(ctx context.Context, attendee *Attendee) (*Attendee, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.insert")
 	defer cancel()
 
-	query := "INSERT INTO attendees (event_id, user_id) VALUES ($1, $2) RETURNING id"
-	err := m.DB.QueryRowContext(ctx, query, attendee.EventId, attendee.UserId).Scan(&attendee.Id)
+	if attendee.Status == "" {
+		attendee.Status = StatusGoing
+	}
+
+	query := "INSERT INTO attendees (event_id, user_id, status) VALUES ($1, $2, $3) RETURNING id"
+	err := m.DB.QueryRowContext(ctx, query, attendee.EventId, attendee.UserId, attendee.Status).Scan(&attendee.Id)
+
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateAttendee
+	}
 
 	if err != nil {
 		return nil, err
@@ -37,15 +75,93 @@ func (m *AttendeeModel) Insert(attendee *Attendee) (*Attendee, error) {
 
 //Here we insert the attendee into the database with the provided user ID,
 // event ID and return an error if there is one.
+// A user can only be added once per event, the unique index on
+// (event_id, user_id) turns a second insert into ErrDuplicateAttendee.
 
-func (m *AttendeeModel) GetByEventAndAttendee(eventId, userId int) (*Attendee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *AttendeeModel) InsertOrWaitlist(ctx context.Context, attendee *Attendee, enqueue EnqueueRSVPs) (*WaitlistEntry, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.insertOrWaitlist")
 	defer cancel()
 
-	query := "SELECT * FROM attendees where event_id = $1 AND user_id = $2"
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	spots, limited, err := availableSpots(ctx, tx, attendee.EventId)
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM attendees WHERE event_id = $1 AND user_id = $2)"
+	if err := tx.QueryRowContext(ctx, query, attendee.EventId, attendee.UserId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrDuplicateAttendee
+	}
+
+	waiting, err := getWaitlistEntry(ctx, tx, attendee.EventId, attendee.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if waiting != nil {
+		return nil, ErrAlreadyWaitlisted
+	}
+
+	if limited && spots <= 0 {
+		entry, err := addToWaitlist(ctx, tx, attendee.EventId, attendee.UserId)
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyWaitlisted
+		}
+		if err != nil {
+			return nil, err
+		}
+		return entry, tx.Commit()
+	}
+
+	attendee.Status = StatusGoing
+
+	query = "INSERT INTO attendees (event_id, user_id, status) VALUES ($1, $2, $3) RETURNING id"
+	err = tx.QueryRowContext(ctx, query, attendee.EventId, attendee.UserId, attendee.Status).Scan(&attendee.Id)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateAttendee
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []RSVPChange{{UserId: attendee.UserId, After: attendee.Status}}
+	if err := enqueue.run(ctx, tx, changes); err != nil {
+		return nil, err
+	}
+
+	return nil, tx.Commit()
+}
+
+/*
+InsertOrWaitlist adds the attendee if the event still has room.
+If the event is full the user is put on the waitlist instead and the
+waitlist entry is returned. Counting and inserting happen in one
+transaction so two requests can never take the last spot at the same time.
+The duplicate checks run inside the same transaction, after availableSpots
+locked the event, so they cannot race with a concurrent insert either.
+Should a duplicate slip through anyway, the unique indexes on attendees and
+waitlist reject it and the violation is reported as ErrDuplicateAttendee
+or ErrAlreadyWaitlisted.
+enqueue is only called for an attendee that was added, not for a user
+that ends up on the waitlist.
+*/
+
+func (m *AttendeeModel) GetByEventAndAttendee(ctx context.Context, eventId, userId int) (*Attendee, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.getByEventAndAttendee")
+	defer cancel()
+
+	query := "SELECT id, user_id, event_id, status, checked_in_at FROM attendees where event_id = $1 AND user_id = $2"
 
 	var attendee Attendee
-	err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(&attendee.Id, &attendee.UserId, &attendee.EventId)
+	err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(&attendee.Id, &attendee.UserId, &attendee.EventId, &attendee.Status, &attendee.CheckedInAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -60,15 +176,39 @@ func (m *AttendeeModel) GetByEventAndAttendee(eventId, userId int) (*Attendee, e
 //This method retrieves an attendee record from the database based on
 // the provided event ID and user ID.
 
-func (m *AttendeeModel) GetAttendeesByEvent(eventId int) ([]*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *AttendeeModel) IsSignedUp(ctx context.Context, eventId, userId int) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.isSignedUp")
+	defer cancel()
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM attendees a JOIN events e ON e.id = a.event_id
+			WHERE a.user_id = $1 AND (e.id = $2 OR e.series_id = $2)
+		) OR EXISTS (
+			SELECT 1 FROM waitlist w JOIN events e ON e.id = w.event_id
+			WHERE w.user_id = $1 AND (e.id = $2 OR e.series_id = $2)
+		)
+	`
+
+	var signedUp bool
+	err := m.DB.QueryRowContext(ctx, query, userId, eventId).Scan(&signedUp)
+	return signedUp, err
+}
+
+// IsSignedUp reports whether the user signed up for the event, whatever the
+// answer, or is on its waitlist. For a series signing up for any of its
+// occurrences counts, so attendees of one date can still open the series.
+
+func (m *AttendeeModel) GetAttendeesByEvent(ctx context.Context, eventId int) ([]*User, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.getAttendeesByEvent")
 	defer cancel()
 
 	query := `
-	 SELECT u.id, u.name, u.email
+	 SELECT u.id, u.name, u.email, u.role
 	 FROM users u
 	 JOIN attendees a ON u.id = a.user_id
-	 where a.event_id = $1
+	 where a.event_id = $1 AND a.status = 'going'
+	 ORDER BY a.id
 	`
 
 	rows, err := m.DB.QueryContext(ctx, query, eventId)
@@ -78,11 +218,11 @@ func (m *AttendeeModel) GetAttendeesByEvent(eventId int) ([]*User, error) {
 
 	defer rows.Close()
 
-	var users []*User
+	users := []*User{}
 
 	for rows.Next() {
 		var user User
-		err := rows.Scan(&user.Id, &user.Name, &user.Email)
+		err := rows.Scan(&user.Id, &user.Name, &user.Email, &user.Role)
 		if err != nil {
 			return nil, err
 		}
@@ -90,38 +230,79 @@ func (m *AttendeeModel) GetAttendeesByEvent(eventId int) ([]*User, error) {
 		users = append(users, &user)
 	}
 
-	return users, nil
+	return users, rows.Err()
 }
 
 //This method retrieves a list of users attending a specific event
 // by joining the users and attendees tables.
+// Users that answered maybe or declined are not part of the list.
+// An event without attendees gets an empty list, not nil.
 
-func (m *AttendeeModel) Delete(userId, eventId int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *AttendeeModel) Delete(ctx context.Context, userId, eventId int, enqueue EnqueueRSVPs) ([]*Attendee, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.delete")
 	defer cancel()
 
-	query := "DELETE FROM attendees WHERE user_id = $1 AND event_id = $2"
-	_, err := m.DB.ExecContext(ctx, query, userId, eventId)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var changes []RSVPChange
+
+	previous := ""
+	query := "DELETE FROM attendees WHERE user_id = $1 AND event_id = $2 RETURNING status"
+	err = tx.QueryRowContext(ctx, query, userId, eventId).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if previous != "" {
+		changes = append(changes, RSVPChange{UserId: userId, Before: previous})
+	}
+
+	entry, err := getWaitlistEntry(ctx, tx, eventId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		if err := removeFromWaitlist(ctx, tx, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	promoted, err := promoteFromWaitlist(ctx, tx, eventId)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := enqueue.run(ctx, tx, append(changes, promotions(promoted)...)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return promoted, nil
 
 }
 
 // This method deletes an attendee from an event
 // with the provided user ID and event ID.
+// If the user was only waitlisted, the waitlist entry is removed instead.
+// The freed spot goes to the first user on the waitlist in the same
+// transaction, the promoted attendees are returned. enqueue gets the
+// removed attendee and the promoted ones.
 
-func (m *AttendeeModel) GetEventsByAttendee(attendeeId int) ([]*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *AttendeeModel) GetEventsByAttendee(ctx context.Context, attendeeId int) ([]*Event, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.getEventsByAttendee")
 	defer cancel()
 
 	query := `
-		SELECT e.id, e.owner_id, e.name, e.description, e.date, e.location
+		SELECT ` + selectEventColumns("e") + `
 		FROM events e
 		JOIN attendees a ON e.id = a.event_id
-		WHERE a.user_id = $1
+		WHERE a.user_id = $1 AND a.status != 'declined'
 	`
 	rows, err := m.DB.QueryContext(ctx, query, attendeeId)
 	if err != nil {
@@ -130,20 +311,225 @@ func (m *AttendeeModel) GetEventsByAttendee(attendeeId int) ([]*Event, error) {
 
 	defer rows.Close()
 
-	var events []*Event
+	events := []*Event{}
 	for rows.Next() {
 		var event Event
-		err := rows.Scan(&event.Id, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+		err := rows.Scan(eventFields(&event)...)
 		if err != nil {
 			return nil, err
 		}
 		events = append(events, &event)
 	}
 
-	return events, nil
-
+	return events, rows.Err()
 }
 
 // This method retrieves all events a user is attending
 // with the provided attendee ID, joining the events and attendees tables
-// to get the relevant data.
+// to get the relevant data. Events the user declined are left out.
+// A user that attends nothing gets an empty list, not nil.
+
+func (m *AttendeeModel) SetStatus(ctx context.Context, eventId, userId int, status string, inviteId int, enqueue EnqueueRSVPs) (*Attendee, *WaitlistEntry, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.setStatus")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if inviteId != 0 {
+		if err := useInvite(ctx, tx, inviteId); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	attendee := Attendee{EventId: eventId, UserId: userId}
+	previous := ""
+
+	query := "SELECT id, status FROM attendees WHERE event_id = $1 AND user_id = $2"
+	err = tx.QueryRowContext(ctx, query, eventId, userId).Scan(&attendee.Id, &previous)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+
+	if status == StatusGoing && previous != StatusGoing {
+		spots, limited, err := availableSpots(ctx, tx, eventId)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if limited && spots <= 0 {
+			var entry *WaitlistEntry
+			entry, err = getWaitlistEntry(ctx, tx, eventId, userId)
+			if err != nil {
+				return nil, nil, err
+			}
+			if entry == nil {
+				entry, err = addToWaitlist(ctx, tx, eventId, userId)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			return nil, entry, tx.Commit()
+		}
+	}
+
+	if status != StatusGoing {
+		entry, err := getWaitlistEntry(ctx, tx, eventId, userId)
+		if err != nil {
+			return nil, nil, err
+		}
+		if entry != nil {
+			if err := removeFromWaitlist(ctx, tx, entry); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if err := upsertAttendee(ctx, tx, &attendee, status); err != nil {
+		return nil, nil, err
+	}
+
+	changes := []RSVPChange{{UserId: userId, Before: previous, After: status}}
+
+	if previous == StatusGoing && status != StatusGoing {
+		promoted, err := promoteFromWaitlist(ctx, tx, eventId)
+		if err != nil {
+			return nil, nil, err
+		}
+		changes = append(changes, promotions(promoted)...)
+	}
+
+	if err := enqueue.run(ctx, tx, changes); err != nil {
+		return nil, nil, err
+	}
+
+	return &attendee, nil, tx.Commit()
+}
+
+/*
+SetStatus records the RSVP of a user for an event.
+Answering going needs a free spot, if the event is full the user is put
+on the waitlist and the waitlist entry is returned instead of the attendee.
+Answering maybe or declined leaves the waitlist, and if the user was going
+before, the freed spot is handed to the next user on the waitlist.
+Everything happens in one transaction so the capacity is never exceeded.
+If the user signs up through an invite link, inviteId counts one use of it
+in the same transaction, so an RSVP that fails does not use up the link.
+A used up or expired invite returns ErrInvalidToken and nothing changes.
+enqueue gets the answer of the user and the users promoted in their place,
+it is not called when the user was put on the waitlist.
+*/
+
+func (m *AttendeeModel) GetRSVPsByEvent(ctx context.Context, eventId int) ([]*RSVP, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.getRSVPsByEvent")
+	defer cancel()
+
+	query := `
+		SELECT u.id, u.name, u.email, a.status, a.checked_in_at
+		FROM users u
+		JOIN attendees a ON u.id = a.user_id
+		WHERE a.event_id = $1
+		ORDER BY a.id
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rsvps := []*RSVP{}
+
+	for rows.Next() {
+		var rsvp RSVP
+		err := rows.Scan(&rsvp.UserId, &rsvp.Name, &rsvp.Email, &rsvp.Status, &rsvp.CheckedInAt)
+		if err != nil {
+			return nil, err
+		}
+		rsvps = append(rsvps, &rsvp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rsvps, nil
+}
+
+// This method returns the answer of every user that responded to the event,
+// including the ones that declined.
+
+func (m *AttendeeModel) CheckIn(ctx context.Context, eventId, attendeeId, userId int) (*Attendee, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.checkIn")
+	defer cancel()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	attendee := Attendee{Id: attendeeId, EventId: eventId, UserId: userId, Status: StatusGoing, CheckedInAt: &now}
+
+	query := `
+		UPDATE attendees SET checked_in_at = $1
+		WHERE id = $2 AND event_id = $3 AND user_id = $4 AND status = 'going' AND checked_in_at IS NULL
+		RETURNING id
+	`
+	err := m.DB.QueryRowContext(ctx, query, now, attendeeId, eventId, userId).Scan(&attendee.Id)
+	if err == nil {
+		return &attendee, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	current, err := m.GetByEventAndAttendee(ctx, eventId, userId)
+	if err != nil || current == nil || current.Id != attendeeId {
+		return nil, err
+	}
+	if current.CheckedInAt != nil {
+		return current, ErrAlreadyCheckedIn
+	}
+	return current, ErrNotGoing
+}
+
+/*
+CheckIn records that the attendee arrived at the event. The check and the
+update are one statement, so scanning the same ticket at two entrances at
+once checks the attendee in only once; the second scan gets
+ErrAlreadyCheckedIn together with the attendee and the time of the first
+one. An attendee that no longer goes gets ErrNotGoing. If the row is gone,
+because the RSVP was cancelled, CheckIn returns nil and no error.
+*/
+
+func (m *AttendeeModel) GetCheckIns(ctx context.Context, eventId int) ([]*RSVP, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "attendees.getCheckIns")
+	defer cancel()
+
+	query := `
+		SELECT u.id, u.name, u.email, a.status, a.checked_in_at
+		FROM users u
+		JOIN attendees a ON u.id = a.user_id
+		WHERE a.event_id = $1 AND a.status = 'going'
+		ORDER BY a.checked_in_at IS NULL, a.checked_in_at, a.id
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rsvps := []*RSVP{}
+	for rows.Next() {
+		var rsvp RSVP
+		if err := rows.Scan(&rsvp.UserId, &rsvp.Name, &rsvp.Email, &rsvp.Status, &rsvp.CheckedInAt); err != nil {
+			return nil, err
+		}
+		rsvps = append(rsvps, &rsvp)
+	}
+
+	return rsvps, rows.Err()
+}
+
+// GetCheckIns returns the attendees that are going, the ones that already
+// checked in first, in the order they arrived.
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ChatModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+type ChatMessage struct {
+	Id        int       `json:"id"`
+	EventId   int       `json:"eventId"`
+	UserId    int       `json:"userId"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ChatMute struct {
+	EventId   int        `json:"eventId"`
+	UserId    int        `json:"userId"`
+	Name      string     `json:"name"`
+	MutedBy   *int       `json:"mutedBy,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+/*
+Every event has a chat for its attendees and organizers. Messages are
+stored so people that join later can read what was said. A deleted message
+keeps its row with deleted_at and deleted_by set, it is just no longer
+returned. A muted user can still read the chat but cannot write; without
+Until the mute lasts until it is lifted.
+*/
+
+func (m ChatModel) InsertMessage(ctx context.Context, message *ChatMessage) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "chat.insertMessage")
+	defer cancel()
+
+	message.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	query := "INSERT INTO chat_messages (event_id, user_id, body, created_at) VALUES ($1, $2, $3, $4) RETURNING id"
+	return m.DB.QueryRowContext(ctx, query, message.EventId, message.UserId, message.Body, message.CreatedAt).Scan(&message.Id)
+}
+
+func (m ChatModel) GetMessages(ctx context.Context, eventId, limit int, cursor string) ([]*ChatMessage, Metadata, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "chat.getMessages")
+	defer cancel()
+
+	metadata := Metadata{Limit: limit}
+
+	query := "SELECT COUNT(*) FROM chat_messages WHERE event_id = $1 AND deleted_at IS NULL"
+	if err := m.DB.QueryRowContext(ctx, query, eventId).Scan(&metadata.Total); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	before := 0
+	if cursor != "" {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		before = id
+	}
+
+	query = `
+		SELECT c.id, c.event_id, c.user_id, u.name, c.body, c.created_at
+		FROM chat_messages c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.event_id = $1 AND c.deleted_at IS NULL AND ($2 = 0 OR c.id < $2)
+		ORDER BY c.id DESC
+		LIMIT $3
+	`
+	rows, err := m.DB.QueryContext(ctx, query, eventId, before, limit+1)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	messages := []*ChatMessage{}
+	for rows.Next() {
+		var message ChatMessage
+		err := rows.Scan(&message.Id, &message.EventId, &message.UserId, &message.Name, &message.Body, &message.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		messages = append(messages, &message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(messages) > limit {
+		messages = messages[:limit]
+		metadata.NextCursor = encodeCursor(messages[len(messages)-1].Id)
+	}
+
+	return messages, metadata, nil
+}
+
+/*
+GetMessages pages backwards through the chat, newest message first, with
+the same opaque cursors as EventModel.GetAll. The next page holds the
+messages written before the last one of this page, so new messages do
+not shift the pages a client is scrolling through.
+*/
+
+func (m ChatModel) GetMessage(ctx context.Context, eventId, id int) (*ChatMessage, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "chat.getMessage")
+	defer cancel()
+
+	query := `
+		SELECT c.id, c.event_id, c.user_id, u.name, c.body, c.created_at
+		FROM chat_messages c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1 AND c.event_id = $2 AND c.deleted_at IS NULL
+	`
+
+	var message ChatMessage
+	err := m.DB.QueryRowContext(ctx, query, id, eventId).Scan(&message.Id, &message.EventId, &message.UserId,
+		&message.Name, &message.Body, &message.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+func (m ChatModel) DeleteMessage(ctx context.Context, eventId, id, deletedBy int) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "chat.deleteMessage")
+	defer cancel()
+
+	query := `
+		UPDATE chat_messages SET deleted_at = $1, deleted_by = $2
+		WHERE id = $3 AND event_id = $4 AND deleted_at IS NULL
+	`
+	result, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), deletedBy, id, eventId)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// GetMessage returns nil and DeleteMessage false if the event has no
+// message with this id or it was deleted already.
+
+func (m ChatModel) Mute(ctx context.Context, mute *ChatMute) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "chat.mute")
+	defer cancel()
+
+	mute.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	if mute.Until != nil {
+		until := mute.Until.UTC().Truncate(time.Second)
+		mute.Until = &until
+	}
+
+	query := `
+		INSERT INTO chat_mutes (event_id, user_id, muted_by, until, created_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (event_id, user_id) DO UPDATE
+		SET muted_by = excluded.muted_by, until = excluded.until, created_at = excluded.created_at
+	`
+	_, err := m.DB.ExecContext(ctx, query, mute.EventId, mute.UserId, mute.MutedBy, mute.Until, mute.CreatedAt)
+	return err
+}
+
+func (m ChatModel) Unmute(ctx context.Context, eventId, userId int) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "chat.unmute")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM chat_mutes WHERE event_id = $1 AND user_id = $2", eventId, userId)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// Muting a user that is muted already replaces the previous mute, so a
+// moderator can extend or shorten it.
+
+func (m ChatModel) IsMuted(ctx context.Context, eventId, userId int) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "chat.isMuted")
+	defer cancel()
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM chat_mutes
+			WHERE event_id = $1 AND user_id = $2 AND (until IS NULL OR until > $3)
+		)
+	`
+
+	var muted bool
+	err := m.DB.QueryRowContext(ctx, query, eventId, userId, time.Now().UTC()).Scan(&muted)
+	return muted, err
+}
+
+func (m ChatModel) GetMutes(ctx context.Context, eventId int) ([]*ChatMute, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "chat.getMutes")
+	defer cancel()
+
+	query := `
+		SELECT m.event_id, m.user_id, u.name, m.muted_by, m.until, m.created_at
+		FROM chat_mutes m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.event_id = $1 AND (m.until IS NULL OR m.until > $2)
+		ORDER BY m.created_at, m.user_id
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, eventId, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mutes := []*ChatMute{}
+	for rows.Next() {
+		var mute ChatMute
+		err := rows.Scan(&mute.EventId, &mute.UserId, &mute.Name, &mute.MutedBy, &mute.Until, &mute.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		mutes = append(mutes, &mute)
+	}
+
+	return mutes, rows.Err()
+}
+
+// Expired mutes are ignored by IsMuted and GetMutes, they stay in the table
+// until the user is muted again or unmuted.
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type CommentModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+type Comment struct {
+	Id        int        `json:"id"`
+	EventId   int        `json:"eventId"`
+	UserId    int        `json:"userId,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	ParentId  *int       `json:"parentId,omitempty"`
+	Body      string     `json:"body"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	Replies   []*Comment `json:"replies,omitempty"`
+}
+
+/*
+Comments form threads one level deep: a comment without ParentId starts a
+thread, every reply points at the comment that started it. UpdatedAt is
+set once the author edited the comment.
+A deleted comment keeps its row with deleted_at and deleted_by set. A
+deleted reply is no longer returned. A deleted comment that started a
+thread is still returned while it has replies, with Deleted set and
+without its body and author, so the replies keep their context.
+*/
+
+const commentColumns = "c.id, c.event_id, c.user_id, u.name, c.parent_id, c.body, c.created_at, c.updated_at, c.deleted_at IS NOT NULL"
+
+func scanComment(row interface{ Scan(...any) error }) (*Comment, error) {
+	var comment Comment
+	err := row.Scan(&comment.Id, &comment.EventId, &comment.UserId, &comment.Name, &comment.ParentId,
+		&comment.Body, &comment.CreatedAt, &comment.UpdatedAt, &comment.Deleted)
+	if err != nil {
+		return nil, err
+	}
+
+	if comment.Deleted {
+		comment.UserId = 0
+		comment.Name = ""
+		comment.Body = ""
+		comment.UpdatedAt = nil
+	}
+
+	return &comment, nil
+}
+
+func (m CommentModel) Insert(ctx context.Context, comment *Comment) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "comments.insert")
+	defer cancel()
+
+	comment.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	query := "INSERT INTO comments (event_id, user_id, parent_id, body, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	return m.DB.QueryRowContext(ctx, query, comment.EventId, comment.UserId, comment.ParentId, comment.Body, comment.CreatedAt).Scan(&comment.Id)
+}
+
+func (m CommentModel) Get(ctx context.Context, eventId, id int) (*Comment, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "comments.get")
+	defer cancel()
+
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1 AND c.event_id = $2 AND c.deleted_at IS NULL
+	`
+
+	comment, err := scanComment(m.DB.QueryRowContext(ctx, query, id, eventId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// Get returns nil if the event has no comment with this id or it was
+// deleted.
+
+func (m CommentModel) GetByEvent(ctx context.Context, eventId, limit int, cursor string) ([]*Comment, Metadata, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "comments.getByEvent")
+	defer cancel()
+
+	metadata := Metadata{Limit: limit}
+
+	visible := `
+		c.event_id = $1 AND c.parent_id IS NULL
+		AND (c.deleted_at IS NULL OR EXISTS (
+			SELECT 1 FROM comments r WHERE r.parent_id = c.id AND r.deleted_at IS NULL
+		))
+	`
+
+	query := "SELECT COUNT(*) FROM comments c WHERE " + visible
+	if err := m.DB.QueryRowContext(ctx, query, eventId).Scan(&metadata.Total); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	after := 0
+	if cursor != "" {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		after = id
+	}
+
+	query = `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE ` + visible + ` AND c.id > $2
+		ORDER BY c.id
+		LIMIT $3
+	`
+	rows, err := m.DB.QueryContext(ctx, query, eventId, after, limit+1)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(comments) > limit {
+		comments = comments[:limit]
+		metadata.NextCursor = encodeCursor(comments[len(comments)-1].Id)
+	}
+	if len(comments) == 0 {
+		return comments, metadata, nil
+	}
+
+	threads := make(map[int]*Comment, len(comments))
+	for _, comment := range comments {
+		comment.Replies = []*Comment{}
+		threads[comment.Id] = comment
+	}
+
+	query = `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.event_id = $1 AND c.parent_id BETWEEN $2 AND $3 AND c.deleted_at IS NULL
+		ORDER BY c.id
+	`
+	rows, err = m.DB.QueryContext(ctx, query, eventId, comments[0].Id, comments[len(comments)-1].Id)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		reply, err := scanComment(rows)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		if thread, ok := threads[*reply.ParentId]; ok {
+			thread.Replies = append(thread.Replies, reply)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return comments, metadata, nil
+}
+
+/*
+GetByEvent returns a page of threads, oldest first, each with all of its
+replies, with the same opaque cursors as EventModel.GetAll. Total counts
+the threads, not the replies. The replies of the whole page are loaded
+with one query: the threads of a page are the visible ones between its
+first and last id, so every reply with a parent in that range belongs to
+one of them.
+*/
+
+func (m CommentModel) Count(ctx context.Context, eventId int) (int, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "comments.count")
+	defer cancel()
+
+	var count int
+	query := "SELECT COUNT(*) FROM comments WHERE event_id = $1 AND deleted_at IS NULL"
+	err := m.DB.QueryRowContext(ctx, query, eventId).Scan(&count)
+	return count, err
+}
+
+// Count counts the comments of an event that were not deleted, replies
+// included.
+
+func (m CommentModel) Update(ctx context.Context, comment *Comment) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "comments.update")
+	defer cancel()
+
+	updatedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	query := `
+		UPDATE comments SET body = $1, updated_at = $2
+		WHERE id = $3 AND event_id = $4 AND deleted_at IS NULL
+	`
+	result, err := m.DB.ExecContext(ctx, query, comment.Body, updatedAt, comment.Id, comment.EventId)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil || updated == 0 {
+		return false, err
+	}
+
+	comment.UpdatedAt = &updatedAt
+	return true, nil
+}
+
+func (m CommentModel) Delete(ctx context.Context, eventId, id, deletedBy int) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "comments.delete")
+	defer cancel()
+
+	query := `
+		UPDATE comments SET deleted_at = $1, deleted_by = $2
+		WHERE id = $3 AND event_id = $4 AND deleted_at IS NULL
+	`
+	result, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), deletedBy, id, eventId)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// Update and Delete return false if the event has no comment with this id
+// or it was deleted in the meantime.
@@ -0,0 +1,1169 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/schlafer/EventApp/internal/database"
+	"github.com/schlafer/EventApp/internal/database/dbtest"
+)
+
+/*
+The contract tests describe how every implementation of the repositories
+behaves, whatever database is behind them. Each case runs against SQLite
+and, if TEST_DATABASE_URL is set, against PostgreSQL, with a fresh
+database every time. NewModels picks the implementations of the dialect,
+so the PostgreSQL run covers PostgresEventModel.Search as well.
+*/
+
+var contractTests = []struct {
+	name string
+	test func(t *testing.T, models database.Models)
+}{
+	{"users are stored and found by id and email", testUsersInsertAndGet},
+	{"users can be verified, promoted, suspended and deleted", testUsersLifecycle},
+	{"sessions rotate their refresh token and detect reuse", testSessionsRotate},
+	{"sessions rotate a token only once when racing", testSessionsRotateConcurrently},
+	{"events are stored as drafts with all their fields", testEventsInsertAndGet},
+	{"events are only updated from the current version", testEventsUpdateVersion},
+	{"events of a batch are stored all or not at all", testEventsInsertMany},
+	{"events are paginated in every sort order", testEventsPagination},
+	{"events search finds public published events by prefix", testEventsSearch},
+	{"events only take the allowed status transitions", testEventsSetStatus},
+	{"events are deleted together with their attendees", testEventsDelete},
+	{"recurring events expand their rule without the excluded dates", testEventsOccurrences},
+	{"recurring events split in two keep their count, occurrences, members and invites", testEventsSplitSeries},
+	{"recurring events keep their local time across DST changes", testEventsTimeZones},
+	{"attendees beyond the capacity go to the waitlist", testAttendeesWaitlist},
+	{"attendees change their RSVP and free their spot", testAttendeesSetStatus},
+	{"attendees never exceed the capacity when racing", testAttendeesConcurrentCapacity},
+	{"attendees use an invite only with a saved RSVP", testAttendeesInvite},
+	{"attendees of an event and events of a user without any are empty lists", testAttendeesEmpty},
+	{"attendees are checked in once", testAttendeesCheckIn},
+	{"notifications are stored with the change they are about", testWebhooksEnqueue},
+	{"members that lose the co-host role lose the webhooks of the event", testWebhooksOfFormerCoHosts},
+	{"notifications that cannot be stored roll the change back", testWebhooksEnqueueFails},
+}
+
+func TestRepositoryContract(t *testing.T) {
+	for _, tc := range contractTests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbtest.ForEachDialect(t, func(t *testing.T, db *sql.DB, dialect string) {
+				tc.test(t, database.NewModels(db, dialect, database.Timeouts{Default: 10 * time.Second}))
+			})
+		})
+	}
+}
+
+var userCount int
+
+func newUser(t *testing.T, models database.Models) *database.User {
+	t.Helper()
+
+	userCount++
+	user := &database.User{
+		Email:    fmt.Sprintf("user%d@example.com", userCount),
+		Name:     fmt.Sprintf("User %d", userCount),
+		Password: "hash",
+	}
+	if err := models.Users.Insert(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func newEvent(t *testing.T, models database.Models, owner *database.User, change func(*database.Event)) *database.Event {
+	t.Helper()
+
+	startsAt := time.Date(2026, 11, 5, 18, 0, 0, 0, time.UTC)
+	event := &database.Event{
+		Name:        "Go meetup",
+		Description: "Talks about Go and databases",
+		StartsAt:    startsAt,
+		EndsAt:      startsAt.Add(3 * time.Hour),
+		Location:    "Berlin",
+		OwnerId:     owner.Id,
+		Status:      database.EventPublished,
+	}
+	if change != nil {
+		change(event)
+	}
+
+	if err := models.Events.Insert(context.Background(), event, nil); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// newUser and newEvent store a user and a published public event with
+// valid defaults, change adjusts the event before it is inserted.
+
+func capacity(n int) *int {
+	return &n
+}
+
+func goingCount(t *testing.T, models database.Models, eventId int) int {
+	t.Helper()
+
+	users, err := models.Attendees.GetAttendeesByEvent(context.Background(), eventId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(users)
+}
+
+func testUsersInsertAndGet(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	user := newUser(t, models)
+
+	byId, err := models.Users.Get(ctx, user.Id)
+	if err != nil || byId == nil {
+		t.Fatalf("Get(%d) = %v, %v", user.Id, byId, err)
+	}
+	if byId.Email != user.Email || byId.Name != user.Name || byId.Role != database.RoleUser {
+		t.Errorf("Get(%d) = %+v, want %+v with role user", user.Id, byId, user)
+	}
+
+	byEmail, err := models.Users.GetByEmail(ctx, user.Email)
+	if err != nil || byEmail == nil || byEmail.Id != user.Id {
+		t.Errorf("GetByEmail(%q) = %v, %v", user.Email, byEmail, err)
+	}
+
+	missing, err := models.Users.Get(ctx, user.Id+1000)
+	if err != nil || missing != nil {
+		t.Errorf("Get of a missing user = %v, %v, want nil, nil", missing, err)
+	}
+
+	duplicate := &database.User{Email: user.Email, Name: "Other", Password: "hash"}
+	if err := models.Users.Insert(ctx, duplicate); err == nil {
+		t.Error("inserting a second user with the same email succeeded")
+	}
+}
+
+func testUsersLifecycle(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	user := newUser(t, models)
+
+	if err := models.Users.MarkVerified(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Users.SetRole(ctx, user.Id, database.RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Users.SetSuspended(ctx, user.Id, true); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := models.Users.Get(ctx, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.VerifiedAt == nil || stored.SuspendedAt == nil || stored.Role != database.RoleModerator {
+		t.Errorf("user after verify, promote and suspend = %+v", stored)
+	}
+
+	if err := models.Users.Delete(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := models.Users.Get(ctx, user.Id); err != nil || deleted != nil {
+		t.Errorf("Get of a deleted user = %v, %v, want nil, nil", deleted, err)
+	}
+}
+
+func testEventsInsertAndGet(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	owner := newUser(t, models)
+
+	berlin := time.FixedZone("CET", 3600)
+	event := newEvent(t, models, owner, func(e *database.Event) {
+		e.Status = ""
+		e.StartsAt = time.Date(2026, 11, 5, 18, 0, 0, 500, berlin)
+		e.EndsAt = e.StartsAt.Add(2 * time.Hour)
+		e.Timezone = "Europe/Berlin"
+		e.Capacity = capacity(20)
+		e.Rrule = "FREQ=WEEKLY;COUNT=4"
+		e.ExDates = database.DateList{"2026-11-12"}
+		e.Visibility = database.VisibilityUnlisted
+	})
+
+	if event.Id == 0 || event.Version != 1 || event.Status != database.EventDraft {
+		t.Fatalf("inserted event = %+v, want an id, version 1 and draft", event)
+	}
+
+	stored, err := models.Events.Get(ctx, event.Id)
+	if err != nil || stored == nil {
+		t.Fatalf("Get(%d) = %v, %v", event.Id, stored, err)
+	}
+
+	want := time.Date(2026, 11, 5, 17, 0, 0, 0, time.UTC)
+	if !stored.StartsAt.Equal(want) || !stored.EndsAt.Equal(want.Add(2*time.Hour)) {
+		t.Errorf("stored times = %v - %v, want %v and two hours later", stored.StartsAt, stored.EndsAt, want)
+	}
+	if stored.Timezone != "Europe/Berlin" || stored.Capacity == nil || *stored.Capacity != 20 {
+		t.Errorf("stored event = %+v", stored)
+	}
+	if stored.Rrule != event.Rrule || len(stored.ExDates) != 1 || stored.ExDates[0] != "2026-11-12" {
+		t.Errorf("stored recurrence = %q %v", stored.Rrule, stored.ExDates)
+	}
+	if stored.Visibility != database.VisibilityUnlisted || stored.Status != database.EventDraft || stored.Version != 1 {
+		t.Errorf("stored event = %+v", stored)
+	}
+
+	missing, err := models.Events.Get(ctx, event.Id+1000)
+	if err != nil || missing != nil {
+		t.Errorf("Get of a missing event = %v, %v, want nil, nil", missing, err)
+	}
+}
+
+func testEventsUpdateVersion(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	event := newEvent(t, models, newUser(t, models), nil)
+
+	stale := *event
+
+	event.Name = "Go meetup, second edition"
+	if err := models.Events.Update(ctx, event, nil); err != nil {
+		t.Fatal(err)
+	}
+	if event.Version != 2 {
+		t.Errorf("version after update = %d, want 2", event.Version)
+	}
+
+	stale.Name = "Overwritten"
+	if err := models.Events.Update(ctx, &stale, nil); !errors.Is(err, database.ErrEditConflict) {
+		t.Errorf("update of a stale version = %v, want ErrEditConflict", err)
+	}
+
+	stored, err := models.Events.Get(ctx, event.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != event.Name || stored.Version != 2 {
+		t.Errorf("stored event = %q version %d, want %q version 2", stored.Name, stored.Version, event.Name)
+	}
+}
+
+func testEventsInsertMany(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	owner := newUser(t, models)
+
+	batch := func(ownerIds ...int) []*database.Event {
+		var events []*database.Event
+		for i, ownerId := range ownerIds {
+			startsAt := time.Date(2026, 11, 5+i, 18, 0, 0, 0, time.UTC)
+			events = append(events, &database.Event{
+				Name: fmt.Sprintf("Go meetup %d", i+1), Description: "Talks about Go and databases", Location: "Berlin",
+				StartsAt: startsAt, EndsAt: startsAt.Add(3 * time.Hour), OwnerId: ownerId,
+			})
+		}
+		return events
+	}
+
+	tests := []struct {
+		name   string
+		events []*database.Event
+		err    bool
+		stored int
+	}{
+		{"a batch with an unknown owner", batch(owner.Id, owner.Id+1000, owner.Id), true, 0},
+		{"a valid batch", batch(owner.Id, owner.Id, owner.Id), false, 3},
+	}
+
+	for _, tc := range tests {
+		err := models.Events.InsertMany(ctx, tc.events)
+		if (err != nil) != tc.err {
+			t.Errorf("InsertMany of %s = %v, want an error %v", tc.name, err, tc.err)
+		}
+
+		_, metadata, err := models.Events.GetAll(ctx, database.EventFilters{OwnerId: owner.Id, ShowHidden: true, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata.Total != tc.stored {
+			t.Errorf("events after %s = %d, want %d", tc.name, metadata.Total, tc.stored)
+		}
+	}
+}
+
+func testEventsPagination(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	owner := newUser(t, models)
+
+	names := []string{"Delta", "Alpha", "Echo", "Charlie", "Bravo"}
+	byName := map[string]int{}
+	for i, name := range names {
+		event := newEvent(t, models, owner, func(e *database.Event) {
+			e.Name = name
+			e.StartsAt = e.StartsAt.Add(time.Duration(i) * 24 * time.Hour)
+			e.EndsAt = e.StartsAt.Add(time.Hour)
+		})
+		byName[name] = event.Id
+	}
+
+	orders := map[string][]string{
+		"":      {"Delta", "Alpha", "Echo", "Charlie", "Bravo"},
+		"-date": {"Bravo", "Charlie", "Echo", "Alpha", "Delta"},
+		"name":  {"Alpha", "Bravo", "Charlie", "Delta", "Echo"},
+		"-name": {"Echo", "Delta", "Charlie", "Bravo", "Alpha"},
+	}
+
+	for sort, want := range orders {
+		var got []string
+		cursor := ""
+		for {
+			events, metadata, err := models.Events.GetAll(ctx, database.EventFilters{Sort: sort, Limit: 2, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("GetAll(sort %q) = %v", sort, err)
+			}
+			if metadata.Total != len(names) {
+				t.Errorf("total = %d, want %d", metadata.Total, len(names))
+			}
+			for _, event := range events {
+				got = append(got, event.Name)
+			}
+			if metadata.NextCursor == "" {
+				break
+			}
+			cursor = metadata.NextCursor
+		}
+
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("sort %q = %v, want %v", sort, got, want)
+		}
+	}
+
+	events, metadata, err := models.Events.GetAll(ctx, database.EventFilters{Sort: "name", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Events.Delete(ctx, events[1].Id, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _, err = models.Events.GetAll(ctx, database.EventFilters{Sort: "name", Limit: 2, Cursor: metadata.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Id != byName["Charlie"] || events[1].Id != byName["Delta"] {
+		t.Errorf("page after a deleted cursor event = %v, want Charlie and Delta", events)
+	}
+
+	_, _, err = models.Events.GetAll(ctx, database.EventFilters{Sort: "-date", Limit: 2, Cursor: metadata.NextCursor})
+	if !errors.Is(err, database.ErrInvalidCursor) {
+		t.Errorf("cursor of another sort order = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func testEventsSearch(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	owner := newUser(t, models)
+
+	jazz := newEvent(t, models, owner, func(e *database.Event) {
+		e.Name = "Jazz night"
+		e.Description = "An evening of live jazz in the park"
+		e.Location = "Stadtpark"
+	})
+	newEvent(t, models, owner, func(e *database.Event) {
+		e.Name = "Jazz draft"
+		e.Status = database.EventDraft
+	})
+	newEvent(t, models, owner, func(e *database.Event) {
+		e.Name = "Jazz in private"
+		e.Visibility = database.VisibilityPrivate
+	})
+	newEvent(t, models, owner, func(e *database.Event) {
+		e.Name = "Rock concert"
+	})
+
+	results, err := models.Events.Search(ctx, "jaz park", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Event.Id != jazz.Id {
+		t.Fatalf("Search = %v, want only the public published jazz event", results)
+	}
+	if !strings.Contains(results[0].Highlights.Name, "<mark>Jazz</mark>") ||
+		!strings.Contains(results[0].Highlights.Description, "<mark>park</mark>") {
+		t.Errorf("highlights = %+v", results[0].Highlights)
+	}
+
+	jazz.Name = "Blues night"
+	jazz.Description = "An evening of live blues"
+	if err := models.Events.Update(ctx, jazz, nil); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := models.Events.Search(ctx, "jazz", 10); err != nil || len(results) != 0 {
+		t.Errorf("Search after renaming = %v, %v, want nothing", results, err)
+	}
+
+	if results, err := models.Events.Search(ctx, `"-: &|!`, 10); err != nil || len(results) != 0 {
+		t.Errorf("Search for operators only = %v, %v, want nothing and no error", results, err)
+	}
+}
+
+func testEventsSetStatus(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	event := newEvent(t, models, newUser(t, models), func(e *database.Event) {
+		e.Status = database.EventDraft
+	})
+
+	stale := *event
+
+	if err := models.Events.SetStatus(ctx, event, database.EventPublished, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != database.EventPublished || event.Version != 2 {
+		t.Errorf("published event = %s version %d", event.Status, event.Version)
+	}
+
+	if err := models.Events.SetStatus(ctx, &stale, database.EventPublished, "", nil); !errors.Is(err, database.ErrEditConflict) {
+		t.Errorf("publishing a stale draft = %v, want ErrEditConflict", err)
+	}
+	if err := models.Events.SetStatus(ctx, event, database.EventDraft, "", nil); !errors.Is(err, database.ErrInvalidTransition) {
+		t.Errorf("published to draft = %v, want ErrInvalidTransition", err)
+	}
+
+	if err := models.Events.SetStatus(ctx, event, database.EventCancelled, "Rain", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := models.Events.Get(ctx, event.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != database.EventCancelled || stored.CancellationReason != "Rain" || stored.CancelledAt == nil {
+		t.Errorf("cancelled event = %+v", stored)
+	}
+}
+
+func testEventsDelete(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	owner := newUser(t, models)
+	event := newEvent(t, models, owner, nil)
+
+	attendee := &database.Attendee{EventId: event.Id, UserId: newUser(t, models).Id}
+	if _, err := models.Attendees.Insert(ctx, attendee); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Events.Delete(ctx, event.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored, err := models.Events.Get(ctx, event.Id); err != nil || stored != nil {
+		t.Errorf("Get of a deleted event = %v, %v, want nil, nil", stored, err)
+	}
+	if stored, err := models.Attendees.GetByEventAndAttendee(ctx, event.Id, attendee.UserId); err != nil || stored != nil {
+		t.Errorf("attendee of a deleted event = %v, %v, want nil, nil", stored, err)
+	}
+}
+
+func testAttendeesWaitlist(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	event := newEvent(t, models, newUser(t, models), func(e *database.Event) {
+		e.Capacity = capacity(2)
+	})
+
+	users := []*database.User{newUser(t, models), newUser(t, models), newUser(t, models)}
+
+	var entry *database.WaitlistEntry
+	for i, user := range users {
+		var err error
+		entry, err = models.Attendees.InsertOrWaitlist(ctx, &database.Attendee{EventId: event.Id, UserId: user.Id}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (entry != nil) != (i == 2) {
+			t.Fatalf("user %d waitlisted = %v, only the third should be", i, entry)
+		}
+	}
+	if entry.Position != 1 {
+		t.Errorf("waitlist position = %d, want 1", entry.Position)
+	}
+
+	_, err := models.Attendees.InsertOrWaitlist(ctx, &database.Attendee{EventId: event.Id, UserId: users[0].Id}, nil)
+	if !errors.Is(err, database.ErrDuplicateAttendee) {
+		t.Errorf("adding an attendee twice = %v, want ErrDuplicateAttendee", err)
+	}
+	_, err = models.Attendees.InsertOrWaitlist(ctx, &database.Attendee{EventId: event.Id, UserId: users[2].Id}, nil)
+	if !errors.Is(err, database.ErrAlreadyWaitlisted) {
+		t.Errorf("waitlisting a user twice = %v, want ErrAlreadyWaitlisted", err)
+	}
+
+	promoted, err := models.Attendees.Delete(ctx, users[0].Id, event.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promoted) != 1 || promoted[0].UserId != users[2].Id || promoted[0].Status != database.StatusGoing {
+		t.Errorf("promoted = %v, want the waitlisted user", promoted)
+	}
+	if going := goingCount(t, models, event.Id); going != 2 {
+		t.Errorf("going = %d, want 2", going)
+	}
+}
+
+func testAttendeesSetStatus(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	event := newEvent(t, models, newUser(t, models), func(e *database.Event) {
+		e.Capacity = capacity(1)
+	})
+	first, second := newUser(t, models), newUser(t, models)
+
+	attendee, entry, err := models.Attendees.SetStatus(ctx, event.Id, first.Id, database.StatusGoing, 0, nil)
+	if err != nil || entry != nil || attendee.Status != database.StatusGoing {
+		t.Fatalf("first going = %v, %v, %v", attendee, entry, err)
+	}
+
+	attendee, entry, err = models.Attendees.SetStatus(ctx, event.Id, second.Id, database.StatusGoing, 0, nil)
+	if err != nil || attendee != nil || entry == nil {
+		t.Fatalf("second going on a full event = %v, %v, %v, want a waitlist entry", attendee, entry, err)
+	}
+
+	if _, _, err := models.Attendees.SetStatus(ctx, event.Id, first.Id, database.StatusMaybe, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rsvps, err := models.Attendees.GetRSVPsByEvent(ctx, event.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[int]string{}
+	for _, rsvp := range rsvps {
+		statuses[rsvp.UserId] = rsvp.Status
+	}
+	if statuses[first.Id] != database.StatusMaybe || statuses[second.Id] != database.StatusGoing {
+		t.Errorf("RSVPs = %v, want the first maybe and the promoted second going", statuses)
+	}
+
+	if waiting, err := models.Waitlist.GetByEvent(ctx, event.Id); err != nil || len(waiting) != 0 {
+		t.Errorf("waitlist = %v, %v, want it empty", waiting, err)
+	}
+}
+
+func testAttendeesConcurrentCapacity(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	event := newEvent(t, models, newUser(t, models), func(e *database.Event) {
+		e.Capacity = capacity(3)
+	})
+
+	users := make([]*database.User, 12)
+	for i := range users {
+		users[i] = newUser(t, models)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(users))
+	for _, user := range users {
+		wg.Add(1)
+		go func(user *database.User) {
+			defer wg.Done()
+			_, _, err := models.Attendees.SetStatus(ctx, event.Id, user.Id, database.StatusGoing, 0, nil)
+			errs <- err
+		}(user)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if going := goingCount(t, models, event.Id); going != 3 {
+		t.Errorf("going = %d, want the capacity of 3", going)
+	}
+
+	waiting, err := models.Waitlist.GetByEvent(ctx, event.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(waiting) != len(users)-3 {
+		t.Fatalf("waitlisted = %d, want %d", len(waiting), len(users)-3)
+	}
+	for i, entry := range waiting {
+		if entry.Position != i+1 {
+			t.Errorf("waitlist positions = %v, want 1 to %d without gaps", waiting, len(waiting))
+			break
+		}
+	}
+}
+
+/*
+Twelve users race for three spots. The transactions only stay correct if
+availableSpots locks the event, with the no-op UPDATE ... RETURNING in
+PostgreSQL and the immediate transactions in SQLite; otherwise several of
+them count the same free spot and the capacity is exceeded.
+*/
+
+func testAttendeesInvite(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	owner := newUser(t, models)
+	event := newEvent(t, models, owner, func(e *database.Event) {
+		e.Visibility = database.VisibilityPrivate
+	})
+
+	invite := &database.Invite{EventId: event.Id, CreatedBy: owner.Id, MaxUses: capacity(1)}
+	if err := models.Invites.Insert(ctx, invite); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := newUser(t, models), newUser(t, models)
+
+	if _, _, err := models.Attendees.SetStatus(ctx, event.Id, first.Id, database.StatusGoing, invite.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := models.Attendees.SetStatus(ctx, event.Id, second.Id, database.StatusGoing, invite.Id, nil)
+	if !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("RSVP through a used up invite = %v, want ErrInvalidToken", err)
+	}
+	if attendee, err := models.Attendees.GetByEventAndAttendee(ctx, event.Id, second.Id); err != nil || attendee != nil {
+		t.Errorf("attendee of a refused invite = %v, %v, want nil, nil", attendee, err)
+	}
+
+	stored, err := models.Invites.Get(ctx, invite.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Uses != 1 {
+		t.Errorf("uses = %d, want 1", stored.Uses)
+	}
+}
+
+func testAttendeesEmpty(t *testing.T, models database.Models) {
+	users, err := models.Attendees.GetAttendeesByEvent(context.Background(), newEvent(t, models, newUser(t, models), nil).Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users == nil || len(users) != 0 {
+		t.Errorf("attendees = %#v, want an empty list", users)
+	}
+
+	events, err := models.Attendees.GetEventsByAttendee(context.Background(), newUser(t, models).Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events == nil || len(events) != 0 {
+		t.Errorf("events of a user = %#v, want an empty list", events)
+	}
+}
+
+func testAttendeesCheckIn(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	event := newEvent(t, models, newUser(t, models), nil)
+	user := newUser(t, models)
+
+	attendee := &database.Attendee{EventId: event.Id, UserId: user.Id}
+	if _, err := models.Attendees.Insert(ctx, attendee); err != nil {
+		t.Fatal(err)
+	}
+
+	checkedIn, err := models.Attendees.CheckIn(ctx, event.Id, attendee.Id, user.Id)
+	if err != nil || checkedIn == nil || checkedIn.CheckedInAt == nil {
+		t.Fatalf("CheckIn = %v, %v", checkedIn, err)
+	}
+
+	if _, err := models.Attendees.CheckIn(ctx, event.Id, attendee.Id, user.Id); !errors.Is(err, database.ErrAlreadyCheckedIn) {
+		t.Errorf("second CheckIn = %v, want ErrAlreadyCheckedIn", err)
+	}
+}
+
+func testWebhooksEnqueue(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	owner := newUser(t, models)
+	event := newEvent(t, models, owner, func(e *database.Event) {
+		e.Capacity = capacity(1)
+	})
+	first, second := newUser(t, models), newUser(t, models)
+
+	enqueue := func(ctx context.Context, tx *sql.Tx) error {
+		return models.Webhooks.Enqueue(ctx, tx, &database.Notification{
+			Type:    database.WebhookEventUpdated,
+			EventId: event.Id,
+			OwnerId: owner.Id,
+			Payload: []byte("{}"),
+		})
+	}
+
+	var changes []database.RSVPChange
+	enqueueRSVPs := func(ctx context.Context, tx *sql.Tx, rsvps []database.RSVPChange) error {
+		changes = append(changes, rsvps...)
+		return enqueue(ctx, tx)
+	}
+
+	event.Name = "Go meetup #2"
+	if err := models.Events.Update(ctx, event, enqueue); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := models.Attendees.SetStatus(ctx, event.Id, first.Id, database.StatusGoing, 0, enqueueRSVPs); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := models.Attendees.SetStatus(ctx, event.Id, second.Id, database.StatusGoing, 0, enqueueRSVPs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.Attendees.Delete(ctx, first.Id, event.Id, enqueueRSVPs); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []database.RSVPChange{
+		{UserId: first.Id, After: database.StatusGoing},
+		{UserId: first.Id, Before: database.StatusGoing},
+		{UserId: second.Id, After: database.StatusGoing},
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+
+	dispatched, err := models.Webhooks.Dispatch(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispatched != 3 {
+		t.Errorf("dispatched = %d, want the 3 notifications", dispatched)
+	}
+}
+
+// The second user goes to the waitlist, so enqueue is only called for the
+// update, the answer of the first user and their removal, which moves the
+// second user up.
+
+func testWebhooksEnqueueFails(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	event := newEvent(t, models, newUser(t, models), nil)
+	user := newUser(t, models)
+
+	failed := errors.New("outbox unavailable")
+	enqueue := func(ctx context.Context, tx *sql.Tx) error { return failed }
+	enqueueRSVPs := func(ctx context.Context, tx *sql.Tx, changes []database.RSVPChange) error { return failed }
+
+	changed := *event
+	changed.Name = "Renamed"
+	if err := models.Events.Update(ctx, &changed, enqueue); !errors.Is(err, failed) {
+		t.Errorf("Update = %v, want the error of enqueue", err)
+	}
+	if err := models.Events.Delete(ctx, event.Id, enqueue); !errors.Is(err, failed) {
+		t.Errorf("Delete = %v, want the error of enqueue", err)
+	}
+	if _, _, err := models.Attendees.SetStatus(ctx, event.Id, user.Id, database.StatusGoing, 0, enqueueRSVPs); !errors.Is(err, failed) {
+		t.Errorf("SetStatus = %v, want the error of enqueue", err)
+	}
+
+	stored, err := models.Events.Get(ctx, event.Id)
+	if err != nil || stored == nil {
+		t.Fatalf("Get = %v, %v, want the event", stored, err)
+	}
+	if stored.Name != event.Name || stored.Version != event.Version {
+		t.Errorf("event = %q version %d, want it unchanged", stored.Name, stored.Version)
+	}
+	if attendee, err := models.Attendees.GetByEventAndAttendee(ctx, event.Id, user.Id); err != nil || attendee != nil {
+		t.Errorf("attendee = %v, %v, want nil, nil", attendee, err)
+	}
+}
+
+func testSessionsRotate(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+
+	session, err := models.Sessions.Insert(ctx, newUser(t, models).Id, "first", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := models.Sessions.Insert(ctx, newUser(t, models).Id, "expired", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		token   string
+		next    string
+		err     error
+		revoked bool
+	}{
+		{"the current token is rotated", "first", "second", nil, false},
+		{"the new token is rotated again", "second", "third", nil, false},
+		{"an unknown token is refused", "unknown", "other", database.ErrInvalidRefreshToken, false},
+		{"an old token revokes the session", "second", "fourth", database.ErrRefreshTokenReused, true},
+		{"the current token of a revoked session is refused", "third", "fifth", database.ErrInvalidRefreshToken, true},
+		{"the token of an expired session is refused", "expired", "sixth", database.ErrInvalidRefreshToken, false},
+	}
+
+	for _, step := range steps {
+		rotated, err := models.Sessions.Rotate(ctx, step.token, step.next, expires)
+		if !errors.Is(err, step.err) {
+			t.Fatalf("%s: Rotate = %v, want %v", step.name, err, step.err)
+		}
+		if err == nil && rotated.Id != session.Id {
+			t.Errorf("%s: rotated session %d, want %d", step.name, rotated.Id, session.Id)
+		}
+		if step.token == "expired" {
+			continue
+		}
+
+		stored, err := models.Sessions.Get(ctx, session.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (stored.RevokedAt != nil) != step.revoked {
+			t.Errorf("%s: session revoked = %v, want %v", step.name, stored.RevokedAt != nil, step.revoked)
+		}
+	}
+
+	if stored, err := models.Sessions.Get(ctx, expired.Id); err != nil || stored.RevokedAt != nil {
+		t.Errorf("expired session = %v, %v, want it left as it is", stored, err)
+	}
+}
+
+// The steps run in order against the same session, each one starts from
+// the state the previous one left behind.
+
+func testSessionsRotateConcurrently(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+
+	session, err := models.Sessions.Insert(ctx, newUser(t, models).Id, "stolen", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const callers = 8
+	results := make(chan error, callers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := models.Sessions.Rotate(ctx, "stolen", fmt.Sprintf("next-%d", i), expires)
+			results <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+
+	rotated, reused := 0, 0
+	for err := range results {
+		switch {
+		case err == nil:
+			rotated++
+		case errors.Is(err, database.ErrRefreshTokenReused):
+			reused++
+		case !errors.Is(err, database.ErrInvalidRefreshToken):
+			t.Errorf("Rotate = %v, want success, ErrRefreshTokenReused or ErrInvalidRefreshToken", err)
+		}
+	}
+	if rotated != 1 || reused == 0 {
+		t.Errorf("rotated %d times and detected reuse %d times, want 1 and at least 1", rotated, reused)
+	}
+
+	stored, err := models.Sessions.Get(ctx, session.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RevokedAt == nil {
+		t.Error("the session of a token that was refreshed twice is still active")
+	}
+}
+
+// The first caller that loses the race detects the reuse and revokes the
+// session, the ones after it find a revoked session and are refused.
+
+func occurrences(t *testing.T, models database.Models, series *database.Event) []*database.Occurrence {
+	t.Helper()
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	list, err := models.Events.GetOccurrences(context.Background(), series, from, from.AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func occurrenceDates(list []*database.Occurrence) string {
+	var dates []string
+	for _, occurrence := range list {
+		dates = append(dates, occurrence.Date)
+	}
+	return strings.Join(dates, " ")
+}
+
+// occurrences expands a series over the whole of 2026, occurrenceDates
+// lists the dates of the expansion separated by spaces.
+
+func testEventsOccurrences(t *testing.T, models database.Models) {
+	owner := newUser(t, models)
+
+	tests := []struct {
+		rule    string
+		exdates database.DateList
+		dates   string
+	}{
+		{"", nil, "2026-11-05"},
+		{"FREQ=WEEKLY;COUNT=3", nil, "2026-11-05 2026-11-12 2026-11-19"},
+		{"FREQ=WEEKLY;COUNT=3", database.DateList{"2026-11-12"}, "2026-11-05 2026-11-19"},
+		{"FREQ=DAILY;INTERVAL=2;COUNT=3", nil, "2026-11-05 2026-11-07 2026-11-09"},
+		{"FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20261112T235959Z", nil, "2026-11-05 2026-11-10 2026-11-12"},
+		{"FREQ=MONTHLY;BYMONTHDAY=5;COUNT=3", database.DateList{"2026-12-05"}, "2026-11-05"},
+	}
+
+	for _, tc := range tests {
+		series := newEvent(t, models, owner, func(e *database.Event) {
+			e.Rrule = tc.rule
+			e.ExDates = tc.exdates
+		})
+
+		if got := occurrenceDates(occurrences(t, models, series)); got != tc.dates {
+			t.Errorf("occurrences of %q without %v = %q, want %q", tc.rule, tc.exdates, got, tc.dates)
+		}
+	}
+}
+
+func TestNormalizeRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+		err  bool
+	}{
+		{"", "", false},
+		{"RRULE:FREQ=WEEKLY;COUNT=4", "FREQ=WEEKLY;COUNT=4", false},
+		{"FREQ=HOURLY;COUNT=4", "", true},
+		{"DTSTART:20261105T180000Z;FREQ=DAILY", "", true},
+		{"FREQ=SOMETIMES", "", true},
+	}
+
+	for _, tc := range tests {
+		got, err := database.NormalizeRule(tc.rule)
+		if tc.err != errors.Is(err, database.ErrInvalidRecurrence) || got != tc.want {
+			t.Errorf("NormalizeRule(%q) = %q, %v, want %q and an error %v", tc.rule, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func testEventsSplitSeries(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	series := newEvent(t, models, newUser(t, models), func(e *database.Event) {
+		e.Rrule = "FREQ=WEEKLY;COUNT=5"
+		e.ExDates = database.DateList{"2026-12-03"}
+	})
+
+	before, err := models.Events.GetOrCreateOccurrence(ctx, series, "2026-11-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := models.Events.GetOrCreateOccurrence(ctx, series, "2026-11-26")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coHost := newUser(t, models)
+	if err := models.Members.Insert(ctx, &database.Member{EventId: series.Id, UserId: coHost.Id, Role: database.MemberCoHost}); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Invites.Insert(ctx, &database.Invite{EventId: series.Id, CreatedBy: series.OwnerId, MaxUses: capacity(5)}); err != nil {
+		t.Fatal(err)
+	}
+
+	following := *series
+	following.Id = 0
+	following.Name = "Go meetup in the new room"
+	following.StartsAt = time.Date(2026, 11, 19, 18, 0, 0, 0, time.UTC)
+	following.EndsAt = following.StartsAt.Add(3 * time.Hour)
+	following.ExDates = nil
+
+	if err := models.Events.SplitSeries(ctx, series, "2026-11-19", &following, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(following.Rrule, "COUNT=3") {
+		t.Errorf("rule of the following series = %q, want the remaining COUNT=3", following.Rrule)
+	}
+	if len(series.ExDates) != 0 || len(following.ExDates) != 1 || following.ExDates[0] != "2026-12-03" {
+		t.Errorf("excluded dates = %v and %v, want none and 2026-12-03", series.ExDates, following.ExDates)
+	}
+
+	tests := []struct {
+		name   string
+		series *database.Event
+		dates  string
+		stored *database.Event
+	}{
+		{"series", series, "2026-11-05 2026-11-12", before},
+		{"following", &following, "2026-11-19 2026-11-26", after},
+	}
+
+	for _, tc := range tests {
+		stored, err := models.Events.Get(ctx, tc.series.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		list := occurrences(t, models, stored)
+		if got := occurrenceDates(list); got != tc.dates {
+			t.Errorf("occurrences of the %s = %q, want %q", tc.name, got, tc.dates)
+			continue
+		}
+
+		occurrence := list[1].Event
+		if occurrence.Id != tc.stored.Id || occurrence.SeriesId == nil || *occurrence.SeriesId != tc.series.Id {
+			t.Errorf("stored occurrence of the %s = %+v, want event %d of series %d", tc.name, occurrence, tc.stored.Id, tc.series.Id)
+		}
+		if occurrence.Name != tc.series.Name {
+			t.Errorf("stored occurrence of the %s is called %q, want %q", tc.name, occurrence.Name, tc.series.Name)
+		}
+
+		role, err := models.Members.GetRole(ctx, tc.series.Id, coHost.Id)
+		if err != nil || role != database.MemberCoHost {
+			t.Errorf("role of the co-host in the %s = %q, %v, want %q", tc.name, role, err, database.MemberCoHost)
+		}
+
+		invites, err := models.Invites.GetByEvent(ctx, tc.series.Id)
+		if err != nil || len(invites) != 1 || invites[0].MaxUses == nil || *invites[0].MaxUses != 5 {
+			t.Errorf("invites of the %s = %v, %v, want one for 5 uses", tc.name, invites, err)
+		}
+	}
+}
+
+func testEventsTimeZones(t *testing.T, models database.Models) {
+	owner := newUser(t, models)
+
+	tests := []struct {
+		zone   string
+		start  string
+		rule   string
+		starts []string
+		dates  []string
+	}{
+		{
+			"Europe/Berlin", "2026-10-22T18:00:00", "FREQ=WEEKLY;COUNT=2",
+			[]string{"2026-10-22T16:00:00Z", "2026-10-29T17:00:00Z"},
+			[]string{"2026-10-22", "2026-10-29"},
+		},
+		{
+			"Europe/Berlin", "2026-03-26T18:00:00", "FREQ=WEEKLY;COUNT=2",
+			[]string{"2026-03-26T17:00:00Z", "2026-04-02T16:00:00Z"},
+			[]string{"2026-03-26", "2026-04-02"},
+		},
+		{
+			"America/New_York", "2026-10-30T21:00:00", "FREQ=DAILY;COUNT=3",
+			[]string{"2026-10-31T01:00:00Z", "2026-11-01T01:00:00Z", "2026-11-02T02:00:00Z"},
+			[]string{"2026-10-30", "2026-10-31", "2026-11-01"},
+		},
+	}
+
+	for _, tc := range tests {
+		zone, err := time.LoadLocation(tc.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, err := time.ParseInLocation("2006-01-02T15:04:05", tc.start, zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		series := newEvent(t, models, owner, func(e *database.Event) {
+			e.StartsAt = start
+			e.EndsAt = start.Add(2 * time.Hour)
+			e.Timezone = tc.zone
+			e.Rrule = tc.rule
+		})
+
+		var starts, dates []string
+		for _, occurrence := range occurrences(t, models, series) {
+			starts = append(starts, occurrence.Event.StartsAt.UTC().Format(time.RFC3339))
+			dates = append(dates, occurrence.Date)
+
+			occurs, err := series.OccursOn(occurrence.Date)
+			if err != nil || !occurs {
+				t.Errorf("OccursOn(%s) of %s %s = %v, %v, want true", occurrence.Date, tc.zone, tc.start, occurs, err)
+			}
+		}
+
+		if fmt.Sprint(starts) != fmt.Sprint(tc.starts) || fmt.Sprint(dates) != fmt.Sprint(tc.dates) {
+			t.Errorf("occurrences of %s %s = %v on %v, want %v on %v", tc.zone, tc.start, starts, dates, tc.starts, tc.dates)
+		}
+	}
+}
+
+/*
+The series are expanded in their own zone: a weekly event at 18:00 stays
+at 18:00 Berlin time when summer time ends or begins, so its start in UTC
+moves by an hour. An evening in New York is already the next day in UTC,
+the occurrence is still on the local date.
+*/
+
+func testWebhooksOfFormerCoHosts(t *testing.T, models database.Models) {
+	ctx := context.Background()
+	owner := newUser(t, models)
+	event := newEvent(t, models, owner, nil)
+	otherEvent := newEvent(t, models, owner, nil)
+
+	webhook := func(user *database.User, eventId *int) *database.Webhook {
+		webhook := &database.Webhook{UserId: user.Id, EventId: eventId, URL: "https://example.com/hooks",
+			Events: database.WebhookTypes, Secret: "secret"}
+		if err := models.Webhooks.Insert(ctx, webhook); err != nil {
+			t.Fatal(err)
+		}
+		return webhook
+	}
+
+	tests := []struct {
+		name   string
+		change func(member *database.User) (bool, error)
+		active bool
+	}{
+		{"a co-host that stays co-host", func(member *database.User) (bool, error) {
+			return models.Members.SetRole(ctx, event.Id, member.Id, database.MemberCoHost)
+		}, true},
+		{"a co-host that becomes a viewer", func(member *database.User) (bool, error) {
+			return models.Members.SetRole(ctx, event.Id, member.Id, database.MemberViewer)
+		}, false},
+		{"a co-host that is removed", func(member *database.User) (bool, error) {
+			return models.Members.Delete(ctx, event.Id, member.Id)
+		}, false},
+	}
+
+	for _, tc := range tests {
+		member := newUser(t, models)
+		if err := models.Members.Insert(ctx, &database.Member{EventId: event.Id, UserId: member.Id, Role: database.MemberCoHost}); err != nil {
+			t.Fatal(err)
+		}
+		if err := models.Members.Insert(ctx, &database.Member{EventId: otherEvent.Id, UserId: member.Id, Role: database.MemberCoHost}); err != nil {
+			t.Fatal(err)
+		}
+
+		ofEvent := webhook(member, &event.Id)
+		kept := []*database.Webhook{webhook(member, nil), webhook(member, &otherEvent.Id), webhook(owner, &event.Id)}
+
+		if changed, err := tc.change(member); err != nil || !changed {
+			t.Fatalf("changing %s = %v, %v", tc.name, changed, err)
+		}
+
+		stored, err := models.Webhooks.Get(ctx, ofEvent.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Active != tc.active {
+			t.Errorf("webhook of the event of %s is active %v, want %v", tc.name, stored.Active, tc.active)
+		}
+
+		for _, webhook := range kept {
+			stored, err := models.Webhooks.Get(ctx, webhook.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !stored.Active {
+				t.Errorf("webhook %+v was deactivated together with the one of %s", webhook, tc.name)
+			}
+		}
+	}
+}
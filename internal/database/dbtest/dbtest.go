@@ -0,0 +1,168 @@
+package dbtest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratedb "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/file"
+
+	"github.com/schlafer/EventApp/internal/database"
+)
+
+/*
+Package dbtest gives tests a migrated database of every dialect the
+application supports. SQLite is always tested, PostgreSQL only when
+TEST_DATABASE_URL points at a server the tests may create schemas in.
+*/
+
+func ForEachDialect(t *testing.T, test func(t *testing.T, db *sql.DB, dialect string)) {
+	t.Run(database.DialectSQLite, func(t *testing.T) {
+		db := openSQLite(t)
+		test(t, db, database.DialectSQLite)
+	})
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Run(database.DialectPostgres, func(t *testing.T) {
+			t.Skip("TEST_DATABASE_URL is not set")
+		})
+		return
+	}
+
+	t.Run(database.DialectPostgres, func(t *testing.T) {
+		db := openPostgres(t, databaseURL)
+		test(t, db, database.DialectPostgres)
+	})
+}
+
+/*
+ForEachDialect runs test once per dialect, each time with a new, empty
+database that has every migration applied and is removed again when the
+test is done. Without the sqlite_fts5 build tag the search migrations
+cannot be applied, the tests fail then instead of passing without having
+touched a database.
+*/
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, _, err := database.Open("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var fts5 bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		t.Fatal(err)
+	}
+	if !fts5 {
+		t.Fatal("SQLite was built without FTS5, run the tests with -tags sqlite_fts5 or make test")
+	}
+
+	instance, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrateUp(t, instance, database.DialectSQLite)
+
+	return db
+}
+
+func openPostgres(t *testing.T, databaseURL string) *sql.DB {
+	t.Helper()
+
+	admin, _, err := database.Open(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(suffix)
+
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin, _, err := database.Open(databaseURL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer admin.Close()
+
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+
+	db, _, err := database.Open(parsed.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	instance, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrateUp(t, instance, database.DialectPostgres)
+
+	return db
+}
+
+// Every PostgreSQL test gets a schema of its own, so the packages that go
+// test runs in parallel can share the same server without seeing each
+// other's rows.
+
+func migrateUp(t *testing.T, instance migratedb.Driver, dialect string) {
+	t.Helper()
+
+	source, err := (&file.File{}).Open(migrationsDir(dialect))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := migrate.NewWithInstance("file", source, dialect, instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		t.Fatal(err)
+	}
+}
+
+func migrationsDir(dialect string) string {
+	_, current, _, _ := runtime.Caller(0)
+	dir := "sqlite"
+	if dialect == database.DialectPostgres {
+		dir = "postgres"
+	}
+	return filepath.Join(filepath.Dir(current), "..", "..", "..", "cmd", "migrate", "migrations", dir)
+}
+
+// migrationsDir finds the migrations of cmd/migrate from the location of
+// this file, so the tests of any package can apply them.
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	return false
+}
+
+/*
+isUniqueViolation reports whether err was caused by a UNIQUE constraint,
+for both drivers we support. 23505 is the unique_violation SQLSTATE of
+PostgreSQL. The models use it to turn a lost race into a domain error
+like ErrDuplicateAttendee instead of a generic database error.
+*/
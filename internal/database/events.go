@@ -3,34 +3,164 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type EventModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
 }
 type Event struct {
-	Id          int    `json:"id"`
-	Name        string `json:"name" binding:"required,min=3"`
-	Description string `json:"description" binding:"required,min=10"`
-	Date        string `json:"date" binding:"required,datetime=2006-01-02"`
-	Location    string `json:"location" binding:"required,min=3"`
-	OwnerId     int    `json:"ownerId"`
+	Id             int       `json:"id"`
+	Name           string    `json:"name" binding:"required,min=3"`
+	Description    string    `json:"description" binding:"required,min=10"`
+	StartsAt       time.Time `json:"startsAt" binding:"required" example:"2026-11-05T18:00:00+01:00"`
+	EndsAt         time.Time `json:"endsAt" binding:"required,gtfield=StartsAt" example:"2026-11-05T21:00:00+01:00"`
+	Timezone       string    `json:"timezone" binding:"omitempty,timezone" example:"Europe/Berlin"`
+	Location       string    `json:"location" binding:"required,min=3"`
+	Capacity       *int      `json:"capacity,omitempty" binding:"omitempty,min=1"`
+	OwnerId        int       `json:"ownerId"`
+	Rrule          string    `json:"rrule,omitempty" example:"FREQ=WEEKLY;COUNT=10"`
+	ExDates        DateList  `json:"exdates,omitempty" binding:"omitempty,dive,datetime=2006-01-02" swaggertype:"array,string"`
+	SeriesId       *int      `json:"seriesId,omitempty"`
+	OccurrenceDate string    `json:"occurrenceDate,omitempty"`
+	Modified       bool      `json:"-"`
+	Version        int       `json:"version"`
+	Visibility     string    `json:"visibility" binding:"omitempty,oneof=public unlisted private" enums:"public,unlisted,private"`
+
+	Status             string     `json:"status" enums:"draft,published,cancelled,completed"`
+	CancellationReason string     `json:"cancellationReason,omitempty"`
+	CancelledAt        *time.Time `json:"cancelledAt,omitempty"`
 }
 
 /*
 The Event struct includes five fields: Id, OwnerId, Name, Description, Date, and Location.
 We set binding tags and some validation rules. These will used later when creating an event and binding the request body to the Event struct. This is done by the Gin framework.
 For now we set a binding tag on the OwnerId field. Later we will remove it and instead use the current logged in user.
+Capacity is optional, an event without a capacity accepts any number of attendees.
+StartsAt and EndsAt are instants, clients send them in RFC 3339 with any
+offset and get them back in UTC. Timezone is the IANA zone the event takes
+place in (UTC if empty), it decides on which local date the event falls and
+keeps a recurring event at the same wall clock time across DST changes.
+Rrule turns the event into a recurring series, StartsAt is the first occurrence
+and ExDates are the local dates of the occurrences that were left out. A single occurrence that
+has attendees or was edited is stored as its own event row, with SeriesId
+pointing at the series and OccurrenceDate holding the date it replaces.
+Version starts at 1 and goes up with every change, it is what the ETag of
+an event is made of.
+Visibility decides who can find and open the event, see invites.go. It is
+public if empty.
+Status, CancellationReason and CancelledAt are only changed by the status
+transitions in lifecycle.go, Update leaves them alone.
 */
 
-func (m EventModel) Insert(event *Event) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+var eventColumns = []string{
+	"id", "owner_id", "name", "description", "starts_at", "ends_at", "timezone", "location", "capacity",
+	"rrule", "exdates", "series_id", "occurrence_date", "modified", "version", "visibility",
+	"status", "cancellation_reason", "cancelled_at",
+}
+
+func selectEventColumns(alias string) string {
+	if alias == "" {
+		return strings.Join(eventColumns, ", ")
+	}
+
+	columns := make([]string, len(eventColumns))
+	for i, column := range eventColumns {
+		columns[i] = alias + "." + column
+	}
+	return strings.Join(columns, ", ")
+}
+
+func eventFields(event *Event) []interface{} {
+	return []interface{}{
+		&event.Id, &event.OwnerId, &event.Name, &event.Description, &event.StartsAt, &event.EndsAt, &event.Timezone,
+		&event.Location, &event.Capacity,
+		&event.Rrule, &event.ExDates, &event.SeriesId, &event.OccurrenceDate, &event.Modified, &event.Version, &event.Visibility,
+		&event.Status, &event.CancellationReason, &event.CancelledAt,
+	}
+}
+
+/*
+Every query that returns events selects the columns of eventColumns and
+scans them with eventFields, so both stay in the same order. The alias is
+used by queries that join events with another table.
+*/
+
+func (e *Event) normalizeTimes() {
+	if e.Timezone == "" {
+		e.Timezone = "UTC"
+	}
+	e.StartsAt = e.StartsAt.UTC().Truncate(time.Second)
+	e.EndsAt = e.EndsAt.UTC().Truncate(time.Second)
+	if e.Visibility == "" {
+		e.Visibility = VisibilityPublic
+	}
+}
+
+/*
+normalizeTimes runs before an event is written. Both instants are stored
+in UTC and without fractions of a second, so they compare correctly even
+where the database keeps them as text, like in SQLite. It also fills in
+the defaults of the time zone and the visibility.
+*/
+
+func (e *Event) Zone() *time.Location {
+	zone, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return zone
+}
+
+func (e *Event) StartDate() string {
+	return e.StartsAt.In(e.Zone()).Format(dateLayout)
+}
+
+// Zone returns the time zone of the event, StartDate the local date the
+// event starts on. Timezone is validated on the way in, so UTC is only a
+// fallback for rows written by hand.
+
+func (m EventModel) Insert(ctx context.Context, event *Event, enqueue Enqueue) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.insert")
 	defer cancel()
 
-	query := "INSERT INTO events (owner_id, name, description, date, location) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertEvent(ctx, tx, event); err != nil {
+		return err
+	}
 
-	err := m.DB.QueryRowContext(ctx, query, event.OwnerId, event.Name, event.Description, event.Date, event.Location).Scan(&event.Id)
+	if err := enqueue.run(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertEvent(ctx context.Context, db queryRower, event *Event) error {
+	event.normalizeTimes()
+	if event.Status == "" {
+		event.Status = EventDraft
+	}
+
+	query := `
+		INSERT INTO events (owner_id, name, description, starts_at, ends_at, timezone, location, capacity, rrule, exdates, visibility, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, version
+	`
+
+	err := db.QueryRowContext(ctx, query, event.OwnerId, event.Name, event.Description, event.StartsAt, event.EndsAt, event.Timezone,
+		event.Location, event.Capacity, event.Rrule, event.ExDates, event.Visibility, event.Status).Scan(&event.Id, &event.Version)
 	if err != nil {
 		return err
 	}
@@ -41,18 +171,213 @@ func (m EventModel) Insert(event *Event) error {
 /*
 This function inserts a new event into the events table.
 It uses QueryRowContext, which executes the query with a context
-that includes a 3-second timeout, ensuring the operation doesn’t hang indefinitely. If there is no error we add the id to the event and return nil.
+derived from the caller's context with the timeout configured for events.insert,
+ensuring the operation doesn’t hang indefinitely and stops when the request is cancelled. If there is no error we add the id to the event and return nil.
+insertEvent takes a queryRower so InsertMany and SplitSeries can insert
+inside their transactions. Events without a status are stored as drafts.
+enqueue writes the notifications about the new event in the same transaction.
 */
 
-func (m EventModel) GetAll() ([]*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m EventModel) InsertMany(ctx context.Context, events []*Event) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.insertMany")
 	defer cancel()
 
-	query := "SELECT * FROM events"
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, event := range events {
+		if err := insertEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// InsertMany inserts a batch of events in one transaction,
+// either all of them are stored or none is.
+
+type EventFilters struct {
+	From       time.Time
+	To         time.Time
+	Location   string
+	OwnerId    int
+	Status     string
+	ViewerId   int
+	ShowHidden bool
+	Sort       string
+	Limit      int
+	Cursor     string
+}
+
+type Metadata struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
 
-	rows, err := m.DB.QueryContext(ctx, query)
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrEditConflict  = errors.New("edit conflict")
+)
+
+/*
+EventFilters holds the optional filters, the sort order and the page size
+used when listing events. From and To limit the start of the events, From is
+included and To is not. Drafts, unlisted and private events are only listed
+for their owner and members, found by ViewerId, unless ShowHidden is set for
+moderators and admins. Metadata is returned next to every page so the
+client knows how many events match in total and how to fetch the next page.
+*/
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
 	if err != nil {
-		return nil, err
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id < 1 {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
+
+/*
+encodeCursor and decodeCursor make the cursors of the chat and the
+comments, which are always sorted by id: the id of the last entry of the
+previous page, base64 encoded so clients treat it as an opaque value.
+The event listing can be sorted by other columns and uses the keyset
+cursor of encodeEventCursor below.
+*/
+
+type eventCursor struct {
+	Column string `json:"c"`
+	Value  string `json:"v"`
+	Id     int    `json:"id"`
+}
+
+func encodeEventCursor(column string, event *Event) string {
+	cursor := eventCursor{Column: column, Value: event.Name, Id: event.Id}
+	if column == "starts_at" {
+		cursor.Value = event.StartsAt.UTC().Format(time.RFC3339)
+	}
+
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeEventCursor(cursor, column string) (interface{}, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	var decoded eventCursor
+	if err := json.Unmarshal(raw, &decoded); err != nil || decoded.Column != column || decoded.Id < 1 {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	if column != "starts_at" {
+		return decoded.Value, decoded.Id, nil
+	}
+
+	startsAt, err := time.Parse(time.RFC3339, decoded.Value)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	return startsAt.UTC(), decoded.Id, nil
+}
+
+/*
+The cursor of the event listing is a keyset cursor: the sort column, the
+sort value of the last event of the previous page and its id, as base64
+encoded JSON. It carries the sort value instead of reading it from the row
+again. The next page is then still correct when that event was deleted in
+the meantime. A cursor only fits the sort order it was made for, using it
+with another one is an ErrInvalidCursor.
+*/
+
+func (m EventModel) GetAll(ctx context.Context, filters EventFilters) ([]*Event, Metadata, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.getAll")
+	defer cancel()
+
+	var (
+		conditions = []string{"series_id IS NULL"}
+		args       []interface{}
+	)
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !filters.From.IsZero() {
+		conditions = append(conditions, "starts_at >= "+arg(filters.From.UTC()))
+	}
+	if !filters.To.IsZero() {
+		conditions = append(conditions, "starts_at < "+arg(filters.To.UTC()))
+	}
+	if filters.Location != "" {
+		conditions = append(conditions, "LOWER(location) LIKE "+arg("%"+strings.ToLower(filters.Location)+"%"))
+	}
+	if filters.OwnerId != 0 {
+		conditions = append(conditions, "owner_id = "+arg(filters.OwnerId))
+	}
+	if filters.Status != "" {
+		conditions = append(conditions, "status = "+arg(filters.Status))
+	}
+	if !filters.ShowHidden {
+		viewer := arg(filters.ViewerId)
+		conditions = append(conditions, fmt.Sprintf(
+			"(owner_id = %s OR id IN (SELECT event_id FROM event_members WHERE user_id = %s) OR (status <> %s AND visibility = %s))",
+			viewer, viewer, arg(EventDraft), arg(VisibilityPublic)))
+	}
+
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	metadata := Metadata{Limit: filters.Limit}
+
+	err := m.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM events"+where, args...).Scan(&metadata.Total)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	column, direction, comparison := "starts_at", "ASC", ">"
+	switch filters.Sort {
+	case "-date":
+		direction, comparison = "DESC", "<"
+	case "name":
+		column = "name"
+	case "-name":
+		column, direction, comparison = "name", "DESC", "<"
+	}
+
+	if filters.Cursor != "" {
+		cursorValue, cursorId, err := decodeEventCursor(filters.Cursor, column)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		where += fmt.Sprintf(" AND (%s, id) %s (%s, %s)", column, comparison, arg(cursorValue), arg(cursorId))
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM events%s ORDER BY %s %s, id %s LIMIT %s",
+		selectEventColumns(""), where, column, direction, direction, arg(filters.Limit+1),
+	)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
@@ -60,37 +385,132 @@ func (m EventModel) GetAll() ([]*Event, error) {
 
 	for rows.Next() {
 		var event Event
-		err := rows.Scan(&event.Id, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+		err := rows.Scan(eventFields(&event)...)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
 		events = append(events, &event)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(events) > filters.Limit {
+		events = events[:filters.Limit]
+		metadata.NextCursor = encodeEventCursor(column, events[len(events)-1])
+	}
+
+	return events, metadata, nil
+}
+
+/*
+We build the WHERE clause from the filters that were provided, count the
+matching rows for the metadata and then fetch one page of events.
+Stored occurrences of recurring events are left out, the series stands for them.
+Instead of OFFSET we use keyset pagination: the cursor holds the sort value
+and the id of the last event of the previous page and we only select rows
+that sort after it, so deep pages stay as fast as the first one.
+We ask for one row more than the limit to know if there is a next page.
+*/
+
+type EventSearchResult struct {
+	Event      *Event          `json:"event"`
+	Highlights EventHighlights `json:"highlights"`
+	Rank       float64         `json:"rank"`
+}
+
+type EventHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+}
+
+/*
+A search result wraps the matching event together with its rank and
+the highlighted fragments of the name, description and location.
+Matching terms are wrapped in <mark></mark> tags.
+*/
+
+func ftsQuery(q string) string {
+	terms := strings.Fields(q)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+/*
+ftsQuery turns the raw search input into a safe FTS5 query.
+Every word is quoted, so characters like - or : are not parsed as
+FTS5 operators, and gets a * so partially typed words still match.
+*/
+
+func (m EventModel) Search(ctx context.Context, q string, limit int) ([]*EventSearchResult, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.search")
+	defer cancel()
+
+	query := `
+		SELECT ` + selectEventColumns("e") + `,
+			highlight(events_fts, 0, '<mark>', '</mark>'),
+			snippet(events_fts, 1, '<mark>', '</mark>', '...', 16),
+			highlight(events_fts, 2, '<mark>', '</mark>'),
+			events_fts.rank
+		FROM events_fts
+		JOIN events e ON e.id = events_fts.rowid
+		WHERE events_fts MATCH $1 AND e.series_id IS NULL AND e.status <> $2 AND e.visibility = $3
+		ORDER BY events_fts.rank
+		LIMIT $4
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, ftsQuery(q), EventDraft, VisibilityPublic, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*EventSearchResult{}
+
+	for rows.Next() {
+		var event Event
+		var result EventSearchResult
+		err := rows.Scan(append(eventFields(&event),
+			&result.Highlights.Name, &result.Highlights.Description, &result.Highlights.Location,
+			&result.Rank,
+		)...)
+		if err != nil {
+			return nil, err
+		}
+		result.Event = &event
+		results = append(results, &result)
+	}
+
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return events, nil
+	return results, nil
 }
 
 /*
-We retrieve all records from the events table.
-We then iterate over the result set and append each event to the events slice.
-If the query fails, we return an error.
+Search runs a full-text query against the events_fts virtual table,
+which is kept in sync with the events table by triggers.
+FTS5 ranks the matches with bm25, lower is better, so we order ascending.
+Only public events that are no longer drafts show up in search results,
+not even the owner finds their drafts, unlisted or private events.
 */
 
-func (m EventModel) Get(id int) (*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m EventModel) Get(ctx context.Context, id int) (*Event, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.get")
 	defer cancel()
 
-	query := "SELECT * FROM events WHERE id = $1"
+	query := "SELECT " + selectEventColumns("") + " FROM events WHERE id = $1"
 
 	row := m.DB.QueryRowContext(ctx, query, id)
 
 	var event Event
 
-	err := row.Scan(&event.Id, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+	err := row.Scan(eventFields(&event)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -108,37 +528,85 @@ It maps the result to the Event struct fields.
 We check if the event is not found and return nil if it is not found.
 */
 
-func (m EventModel) Update(event *Event) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m EventModel) Update(ctx context.Context, event *Event, enqueue Enqueue) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.update")
 	defer cancel()
 
-	query := "UPDATE events SET name = $1, description = $2, date = $3, location = $4 WHERE id = $5"
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	event.normalizeTimes()
+
+	query := `
+		UPDATE events SET name = $1, description = $2, starts_at = $3, ends_at = $4, timezone = $5, location = $6,
+			capacity = $7, rrule = $8, exdates = $9, visibility = $10, modified = $11, version = version + 1
+		WHERE id = $12 AND version = $13
+		RETURNING version
+	`
 
-	_, err := m.DB.ExecContext(ctx, query, event.Name, event.Description, event.Date, event.Location, event.Id)
+	err = tx.QueryRowContext(ctx, query, event.Name, event.Description, event.StartsAt, event.EndsAt, event.Timezone,
+		event.Location, event.Capacity, event.Rrule, event.ExDates, event.Visibility, event.Modified, event.Id, event.Version).Scan(&event.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEditConflict
+	}
 	if err != nil {
 		return err
 	}
-	return nil
+
+	if _, err := promoteFromWaitlist(ctx, tx, event.Id); err != nil {
+		return err
+	}
+
+	if err := updateOccurrences(ctx, tx, event.Id, event); err != nil {
+		return err
+	}
+
+	if err := enqueue.run(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 /*
 This function updates an existing record in the events table.
 It uses the SET clause to specify the columns to be updated and their new values.
-It ensures only the record with the specified id is updated.
+It ensures only the record with the specified id is updated, and only if it
+still has the version the caller read. Otherwise somebody else changed the
+event in the meantime and ErrEditConflict is returned instead of silently
+overwriting their change. On success event.Version holds the new version.
+If the capacity was raised, users from the waitlist are moved into the free
+spots in the same transaction.
+Updating a series also updates the stored occurrences that were not edited on their own.
+enqueue runs last, when event holds the new version.
 If the update fails, it returns an error.
 */
 
-func (m EventModel) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m EventModel) Delete(ctx context.Context, id int, enqueue Enqueue) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.delete")
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := "DELETE FROM events WHERE id = $1"
 
-	_, err := m.DB.ExecContext(ctx, query, id)
+	_, err = tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	if err := enqueue.run(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 /*
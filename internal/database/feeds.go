@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type FeedModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+/*
+Every user can have one personal calendar feed. The feed is fetched by
+calendar apps that cannot log in, so it is protected by an unguessable
+token in its URL instead. Like the other tokens only its hash is stored.
+*/
+
+func (m *FeedModel) Rotate(ctx context.Context, userId int, tokenHash string) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "feeds.rotate")
+	defer cancel()
+
+	query := `
+		INSERT INTO calendar_feeds (user_id, token_hash, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at
+	`
+	_, err := m.DB.ExecContext(ctx, query, userId, tokenHash, time.Now().UTC())
+	return err
+}
+
+// Rotate stores the hash of a new feed token for the user, replacing the
+// previous one, so old feed URLs stop working.
+
+func (m *FeedModel) GetUserId(ctx context.Context, tokenHash string) (int, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "feeds.getUserId")
+	defer cancel()
+
+	var userId int
+	err := m.DB.QueryRowContext(ctx, "SELECT user_id FROM calendar_feeds WHERE token_hash = $1", tokenHash).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (m *FeedModel) Delete(ctx context.Context, userId int) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "feeds.delete")
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, "DELETE FROM calendar_feeds WHERE user_id = $1", userId)
+	return err
+}
+
+// GetUserId looks up the owner of a feed token, Delete turns the feed off.
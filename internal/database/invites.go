@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+/*
+A public event is listed and found by search. An unlisted event is left
+out of lists and search, but everybody with its id can open it. A private
+event can only be seen by its owner, moderators and admins, the users
+that signed up for it and whoever holds a valid invite link.
+*/
+
+type InviteModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+type Invite struct {
+	Id        int        `json:"id"`
+	EventId   int        `json:"eventId"`
+	CreatedBy int        `json:"createdBy"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxUses   *int       `json:"maxUses,omitempty"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+/*
+An invite opens a private event to everybody who has its link. The link
+can expire and can be limited to a number of uses, every user that signs
+up for the event through the link uses it once. Without ExpiresAt and
+MaxUses the link works until it is deleted.
+*/
+
+func (i *Invite) Valid(now time.Time) bool {
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == nil || i.Uses < *i.MaxUses
+}
+
+// Valid reports whether the invite is neither expired nor used up.
+
+var inviteColumns = "id, event_id, created_by, expires_at, max_uses, uses, created_at"
+
+func inviteFields(invite *Invite) []interface{} {
+	return []interface{}{
+		&invite.Id, &invite.EventId, &invite.CreatedBy, &invite.ExpiresAt, &invite.MaxUses, &invite.Uses, &invite.CreatedAt,
+	}
+}
+
+func (m InviteModel) Insert(ctx context.Context, invite *Invite) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "invites.insert")
+	defer cancel()
+
+	invite.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	if invite.ExpiresAt != nil {
+		expiresAt := invite.ExpiresAt.UTC().Truncate(time.Second)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	query := `
+		INSERT INTO event_invites (event_id, created_by, expires_at, max_uses, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id
+	`
+	return m.DB.QueryRowContext(ctx, query, invite.EventId, invite.CreatedBy, invite.ExpiresAt, invite.MaxUses,
+		invite.CreatedAt).Scan(&invite.Id)
+}
+
+func (m InviteModel) Get(ctx context.Context, id int) (*Invite, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "invites.get")
+	defer cancel()
+
+	var invite Invite
+	query := "SELECT " + inviteColumns + " FROM event_invites WHERE id = $1"
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(inviteFields(&invite)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+func (m InviteModel) GetByEvent(ctx context.Context, eventId int) ([]*Invite, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "invites.getByEvent")
+	defer cancel()
+
+	query := "SELECT " + inviteColumns + " FROM event_invites WHERE event_id = $1 ORDER BY id"
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []*Invite{}
+	for rows.Next() {
+		var invite Invite
+		if err := rows.Scan(inviteFields(&invite)...); err != nil {
+			return nil, err
+		}
+		invites = append(invites, &invite)
+	}
+
+	return invites, rows.Err()
+}
+
+// Get returns nil if the invite does not exist, GetByEvent lists the
+// invites of an event in the order they were created.
+
+func (m InviteModel) Use(ctx context.Context, id int) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "invites.use")
+	defer cancel()
+
+	return useInvite(ctx, m.DB, id)
+}
+
+func useInvite(ctx context.Context, db queryRower, id int) error {
+	query := `
+		UPDATE event_invites SET uses = uses + 1
+		WHERE id = $1 AND (expires_at IS NULL OR expires_at > $2) AND (max_uses IS NULL OR uses < max_uses)
+		RETURNING id
+	`
+
+	err := db.QueryRowContext(ctx, query, id, time.Now().UTC()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrInvalidToken
+	}
+	return err
+}
+
+/*
+Use counts one use of the invite. Like TokenModel.Consume the check and the
+update are one statement, so two users racing for the last use of a link
+cannot both get it. An expired, used up or deleted invite returns
+ErrInvalidToken. useInvite takes a queryRower so AttendeeModel.SetStatus
+can count the use in the transaction of the RSVP.
+*/
+
+func (m InviteModel) Delete(ctx context.Context, eventId, id int) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "invites.delete")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM event_invites WHERE id = $1 AND event_id = $2", id, eventId)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// Delete revokes an invite, its link stops working right away. It reports
+// false if the event has no invite with this id.
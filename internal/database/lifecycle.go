@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	EventDraft     = "draft"
+	EventPublished = "published"
+	EventCancelled = "cancelled"
+	EventCompleted = "completed"
+)
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+var eventTransitions = map[string][]string{
+	EventDraft:     {EventPublished},
+	EventPublished: {EventCancelled, EventCompleted},
+}
+
+/*
+An event starts as a draft that only its owner can see. Publishing it
+opens it for RSVPs, after that it is either cancelled or completed.
+Cancelled and completed are final: the event and its attendees stay, but
+nobody can sign up anymore. eventTransitions lists the allowed steps.
+*/
+
+func CanTransition(from, to string) bool {
+	return slices.Contains(eventTransitions[from], to)
+}
+
+func (e *Event) IsOpen() bool {
+	return e.Status == EventPublished
+}
+
+// IsOpen reports whether users can still sign up for the event.
+
+func (m EventModel) SetStatus(ctx context.Context, event *Event, status, reason string, enqueue Enqueue) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.setStatus")
+	defer cancel()
+
+	if !CanTransition(event.Status, status) {
+		return ErrInvalidTransition
+	}
+
+	var cancelledAt *time.Time
+	if status == EventCancelled {
+		now := time.Now().UTC().Truncate(time.Second)
+		cancelledAt = &now
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE events SET status = $1, cancellation_reason = $2, cancelled_at = $3, version = version + 1
+		WHERE id = $4 AND status = $5
+		RETURNING version
+	`
+
+	var version int
+	err = tx.QueryRowContext(ctx, query, status, reason, cancelledAt, event.Id, event.Status).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEditConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE events SET status = $1, cancellation_reason = $2, cancelled_at = $3, version = version + 1
+		WHERE series_id = $4 AND status = $5
+	`
+	if _, err := tx.ExecContext(ctx, query, status, reason, cancelledAt, event.Id, event.Status); err != nil {
+		return err
+	}
+
+	event.Status = status
+	event.CancellationReason = reason
+	event.CancelledAt = cancelledAt
+	event.Version = version
+
+	if err := enqueue.run(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+SetStatus moves an event to a new status if the transition is allowed.
+The stored occurrences of a series that are still in the same status
+follow the series, so cancelling a series cancels every occurrence people
+signed up for. The WHERE on the old status makes two concurrent
+transitions fail with ErrEditConflict instead of both succeeding.
+event already has its new status when enqueue runs.
+*/
+
+func (m EventModel) CompleteEnded(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.completeEnded")
+	defer cancel()
+
+	query := `
+		UPDATE events SET status = $1, version = version + 1
+		WHERE status = $2 AND rrule = '' AND ends_at < $3
+	`
+
+	result, err := m.DB.ExecContext(ctx, query, EventCompleted, EventPublished, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+/*
+CompleteEnded marks published events that are over as completed, the
+cleanup worker calls it periodically. A series never ends on its own
+because its rule can go on forever, but each stored occurrence is an event
+without a rule and is completed once it is over.
+*/
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	MemberCoHost  = "co-host"
+	MemberCheckIn = "check-in"
+	MemberViewer  = "viewer"
+)
+
+var ErrDuplicateMember = errors.New("user is already a member of the event")
+
+type MemberModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+type Member struct {
+	EventId   int       `json:"eventId"`
+	UserId    int       `json:"userId"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role" enums:"co-host,check-in,viewer"`
+	AddedBy   *int      `json:"addedBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+/*
+Members share the work on an event with its owner. A co-host can do
+almost everything the owner can, check-in staff can see who signed up and
+check them in, and a viewer can see the event and its RSVPs even while it
+is a draft or private. What every role is allowed to do is decided by the
+policies in cmd/api/policy.go. Members of a series are members of all its
+occurrences.
+*/
+
+func (m MemberModel) GetRole(ctx context.Context, eventId, userId int) (string, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "members.getRole")
+	defer cancel()
+
+	var role string
+	query := "SELECT role FROM event_members WHERE event_id = $1 AND user_id = $2"
+	err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// GetRole returns the role of the user in the event, an empty string if
+// the user is not a member.
+
+const selectMembers = `
+	SELECT m.event_id, m.user_id, u.name, u.email, m.role, m.added_by, m.created_at
+	FROM event_members m
+	JOIN users u ON u.id = m.user_id
+`
+
+func memberFields(member *Member) []interface{} {
+	return []interface{}{
+		&member.EventId, &member.UserId, &member.Name, &member.Email, &member.Role, &member.AddedBy, &member.CreatedAt,
+	}
+}
+
+func (m MemberModel) Get(ctx context.Context, eventId, userId int) (*Member, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "members.get")
+	defer cancel()
+
+	var member Member
+	query := selectMembers + " WHERE m.event_id = $1 AND m.user_id = $2"
+	err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(memberFields(&member)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (m MemberModel) GetByEvent(ctx context.Context, eventId int) ([]*Member, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "members.getByEvent")
+	defer cancel()
+
+	query := selectMembers + " WHERE m.event_id = $1 ORDER BY m.created_at, m.user_id"
+
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		var member Member
+		if err := rows.Scan(memberFields(&member)...); err != nil {
+			return nil, err
+		}
+		members = append(members, &member)
+	}
+
+	return members, rows.Err()
+}
+
+// Members are returned together with the name and email of their user,
+// Get returns nil if the user is not a member of the event.
+
+func (m MemberModel) Insert(ctx context.Context, member *Member) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "members.insert")
+	defer cancel()
+
+	member.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	query := "INSERT INTO event_members (event_id, user_id, role, added_by, created_at) VALUES ($1, $2, $3, $4, $5)"
+	_, err := m.DB.ExecContext(ctx, query, member.EventId, member.UserId, member.Role, member.AddedBy, member.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateMember
+	}
+	return err
+}
+
+func (m MemberModel) SetRole(ctx context.Context, eventId, userId int, role string) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "members.setRole")
+	defer cancel()
+
+	query := "UPDATE event_members SET role = $1 WHERE event_id = $2 AND user_id = $3"
+	return m.change(ctx, eventId, userId, role != MemberCoHost, query, role, eventId, userId)
+}
+
+func (m MemberModel) Delete(ctx context.Context, eventId, userId int) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "members.delete")
+	defer cancel()
+
+	query := "DELETE FROM event_members WHERE event_id = $1 AND user_id = $2"
+	return m.change(ctx, eventId, userId, true, query, eventId, userId)
+}
+
+func (m MemberModel) change(ctx context.Context, eventId, userId int, dropWebhooks bool, query string, args ...interface{}) (bool, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := result.RowsAffected()
+	if err != nil || changed == 0 {
+		return false, err
+	}
+
+	if dropWebhooks {
+		query = "UPDATE webhooks SET active = FALSE WHERE event_id = $1 AND user_id = $2"
+		if _, err := tx.ExecContext(ctx, query, eventId, userId); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+/*
+SetRole and Delete report false if the user is not a member of the event.
+Only co-hosts may manage the webhooks of an event, so when a member is
+removed or loses the role, the webhooks they created for the event are
+deactivated in the same transaction. Otherwise they would keep receiving
+its RSVPs long after they lost access to them.
+*/
+
+func (m MemberModel) TransferOwnership(ctx context.Context, event *Event, newOwnerId int, enqueue Enqueue) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "members.transferOwnership")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE events SET owner_id = $1, version = version + 1
+		WHERE id = $2 AND owner_id = $3
+		RETURNING version
+	`
+
+	var version int
+	err = tx.QueryRowContext(ctx, query, newOwnerId, event.Id, event.OwnerId).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEditConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	query = "UPDATE events SET owner_id = $1, version = version + 1 WHERE series_id = $2"
+	if _, err := tx.ExecContext(ctx, query, newOwnerId, event.Id); err != nil {
+		return err
+	}
+
+	query = "DELETE FROM event_members WHERE event_id = $1 AND user_id = $2"
+	if _, err := tx.ExecContext(ctx, query, event.Id, newOwnerId); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO event_members (event_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (event_id, user_id) DO UPDATE SET role = excluded.role
+	`
+	_, err = tx.ExecContext(ctx, query, event.Id, event.OwnerId, MemberCoHost, time.Now().UTC().Truncate(time.Second))
+	if err != nil {
+		return err
+	}
+
+	event.OwnerId = newOwnerId
+	event.Version = version
+
+	if err := enqueue.run(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+TransferOwnership hands the event and its stored occurrences to another
+user. The new owner stops being a member, the previous owner stays on as
+a co-host so they do not lose access to the event they organized; the new
+owner can remove them afterwards. Like SetStatus, the WHERE on the current
+owner makes two concurrent transfers fail with ErrEditConflict.
+*/
@@ -1,22 +1,108 @@
 package database
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx.
+
+type UserRepository interface {
+	Insert(ctx context.Context, user *User) error
+	Get(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetAll(ctx context.Context) ([]*User, error)
+	UpdatePassword(ctx context.Context, id int, password string) error
+	MarkVerified(ctx context.Context, id int) error
+	SetRole(ctx context.Context, id int, role string) error
+	SetSuspended(ctx context.Context, id int, suspended bool) error
+	Delete(ctx context.Context, id int) error
+}
+
+type EventRepository interface {
+	Insert(ctx context.Context, event *Event, enqueue Enqueue) error
+	InsertMany(ctx context.Context, events []*Event) error
+	GetAll(ctx context.Context, filters EventFilters) ([]*Event, Metadata, error)
+	Search(ctx context.Context, q string, limit int) ([]*EventSearchResult, error)
+	Get(ctx context.Context, id int) (*Event, error)
+	Update(ctx context.Context, event *Event, enqueue Enqueue) error
+	Delete(ctx context.Context, id int, enqueue Enqueue) error
+	GetOccurrences(ctx context.Context, series *Event, from, to time.Time) ([]*Occurrence, error)
+	GetOrCreateOccurrence(ctx context.Context, series *Event, date string) (*Event, error)
+	SplitSeries(ctx context.Context, series *Event, date string, following *Event, enqueue Enqueue) error
+	CancelOccurrence(ctx context.Context, series *Event, date string, enqueue Enqueue) error
+	GetModifiedOccurrences(ctx context.Context, seriesId int) ([]*Event, error)
+	SetStatus(ctx context.Context, event *Event, status, reason string, enqueue Enqueue) error
+	CompleteEnded(ctx context.Context, now time.Time) (int64, error)
+}
+
+type AttendeeRepository interface {
+	Insert(ctx context.Context, attendee *Attendee) (*Attendee, error)
+	InsertOrWaitlist(ctx context.Context, attendee *Attendee, enqueue EnqueueRSVPs) (*WaitlistEntry, error)
+	GetByEventAndAttendee(ctx context.Context, eventId, userId int) (*Attendee, error)
+	IsSignedUp(ctx context.Context, eventId, userId int) (bool, error)
+	GetAttendeesByEvent(ctx context.Context, eventId int) ([]*User, error)
+	GetEventsByAttendee(ctx context.Context, attendeeId int) ([]*Event, error)
+	GetRSVPsByEvent(ctx context.Context, eventId int) ([]*RSVP, error)
+	SetStatus(ctx context.Context, eventId, userId int, status string, inviteId int, enqueue EnqueueRSVPs) (*Attendee, *WaitlistEntry, error)
+	Delete(ctx context.Context, userId, eventId int, enqueue EnqueueRSVPs) ([]*Attendee, error)
+	CheckIn(ctx context.Context, eventId, attendeeId, userId int) (*Attendee, error)
+	GetCheckIns(ctx context.Context, eventId int) ([]*RSVP, error)
+}
+
+/*
+The handlers only talk to users, events and attendees through these
+repository interfaces, so the storage behind them can be swapped.
+*/
 
 type Models struct {
-	Users     UserModel
-	Events    EventModel
-	Attendees AttendeeModel
+	Users     UserRepository
+	Events    EventRepository
+	Attendees AttendeeRepository
+	Waitlist  WaitlistModel
+	Sessions  SessionModel
+	Tokens    TokenModel
+	Feeds     FeedModel
+	Invites   InviteModel
+	Members   MemberModel
+	Webhooks  WebhookModel
+	Chat      ChatModel
+	Comments  CommentModel
 }
 
-func NewModels(db *sql.DB) Models {
+func NewModels(db *sql.DB, dialect string, timeouts Timeouts) Models {
+	var events EventRepository = EventModel{DB: db, Timeouts: timeouts}
+	if dialect == DialectPostgres {
+		events = PostgresEventModel{EventModel{DB: db, Timeouts: timeouts}}
+	}
+
 	return Models{
-		Users:     UserModel{DB: db},
-		Events:    EventModel{DB: db},
-		Attendees: AttendeeModel{DB: db},
+		Users:     &UserModel{DB: db, Timeouts: timeouts},
+		Events:    events,
+		Attendees: &AttendeeModel{DB: db, Timeouts: timeouts},
+		Waitlist:  WaitlistModel{DB: db, Timeouts: timeouts},
+		Sessions:  SessionModel{DB: db, Timeouts: timeouts},
+		Tokens:    TokenModel{DB: db, Timeouts: timeouts},
+		Feeds:     FeedModel{DB: db, Timeouts: timeouts},
+		Invites:   InviteModel{DB: db, Timeouts: timeouts},
+		Members:   MemberModel{DB: db, Timeouts: timeouts},
+		Webhooks:  WebhookModel{DB: db, Timeouts: timeouts},
+		Chat:      ChatModel{DB: db, Timeouts: timeouts},
+		Comments:  CommentModel{DB: db, Timeouts: timeouts},
 	}
 }
 
 /*
-Here we are creating a Models struct with 3 fields: Users, Events, and Attendees.
-We are also creating a NewModels function that takes a *sql.DB instance as an argument and passes it to the UserModel, EventModel, and AttendeeModel structs.
+Here we are creating a Models struct with a field for every model: Users, Events, Attendees, Waitlist, Sessions and Tokens.
+We are also creating a NewModels function that takes a *sql.DB instance, the dialect of the database
+and the query timeouts every model applies to its operations.
+The models write portable SQL with $1 placeholders and RETURNING, which both
+SQLite and PostgreSQL understand, so most of them are shared between the two.
+Where the databases differ, like full-text search, the dialect picks a
+specialised implementation, see postgres.go.
 */
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	DialectSQLite   = "sqlite3"
+	DialectPostgres = "postgres"
+)
+
+func ParseURL(databaseURL string) (string, string) {
+	if strings.HasPrefix(databaseURL, "postgres://") || strings.HasPrefix(databaseURL, "postgresql://") {
+		return DialectPostgres, databaseURL
+	}
+
+	dsn := strings.TrimPrefix(strings.TrimPrefix(databaseURL, "sqlite3://"), "sqlite://")
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+
+	return DialectSQLite, dsn + separator + "_foreign_keys=on&_txlock=immediate&_busy_timeout=5000"
+}
+
+/*
+ParseURL turns a DATABASE_URL into the name of the driver and the
+connection string for it. postgres:// and postgresql:// URLs are handed
+to lib/pq unchanged, everything else is treated as the path of an SQLite
+file, with or without a sqlite:// prefix.
+For SQLite we always add the options the models rely on:
+_foreign_keys=on turns on foreign key enforcement so deleting a user or an
+event cascades to the rows that reference it.
+_txlock=immediate makes every transaction take the write lock as soon as
+it begins, so transactions that count attendees before inserting one are
+serialized instead of failing halfway with SQLITE_BUSY.
+*/
+
+func Open(databaseURL string) (*sql.DB, string, error) {
+	dialect, dsn := ParseURL(databaseURL)
+
+	db, err := sql.Open(dialect, dsn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, "", err
+	}
+
+	return db, dialect, nil
+}
+
+// Open connects to the database of the URL and returns its dialect,
+// which NewModels uses to pick the matching repository implementations.
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+type PostgresEventModel struct {
+	EventModel
+}
+
+/*
+PostgresEventModel is the EventRepository for PostgreSQL.
+It shares every query with EventModel except Search, because PostgreSQL
+has no FTS5. Instead the events table has a generated tsvector column
+with a GIN index, see the postgres migrations.
+*/
+
+func tsQuery(q string) string {
+	terms := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+/*
+tsQuery turns the raw search input into a tsquery. Like ftsQuery for SQLite
+every word must match and may be a prefix. Everything that is not a letter
+or a digit is dropped so the input cannot contain tsquery operators.
+*/
+
+func (m PostgresEventModel) Search(ctx context.Context, q string, limit int) ([]*EventSearchResult, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.search")
+	defer cancel()
+
+	results := []*EventSearchResult{}
+
+	tsq := tsQuery(q)
+	if tsq == "" {
+		return results, nil
+	}
+
+	query := `
+		SELECT ` + selectEventColumns("e") + `,
+			ts_headline('simple', e.name, q, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('simple', e.description, q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=1, MaxWords=16, MinWords=4, FragmentDelimiter=...'),
+			ts_headline('simple', e.location, q, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			-ts_rank(e.search_vector, q) AS rank
+		FROM events e, to_tsquery('simple', $1) q
+		WHERE e.search_vector @@ q AND e.series_id IS NULL AND e.status <> $2 AND e.visibility = $3
+		ORDER BY rank
+		LIMIT $4
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, tsq, EventDraft, VisibilityPublic, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event Event
+		var result EventSearchResult
+		err := rows.Scan(append(eventFields(&event),
+			&result.Highlights.Name, &result.Highlights.Description, &result.Highlights.Location,
+			&result.Rank,
+		)...)
+		if err != nil {
+			return nil, err
+		}
+		result.Event = &event
+		results = append(results, &result)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+/*
+Search ranks the matches with ts_rank. Higher is better there, so we negate
+it to keep the same order and meaning as the bm25 rank of the SQLite
+implementation: lower ranks come first.
+*/
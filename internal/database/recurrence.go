@@ -0,0 +1,584 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+type DateList []string
+
+func (d DateList) Value() (driver.Value, error) {
+	return strings.Join(d, ","), nil
+}
+
+func (d *DateList) Scan(src interface{}) error {
+	var value string
+	switch src := src.(type) {
+	case nil:
+	case string:
+		value = src
+	case []byte:
+		value = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into DateList", src)
+	}
+
+	*d = nil
+	if value != "" {
+		*d = strings.Split(value, ",")
+	}
+	return nil
+}
+
+/*
+DateList stores the excluded dates of a series in a single column as a
+comma separated list, the same way EXDATE lists them in iCalendar.
+*/
+
+var (
+	ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+	ErrNoOccurrence      = errors.New("the event does not occur on this date")
+)
+
+type Occurrence struct {
+	Date  string `json:"date"`
+	Event *Event `json:"event"`
+}
+
+/*
+An Occurrence is one date of a recurring event. Event is the stored
+occurrence if there is one, otherwise a copy of the series with the date
+filled in and an id of 0. An occurrence is stored as soon as somebody
+signs up for it or it is edited on its own.
+*/
+
+const dateLayout = "2006-01-02"
+
+func parseDate(value string) (time.Time, error) {
+	return time.Parse(dateLayout, value)
+}
+
+func NormalizeRule(rule string) (string, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return "", nil
+	}
+
+	if strings.Contains(rule, "DTSTART") {
+		return "", fmt.Errorf("%w: DTSTART is taken from the start of the event", ErrInvalidRecurrence)
+	}
+
+	option, err := rrule.StrToROption(rule)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+
+	if option.Freq > rrule.DAILY {
+		return "", fmt.Errorf("%w: events repeat at most daily", ErrInvalidRecurrence)
+	}
+
+	if _, err := rrule.NewRRule(*option); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+
+	return option.RRuleString(), nil
+}
+
+/*
+NormalizeRule validates an RFC 5545 RRULE like FREQ=WEEKLY;BYDAY=TU;COUNT=10
+and returns it in canonical form. The start of the series is always the
+start of the event, so the rule must not carry its own DTSTART.
+Occurrences are identified by their local date, so rules that repeat more
+than once a day are refused.
+*/
+
+func (e *Event) IsRecurring() bool {
+	return e.Rrule != ""
+}
+
+func (e *Event) StartOn(date string) (time.Time, error) {
+	day, err := parseDate(date)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	start := e.StartsAt.In(e.Zone())
+	return time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location()), nil
+}
+
+/*
+StartOn returns the start of the event moved to another local date at the
+same wall clock time, which is when an occurrence on that date starts.
+It is also how a date of ExDates turns back into the instant iCalendar
+and the recurrence rule work with.
+*/
+
+func (e *Event) recurrence() (*rrule.Set, error) {
+	option, err := rrule.StrToROption(e.Rrule)
+	if err != nil {
+		return nil, err
+	}
+	option.Dtstart = e.StartsAt.In(e.Zone())
+
+	rule, err := rrule.NewRRule(*option)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &rrule.Set{}
+	set.RRule(rule)
+
+	for _, exdate := range e.ExDates {
+		start, err := e.StartOn(exdate)
+		if err != nil {
+			return nil, err
+		}
+		set.ExDate(start)
+	}
+
+	return set, nil
+}
+
+func (e *Event) occurrenceStarts(from, to time.Time) ([]time.Time, error) {
+	if !e.IsRecurring() {
+		if e.StartsAt.Before(from) || e.StartsAt.After(to) {
+			return nil, nil
+		}
+		return []time.Time{e.StartsAt}, nil
+	}
+
+	set, err := e.recurrence()
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Between(from, to, true), nil
+}
+
+/*
+occurrenceStarts expands the series into the starts between from and to,
+both included, leaving out the excluded dates. The rule is expanded in the
+time zone of the event, so a weekly event at 18:00 stays at 18:00 local
+time when daylight saving time begins or ends. An event that does not
+recur has a single occurrence at its own start.
+*/
+
+func (e *Event) occurrenceStart(date string) (time.Time, bool, error) {
+	day, err := parseDate(date)
+	if err != nil {
+		return time.Time{}, false, nil
+	}
+
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, e.Zone())
+	starts, err := e.occurrenceStarts(from, from.AddDate(0, 0, 1).Add(-time.Second))
+	if err != nil || len(starts) == 0 {
+		return time.Time{}, false, err
+	}
+
+	return starts[0], true, nil
+}
+
+func (e *Event) OccursOn(date string) (bool, error) {
+	_, ok, err := e.occurrenceStart(date)
+	return ok, err
+}
+
+// OccursOn reports whether the event has an occurrence on the given local
+// date, occurrenceStart also returns when it starts.
+
+func (e *Event) occurrence(date string, start time.Time) *Event {
+	return &Event{
+		Name:           e.Name,
+		Description:    e.Description,
+		StartsAt:       start.UTC(),
+		EndsAt:         start.UTC().Add(e.EndsAt.Sub(e.StartsAt)),
+		Timezone:       e.Timezone,
+		Location:       e.Location,
+		Capacity:       e.Capacity,
+		OwnerId:        e.OwnerId,
+		Visibility:     e.Visibility,
+		Status:         e.Status,
+		SeriesId:       &e.Id,
+		OccurrenceDate: date,
+	}
+}
+
+// occurrence builds the occurrence of the series that starts at start,
+// it lasts as long as the series does.
+
+func splitRule(rule string, start, day time.Time) (string, string, error) {
+	option, err := rrule.StrToROption(rule)
+	if err != nil {
+		return "", "", err
+	}
+	option.Dtstart = start
+
+	following := *option
+	until := day.Add(-time.Second)
+
+	if option.Count > 0 {
+		series, err := rrule.NewRRule(*option)
+		if err != nil {
+			return "", "", err
+		}
+		following.Count = option.Count - len(series.Between(start, until, true))
+	}
+
+	option.Count = 0
+	option.Until = until
+
+	return option.RRuleString(), following.RRuleString(), nil
+}
+
+/*
+splitRule cuts a rule in two at the local midnight day. The first rule ends
+just before, the second one continues from day on. A COUNT is divided
+between both parts so the series as a whole keeps the same number of
+occurrences.
+*/
+
+func (m EventModel) GetOccurrences(ctx context.Context, series *Event, from, to time.Time) ([]*Occurrence, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.getOccurrences")
+	defer cancel()
+
+	starts, err := series.occurrenceStarts(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []*Occurrence{}
+
+	if !series.IsRecurring() {
+		for range starts {
+			occurrences = append(occurrences, &Occurrence{Date: series.StartDate(), Event: series})
+		}
+		return occurrences, nil
+	}
+
+	if len(starts) == 0 {
+		return occurrences, nil
+	}
+
+	zone := series.Zone()
+	first := starts[0].In(zone).Format(dateLayout)
+	last := starts[len(starts)-1].In(zone).Format(dateLayout)
+
+	query := "SELECT " + selectEventColumns("") + " FROM events WHERE series_id = $1 AND occurrence_date >= $2 AND occurrence_date <= $3"
+
+	rows, err := m.DB.QueryContext(ctx, query, series.Id, first, last)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := map[string]*Event{}
+
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(eventFields(&event)...); err != nil {
+			return nil, err
+		}
+		stored[event.OccurrenceDate] = &event
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, start := range starts {
+		date := start.In(zone).Format(dateLayout)
+		event, ok := stored[date]
+		if !ok {
+			event = series.occurrence(date, start)
+		}
+		occurrences = append(occurrences, &Occurrence{Date: date, Event: event})
+	}
+
+	return occurrences, nil
+}
+
+/*
+GetOccurrences expands the series within the window and merges in the
+occurrences that are already stored, so edited occurrences show their own
+details and the id clients need to sign up for them.
+*/
+
+func (m EventModel) GetOrCreateOccurrence(ctx context.Context, series *Event, date string) (*Event, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.getOrCreateOccurrence")
+	defer cancel()
+
+	start, ok, err := series.occurrenceStart(date)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !series.IsRecurring() {
+		return nil, ErrNoOccurrence
+	}
+
+	occurrence := series.occurrence(date, start)
+
+	query := `
+		INSERT INTO events (occurrence_date, starts_at, ends_at, series_id, owner_id, name, description, timezone, location, capacity, visibility, status)
+		SELECT $1, $2, $3, id, owner_id, name, description, timezone, location, capacity, visibility, status FROM events WHERE id = $4
+		ON CONFLICT (series_id, occurrence_date) DO NOTHING
+	`
+	if _, err := m.DB.ExecContext(ctx, query, date, occurrence.StartsAt, occurrence.EndsAt, series.Id); err != nil {
+		return nil, err
+	}
+
+	var event Event
+	query = "SELECT " + selectEventColumns("") + " FROM events WHERE series_id = $1 AND occurrence_date = $2"
+	if err := m.DB.QueryRowContext(ctx, query, series.Id, date).Scan(eventFields(&event)...); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+/*
+GetOrCreateOccurrence returns the stored occurrence of the series on date
+and stores it first if needed. The stored occurrence is a normal event row,
+so attendees, RSVPs, the waitlist and the capacity work per occurrence
+without any changes. ON CONFLICT makes concurrent calls for the same date
+end up with the same row. The parameters appear in order because SQLite
+numbers $N parameters by their first appearance.
+*/
+
+func (m EventModel) SplitSeries(ctx context.Context, series *Event, date string, following *Event, enqueue Enqueue) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.splitSeries")
+	defer cancel()
+
+	day, err := parseDate(date)
+	if err != nil {
+		return ErrNoOccurrence
+	}
+
+	start := series.StartsAt.In(series.Zone())
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, start.Location())
+
+	before, after, err := splitRule(series.Rrule, start, midnight)
+	if err != nil {
+		return err
+	}
+
+	if following.Rrule == "" || following.Rrule == series.Rrule {
+		following.Rrule = after
+	}
+
+	var kept, moved DateList
+	for _, exdate := range series.ExDates {
+		if exdate < date {
+			kept = append(kept, exdate)
+		} else {
+			moved = append(moved, exdate)
+		}
+	}
+
+	if following.ExDates == nil {
+		following.ExDates = moved
+	}
+	following.Status = series.Status
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertEvent(ctx, tx, following); err != nil {
+		return err
+	}
+
+	query := "UPDATE events SET rrule = $1, exdates = $2, version = version + 1 WHERE id = $3"
+	if _, err := tx.ExecContext(ctx, query, before, kept, series.Id); err != nil {
+		return err
+	}
+
+	query = "UPDATE events SET series_id = $1 WHERE series_id = $2 AND occurrence_date >= $3"
+	if _, err := tx.ExecContext(ctx, query, following.Id, series.Id, date); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO event_members (event_id, user_id, role, added_by, created_at)
+		SELECT $1, user_id, role, added_by, created_at FROM event_members WHERE event_id = $2
+	`
+	if _, err := tx.ExecContext(ctx, query, following.Id, series.Id); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO event_invites (event_id, created_by, expires_at, max_uses, uses, created_at)
+		SELECT $1, created_by, expires_at, max_uses, uses, created_at FROM event_invites WHERE event_id = $2
+	`
+	if _, err := tx.ExecContext(ctx, query, following.Id, series.Id); err != nil {
+		return err
+	}
+
+	if err := updateOccurrences(ctx, tx, following.Id, following); err != nil {
+		return err
+	}
+
+	series.Rrule = before
+	series.ExDates = kept
+	series.Version++
+
+	if err := enqueue.run(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+SplitSeries implements editing "this and following" occurrences.
+The series is cut off the day before date and following is inserted as a
+new series that starts on date with the rest of the rule, unless it
+brings a rule of its own. Stored occurrences from date on move to the new
+series together with their attendees, and take over its details unless
+they were edited on their own. The members and invites of the series are
+copied to the new one, so co-hosts keep managing the occurrences they
+managed before the split. Invite links carry the id of the series they
+were made for, the copies get new ids and links of their own. When
+enqueue runs, series is already cut off and following has its id.
+*/
+
+func (m EventModel) CancelOccurrence(ctx context.Context, series *Event, date string, enqueue Enqueue) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.cancelOccurrence")
+	defer cancel()
+
+	ok, err := series.OccursOn(date)
+	if err != nil {
+		return err
+	}
+	if !ok || !series.IsRecurring() {
+		return ErrNoOccurrence
+	}
+
+	exdates := append(DateList{date}, series.ExDates...)
+	sort.Strings(exdates)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE events SET exdates = $1, version = version + 1 WHERE id = $2", exdates, series.Id); err != nil {
+		return err
+	}
+
+	query := "DELETE FROM events WHERE series_id = $1 AND occurrence_date = $2"
+	if _, err := tx.ExecContext(ctx, query, series.Id, date); err != nil {
+		return err
+	}
+
+	series.ExDates = exdates
+	series.Version++
+
+	if err := enqueue.run(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelOccurrence adds date to the excluded dates of the series and
+// removes the stored occurrence of that date together with its attendees.
+
+func (m EventModel) GetModifiedOccurrences(ctx context.Context, seriesId int) ([]*Event, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "events.getModifiedOccurrences")
+	defer cancel()
+
+	query := "SELECT " + selectEventColumns("") + " FROM events WHERE series_id = $1 AND modified ORDER BY occurrence_date"
+
+	rows, err := m.DB.QueryContext(ctx, query, seriesId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(eventFields(&event)...); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetModifiedOccurrences returns the occurrences of a series that were
+// edited on their own, the exceptions a calendar export has to list.
+
+func updateOccurrences(ctx context.Context, tx *sql.Tx, seriesId int, series *Event) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, occurrence_date FROM events WHERE series_id = $1 AND NOT modified", seriesId)
+	if err != nil {
+		return err
+	}
+
+	dates := map[int]string{}
+	for rows.Next() {
+		var id int
+		var date string
+		if err := rows.Scan(&id, &date); err != nil {
+			rows.Close()
+			return err
+		}
+		dates[id] = date
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE events SET name = $1, description = $2, starts_at = $3, ends_at = $4, timezone = $5,
+			location = $6, capacity = $7, visibility = $8, version = version + 1
+		WHERE id = $9
+	`
+
+	for id, date := range dates {
+		start, err := series.StartOn(date)
+		if err != nil {
+			return err
+		}
+		occurrence := series.occurrence(date, start)
+
+		_, err = tx.ExecContext(ctx, query, occurrence.Name, occurrence.Description, occurrence.StartsAt, occurrence.EndsAt,
+			occurrence.Timezone, occurrence.Location, occurrence.Capacity, occurrence.Visibility, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := promoteFromWaitlist(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+updateOccurrences copies the details of a series to its stored occurrences
+that were not edited on their own, including the time of day, so moving a
+series from 18:00 to 19:00 moves its occurrences too. The rows are read
+before anything is updated because a transaction can only run one query at
+a time on PostgreSQL.
+*/
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SessionModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+type Session struct {
+	Id        int
+	UserId    int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+/*
+A session is created on login and lives as long as its refresh token.
+Access tokens carry the id of their session, so revoking the session
+also invalidates every access token that was issued for it.
+Only the SHA-256 hash of the refresh token is stored, a leaked database
+does not leak usable tokens.
+*/
+
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+func (m *SessionModel) Insert(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) (*Session, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "sessions.insert")
+	defer cancel()
+
+	session := Session{
+		UserId:    userId,
+		ExpiresAt: expiresAt.UTC(),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	query := "INSERT INTO sessions (user_id, refresh_token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4) RETURNING id"
+	err := m.DB.QueryRowContext(ctx, query, session.UserId, tokenHash, session.ExpiresAt, session.CreatedAt).Scan(&session.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Here we create a new session for the user with the hash of its first refresh token.
+
+func (m *SessionModel) Get(ctx context.Context, id int) (*Session, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "sessions.get")
+	defer cancel()
+
+	query := "SELECT id, user_id, expires_at, created_at, revoked_at FROM sessions WHERE id = $1"
+
+	var session Session
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&session.Id, &session.UserId, &session.ExpiresAt, &session.CreatedAt, &session.RevokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// This method is used by the AuthMiddleware to check that the session of an access token is still active.
+
+func (m *SessionModel) Rotate(ctx context.Context, tokenHash, newTokenHash string, expiresAt time.Time) (*Session, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "sessions.rotate")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var session Session
+	query := "SELECT id, user_id, expires_at, created_at, revoked_at FROM sessions WHERE refresh_token_hash = $1"
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&session.Id, &session.UserId, &session.ExpiresAt, &session.CreatedAt, &session.RevokedAt)
+
+	if err == sql.ErrNoRows {
+		query = "UPDATE sessions SET revoked_at = $1 WHERE previous_token_hash = $2 AND revoked_at IS NULL"
+		result, err := tx.ExecContext(ctx, query, time.Now().UTC(), tokenHash)
+		if err != nil {
+			return nil, err
+		}
+		if reused, _ := result.RowsAffected(); reused > 0 {
+			if err := tx.Commit(); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !session.Active() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session.ExpiresAt = expiresAt.UTC()
+	query = `
+		UPDATE sessions SET refresh_token_hash = $1, previous_token_hash = $2, expires_at = $3
+		WHERE id = $4 AND refresh_token_hash = $2 AND revoked_at IS NULL
+	`
+	result, err := tx.ExecContext(ctx, query, newTokenHash, tokenHash, session.ExpiresAt, session.Id)
+	if err != nil {
+		return nil, err
+	}
+	if rotated, err := result.RowsAffected(); err != nil || rotated == 0 {
+		if err != nil {
+			return nil, err
+		}
+
+		query = "UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL"
+		if _, err := tx.ExecContext(ctx, query, time.Now().UTC(), session.Id); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+/*
+Rotate exchanges a refresh token for a new one. The old hash is kept as
+previous_token_hash: if it is ever presented again somebody else is
+using a copy of the token, so we revoke the whole session and return
+ErrRefreshTokenReused.
+The SELECT does not lock the row. Two requests with the same token can
+both find the session, on PostgreSQL they really run side by side. That
+is why the UPDATE only matches while the row still holds the old hash: the
+second one waits for the first to commit, then matches nothing and is
+treated as reuse like a token presented after its rotation.
+*/
+
+func (m *SessionModel) Revoke(ctx context.Context, id int) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "sessions.revoke")
+	defer cancel()
+
+	query := "UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL"
+	_, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), id)
+	return err
+}
+
+// Revoke ends a single session, this is what a logout does.
+
+func (m *SessionModel) RevokeAllForUser(ctx context.Context, userId int) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "sessions.revokeAllForUser")
+	defer cancel()
+
+	query := "UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL"
+	_, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), userId)
+	return err
+}
+
+// RevokeAllForUser ends every session of the user, logging them out on all devices.
+
+func (m *SessionModel) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "sessions.deleteExpired")
+	defer cancel()
+
+	query := "DELETE FROM sessions WHERE expires_at < $1"
+	result, err := m.DB.ExecContext(ctx, query, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteExpired removes sessions that can no longer be refreshed,
+// it is run periodically by the cleanup worker.
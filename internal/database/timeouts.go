@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Timeouts struct {
+	Default    time.Duration
+	Operations map[string]time.Duration
+}
+
+/*
+Timeouts holds how long a query may run. Default applies to every
+operation, Operations overrides it for single operations. An operation is
+named after the table and the model method in lowerCamelCase, for example
+events.search or attendees.setStatus.
+*/
+
+func (t Timeouts) For(operation string) time.Duration {
+	if timeout, ok := t.Operations[operation]; ok {
+		return timeout
+	}
+
+	if t.Default > 0 {
+		return t.Default
+	}
+
+	return 3 * time.Second
+}
+
+func (t Timeouts) WithTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, t.For(operation))
+}
+
+/*
+WithTimeout derives the query context from the context passed in by the
+caller, usually the context of the HTTP request. The query is cancelled
+when the timeout of the operation expires, but also as soon as the client
+disconnects or the server shuts down.
+*/
+
+func ParseTimeouts(defaultTimeout time.Duration, spec string) (Timeouts, error) {
+	timeouts := Timeouts{
+		Default:    defaultTimeout,
+		Operations: map[string]time.Duration{},
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		operation, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return Timeouts{}, fmt.Errorf("invalid query timeout %q, expected operation=duration", pair)
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return Timeouts{}, fmt.Errorf("invalid query timeout for %s: %w", operation, err)
+		}
+
+		timeouts.Operations[strings.TrimSpace(operation)] = timeout
+	}
+
+	return timeouts, nil
+}
+
+// ParseTimeouts reads the per operation overrides from a comma separated
+// list like "events.search=5s,events.getAll=2s".
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type TokenModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+const (
+	ScopePasswordReset     = "password-reset"
+	ScopeEmailVerification = "email-verification"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+/*
+The tokens table stores single-use tokens that are sent to users by email.
+The scope tells what a token can be used for, so a token issued for one
+flow can never be redeemed in another one. Like refresh tokens, only the
+hash of the token is stored.
+*/
+
+func (m *TokenModel) Insert(ctx context.Context, userId int, hash, scope string, expiresAt time.Time) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "tokens.insert")
+	defer cancel()
+
+	query := "INSERT INTO tokens (hash, user_id, scope, expires_at) VALUES ($1, $2, $3, $4)"
+	_, err := m.DB.ExecContext(ctx, query, hash, userId, scope, expiresAt.UTC())
+	return err
+}
+
+// Here we store a new token for the user that expires at the given time.
+
+func (m *TokenModel) Consume(ctx context.Context, hash, scope string) (int, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "tokens.consume")
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	query := `
+		UPDATE tokens SET used_at = $1
+		WHERE hash = $2 AND scope = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	`
+
+	var userId int
+	err := m.DB.QueryRowContext(ctx, query, now, hash, scope).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+/*
+Consume redeems a token and returns the id of its user.
+The token is marked as used in the same statement that checks it, so two
+requests with the same token can never both succeed.
+Unknown, expired and already used tokens all return ErrInvalidToken.
+*/
+
+func (m *TokenModel) DeleteAllForUser(ctx context.Context, userId int, scope string) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "tokens.deleteAllForUser")
+	defer cancel()
+
+	query := "DELETE FROM tokens WHERE user_id = $1 AND scope = $2"
+	_, err := m.DB.ExecContext(ctx, query, userId, scope)
+	return err
+}
+
+// DeleteAllForUser removes the outstanding tokens of a user for a scope,
+// for example every other reset link once the password was changed.
+
+func (m *TokenModel) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "tokens.deleteExpired")
+	defer cancel()
+
+	query := "DELETE FROM tokens WHERE expires_at < $1 OR used_at IS NOT NULL"
+	result, err := m.DB.ExecContext(ctx, query, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteExpired removes tokens that can no longer be redeemed.
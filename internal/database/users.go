@@ -7,29 +7,46 @@ import (
 )
 
 type UserModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
 }
 
 type User struct {
-	Id       int    `json:"id"`
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	Password string `json:"-"`
+	Id          int        `json:"id"`
+	Email       string     `json:"email"`
+	Name        string     `json:"name"`
+	Password    string     `json:"-"`
+	Role        string     `json:"role"`
+	VerifiedAt  *time.Time `json:"verifiedAt,omitempty"`
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"`
 }
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 /*
 The UserModel struct contains a DB field, which is a pointer to a sql.DB instance.
 The User struct includes four fields: Id, Email, Password, and Name.
 json tags are used to define how the struct fields are converted to and from JSON, ensuring proper data serialization and deserialization.
 The Password field is marked with a - in the json tag, instructing the JSON package to exclude it from JSON responses, making sure we don’t expose the password in the response.
+VerifiedAt is nil until the user confirmed their email address.
+Role is one of user, moderator or admin and decides what the user may do
+besides managing their own events. Suspended users can no longer log in.
 */
 
-func (m *UserModel) Insert(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *UserModel) Insert(ctx context.Context, user *User) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "users.insert")
 	defer cancel()
 
-	stmt := `INSERT INTO users (email, password, name) VALUES ($1, $2, $3) RETURNING id`
-	err := m.DB.QueryRowContext(ctx, stmt, user.Email, user.Password, user.Name).Scan(&user.Id)
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+
+	stmt := `INSERT INTO users (email, password, name, role) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := m.DB.QueryRowContext(ctx, stmt, user.Email, user.Password, user.Name, user.Role).Scan(&user.Id)
 	if err != nil {
 		return err
 	}
@@ -38,12 +55,12 @@ func (m *UserModel) Insert(user *User) error {
 
 //Here we insert the user into the database and return an error if there is one.
 
-func (m *UserModel) getUser(query string, args ...interface{}) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *UserModel) getUser(ctx context.Context, operation, query string, args ...interface{}) (*User, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, operation)
 	defer cancel()
 
 	var user User
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Id, &user.Email, &user.Name, &user.Password)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Id, &user.Email, &user.Name, &user.Password, &user.Role, &user.VerifiedAt, &user.SuspendedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -53,22 +70,116 @@ func (m *UserModel) getUser(query string, args ...interface{}) (*User, error) {
 	return &user, nil
 }
 
-func (m *UserModel) Get(id int) (*User, error) {
-	query := `SELECT * FROM users WHERE id = $1`
-	return m.getUser(query, id)
+func (m *UserModel) Get(ctx context.Context, id int) (*User, error) {
+	query := `SELECT id, email, name, password, role, verified_at, suspended_at FROM users WHERE id = $1`
+	return m.getUser(ctx, "users.get", query, id)
 }
 
-func (m *UserModel) GetByEmail(email string) (*User, error) {
-	query := `SELECT * FROM users WHERE email = $1`
-	return m.getUser(query, email)
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `SELECT id, email, name, password, role, verified_at, suspended_at FROM users WHERE email = $1`
+	return m.getUser(ctx, "users.getByEmail", query, email)
 }
 
 /*
 Here we did some refactoring and created a new method called getUser,
 notice the ...interface{} in the method signature.
 This allows us to pass in multiple arguments to the method.
+The operation name is passed along so Get and GetByEmail keep their own timeouts.
 Then we have the Get and GetByEmail methods that we can use
 to get a user by id or email.
 This refactoring reduces code duplication and centralizes the logic
 for querying and handling user data.
 */
+
+func (m *UserModel) UpdatePassword(ctx context.Context, id int, password string) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "users.updatePassword")
+	defer cancel()
+
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+	_, err := m.DB.ExecContext(ctx, query, password, id)
+	return err
+}
+
+// UpdatePassword stores a new password hash for the user.
+
+func (m *UserModel) MarkVerified(ctx context.Context, id int) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "users.markVerified")
+	defer cancel()
+
+	query := `UPDATE users SET verified_at = $1 WHERE id = $2 AND verified_at IS NULL`
+	_, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), id)
+	return err
+}
+
+// MarkVerified records that the user confirmed their email address.
+
+func (m *UserModel) GetAll(ctx context.Context) ([]*User, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "users.getAll")
+	defer cancel()
+
+	query := `SELECT id, email, name, password, role, verified_at, suspended_at FROM users ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.Id, &user.Email, &user.Name, &user.Password, &user.Role, &user.VerifiedAt, &user.SuspendedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetAll returns every user, it is only exposed to admins.
+
+func (m *UserModel) SetRole(ctx context.Context, id int, role string) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "users.setRole")
+	defer cancel()
+
+	query := `UPDATE users SET role = $1 WHERE id = $2`
+	_, err := m.DB.ExecContext(ctx, query, role, id)
+	return err
+}
+
+func (m *UserModel) SetSuspended(ctx context.Context, id int, suspended bool) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "users.setSuspended")
+	defer cancel()
+
+	var suspendedAt *time.Time
+	if suspended {
+		now := time.Now().UTC()
+		suspendedAt = &now
+	}
+
+	query := `UPDATE users SET suspended_at = $1 WHERE id = $2`
+	_, err := m.DB.ExecContext(ctx, query, suspendedAt, id)
+	return err
+}
+
+func (m *UserModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "users.delete")
+	defer cancel()
+
+	query := `DELETE FROM users WHERE id = $1`
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+/*
+SetRole, SetSuspended and Delete are used by the admin endpoints.
+Suspending keeps the account and everything it owns but blocks the user,
+deleting removes the user together with their events and attendances.
+*/
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+type WaitlistModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+type WaitlistEntry struct {
+	Id       int `json:"id"`
+	UserId   int `json:"userId"`
+	EventId  int `json:"eventId"`
+	Position int `json:"position"`
+}
+
+/*
+A waitlist entry is created when a user wants to attend an event that is
+already full. Position starts at 1 for the user that is next in line.
+*/
+
+func (m *WaitlistModel) GetByEvent(ctx context.Context, eventId int) ([]*WaitlistEntry, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "waitlist.getByEvent")
+	defer cancel()
+
+	query := "SELECT id, user_id, event_id, position FROM waitlist WHERE event_id = $1 ORDER BY position"
+
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*WaitlistEntry{}
+
+	for rows.Next() {
+		var entry WaitlistEntry
+		err := rows.Scan(&entry.Id, &entry.UserId, &entry.EventId, &entry.Position)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// This method returns the waitlist of an event, ordered by position.
+
+func (m *WaitlistModel) GetByEventAndUser(ctx context.Context, eventId, userId int) (*WaitlistEntry, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "waitlist.getByEventAndUser")
+	defer cancel()
+
+	query := "SELECT id, user_id, event_id, position FROM waitlist WHERE event_id = $1 AND user_id = $2"
+
+	var entry WaitlistEntry
+	err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(&entry.Id, &entry.UserId, &entry.EventId, &entry.Position)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// This method returns the waitlist entry of a user for an event, or nil
+// if the user is not waiting for it.
+
+func availableSpots(ctx context.Context, tx *sql.Tx, eventId int) (int, bool, error) {
+	var capacity sql.NullInt64
+	err := tx.QueryRowContext(ctx, "UPDATE events SET capacity = capacity WHERE id = $1 RETURNING capacity", eventId).Scan(&capacity)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if !capacity.Valid {
+		return 0, false, nil
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM attendees WHERE event_id = $1 AND status = 'going'", eventId).Scan(&count)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return int(capacity.Int64) - count, true, nil
+}
+
+/*
+availableSpots returns how many attendees can still be added to the event
+and whether the event is limited at all. Only attendees that are going count.
+The capacity is read with a no-op UPDATE instead of a SELECT: that locks the
+event row in PostgreSQL, the portable equivalent of SELECT ... FOR UPDATE,
+so concurrent transactions for the same event wait for each other and the
+count cannot change before the caller writes. SQLite already serializes
+the transactions because of _txlock=immediate.
+*/
+
+func addToWaitlist(ctx context.Context, tx *sql.Tx, eventId, userId int) (*WaitlistEntry, error) {
+	entry := WaitlistEntry{EventId: eventId, UserId: userId}
+
+	query := `
+		INSERT INTO waitlist (event_id, user_id, position)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(position), 0) + 1 FROM waitlist WHERE event_id = $1))
+		RETURNING id, position
+	`
+	err := tx.QueryRowContext(ctx, query, eventId, userId).Scan(&entry.Id, &entry.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// addToWaitlist puts the user at the end of the waitlist of the event.
+
+func getWaitlistEntry(ctx context.Context, tx *sql.Tx, eventId, userId int) (*WaitlistEntry, error) {
+	entry := WaitlistEntry{}
+
+	query := "SELECT id, user_id, event_id, position FROM waitlist WHERE event_id = $1 AND user_id = $2"
+	err := tx.QueryRowContext(ctx, query, eventId, userId).Scan(&entry.Id, &entry.UserId, &entry.EventId, &entry.Position)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// getWaitlistEntry is the transactional version of GetByEventAndUser.
+
+func removeFromWaitlist(ctx context.Context, tx *sql.Tx, entry *WaitlistEntry) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM waitlist WHERE id = $1", entry.Id)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE waitlist SET position = position - 1 WHERE event_id = $1 AND position > $2"
+	_, err = tx.ExecContext(ctx, query, entry.EventId, entry.Position)
+	return err
+}
+
+// removeFromWaitlist deletes the entry and moves everybody behind it one position up.
+
+func promoteFromWaitlist(ctx context.Context, tx *sql.Tx, eventId int) ([]*Attendee, error) {
+	spots, limited, err := availableSpots(ctx, tx, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	var promoted []*Attendee
+
+	for !limited || spots > 0 {
+		var entry WaitlistEntry
+		query := "SELECT id, user_id, event_id, position FROM waitlist WHERE event_id = $1 ORDER BY position LIMIT 1"
+		err := tx.QueryRowContext(ctx, query, eventId).Scan(&entry.Id, &entry.UserId, &entry.EventId, &entry.Position)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := removeFromWaitlist(ctx, tx, &entry); err != nil {
+			return nil, err
+		}
+
+		attendee := Attendee{EventId: entry.EventId, UserId: entry.UserId}
+		if err := upsertAttendee(ctx, tx, &attendee, StatusGoing); err != nil {
+			return nil, err
+		}
+
+		promoted = append(promoted, &attendee)
+		spots--
+	}
+
+	return promoted, nil
+}
+
+/*
+promoteFromWaitlist moves users from the front of the waitlist into the
+attendees table until the event is full again or the waitlist is empty.
+If the capacity was removed from the event, everybody on the waitlist gets in.
+*/
+
+func promotions(promoted []*Attendee) []RSVPChange {
+	changes := make([]RSVPChange, len(promoted))
+	for i, attendee := range promoted {
+		changes[i] = RSVPChange{UserId: attendee.UserId, After: attendee.Status}
+	}
+	return changes
+}
+
+// promotions describes the promoted attendees as RSVPChanges for enqueue.
+
+func upsertAttendee(ctx context.Context, tx *sql.Tx, attendee *Attendee, status string) error {
+	attendee.Status = status
+
+	query := `
+		INSERT INTO attendees (event_id, user_id, status) VALUES ($1, $2, $3)
+		ON CONFLICT (event_id, user_id) DO UPDATE SET status = excluded.status
+		RETURNING id, checked_in_at
+	`
+	return tx.QueryRowContext(ctx, query, attendee.EventId, attendee.UserId, status).Scan(&attendee.Id, &attendee.CheckedInAt)
+}
+
+// upsertAttendee sets the status of an existing attendee row,
+// or creates the row if the user has not answered yet.
+// ON CONFLICT relies on the unique index on (event_id, user_id) and works
+// the same way in SQLite and PostgreSQL.
@@ -0,0 +1,461 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookEventCreated   = "event.created"
+	WebhookEventUpdated   = "event.updated"
+	WebhookEventCancelled = "event.cancelled"
+	WebhookEventDeleted   = "event.deleted"
+	WebhookAttendeeJoined = "attendee.joined"
+	WebhookAttendeeLeft   = "attendee.left"
+)
+
+var WebhookTypes = []string{
+	WebhookEventCreated, WebhookEventUpdated, WebhookEventCancelled, WebhookEventDeleted,
+	WebhookAttendeeJoined, WebhookAttendeeLeft,
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+type Webhook struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"userId"`
+	EventId   *int      `json:"eventId,omitempty"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+/*
+A webhook tells another system about changes to events. Without EventId it
+covers every event its user owns, with EventId only that event and its
+occurrences. Events lists the notifications it wants, see WebhookTypes.
+Every request is signed with the Secret, which is only shown once when the
+webhook is created. A webhook of an event is deactivated once the event is
+deleted, after it was told about it.
+*/
+
+type Notification struct {
+	Type     string
+	EventId  int
+	SeriesId *int
+	OwnerId  int
+	Payload  []byte
+}
+
+type WebhookDelivery struct {
+	Id             int             `json:"id"`
+	WebhookId      int             `json:"webhookId"`
+	Type           string          `json:"type"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" enums:"pending,delivered,failed"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	ResponseStatus *int            `json:"responseStatus,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+}
+
+/*
+A Notification is written to the webhook_outbox table in the same
+transaction as the change it is about. Dispatch turns it into one
+WebhookDelivery per webhook that wants it, the deliveries double as the
+delivery log. Both live in the database, so notifications that were not
+sent yet survive a crash or a restart and are picked up again.
+*/
+
+var webhookColumns = "id, user_id, event_id, url, events, secret, active, created_at"
+
+func scanWebhook(scan func(...interface{}) error) (*Webhook, error) {
+	var webhook Webhook
+	var events string
+
+	err := scan(&webhook.Id, &webhook.UserId, &webhook.EventId, &webhook.URL, &events, &webhook.Secret,
+		&webhook.Active, &webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Events = strings.Split(events, ",")
+	return &webhook, nil
+}
+
+func (m WebhookModel) Insert(ctx context.Context, webhook *Webhook) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.insert")
+	defer cancel()
+
+	webhook.Active = true
+	webhook.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	query := `
+		INSERT INTO webhooks (user_id, event_id, url, events, secret, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+	`
+	return m.DB.QueryRowContext(ctx, query, webhook.UserId, webhook.EventId, webhook.URL,
+		strings.Join(webhook.Events, ","), webhook.Secret, webhook.Active, webhook.CreatedAt).Scan(&webhook.Id)
+}
+
+func (m WebhookModel) Get(ctx context.Context, id int) (*Webhook, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.get")
+	defer cancel()
+
+	row := m.DB.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id)
+	webhook, err := scanWebhook(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return webhook, err
+}
+
+func (m WebhookModel) list(ctx context.Context, query string, args ...interface{}) ([]*Webhook, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (m WebhookModel) GetByUser(ctx context.Context, userId int) ([]*Webhook, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.getByUser")
+	defer cancel()
+
+	return m.list(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE user_id = $1 ORDER BY id", userId)
+}
+
+func (m WebhookModel) GetByEvent(ctx context.Context, eventId int) ([]*Webhook, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.getByEvent")
+	defer cancel()
+
+	return m.list(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE event_id = $1 ORDER BY id", eventId)
+}
+
+// Get returns nil if the webhook does not exist. GetByUser lists every
+// webhook the user created, the ones of single events included.
+
+func (m WebhookModel) Delete(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.delete")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// Deleting a webhook also deletes its delivery log and the deliveries that
+// were not sent yet.
+
+type Enqueue func(ctx context.Context, tx *sql.Tx) error
+
+type RSVPChange struct {
+	UserId int
+	Before string
+	After  string
+}
+
+type EnqueueRSVPs func(ctx context.Context, tx *sql.Tx, changes []RSVPChange) error
+
+/*
+The models that change events and attendees take an Enqueue, or an
+EnqueueRSVPs for attendees, and call it inside their transaction after
+the change was written and before it commits. The handlers pass functions
+that write the notifications to the outbox with WebhookModel.Enqueue, so
+a change and its notifications are stored together or not at all. An
+error of the function rolls the change back. A nil function writes nothing.
+The RSVPChanges tell which users changed their status in the transaction,
+including the ones promoted from the waitlist. Before is empty for a user
+that had not answered, After is empty for one that was removed.
+*/
+
+func (enqueue Enqueue) run(ctx context.Context, tx *sql.Tx) error {
+	if enqueue == nil {
+		return nil
+	}
+	return enqueue(ctx, tx)
+}
+
+func (enqueue EnqueueRSVPs) run(ctx context.Context, tx *sql.Tx, changes []RSVPChange) error {
+	if enqueue == nil {
+		return nil
+	}
+	return enqueue(ctx, tx, changes)
+}
+
+func (m WebhookModel) Enqueue(ctx context.Context, tx *sql.Tx, notification *Notification) error {
+	query := `
+		INSERT INTO webhook_outbox (type, event_id, series_id, owner_id, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := tx.ExecContext(ctx, query, notification.Type, notification.EventId, notification.SeriesId,
+		notification.OwnerId, string(notification.Payload), time.Now().UTC())
+	return err
+}
+
+// Enqueue writes a notification to the outbox in the transaction of the
+// change it is about. It runs under the timeout of that transaction.
+
+type outboxEntry struct {
+	id int
+	Notification
+}
+
+func (m WebhookModel) Dispatch(ctx context.Context, limit int) (int, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.dispatch")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT id, type, event_id, series_id, owner_id, payload FROM webhook_outbox ORDER BY id LIMIT $1"
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var entries []outboxEntry
+	for rows.Next() {
+		var entry outboxEntry
+		var payload string
+		if err := rows.Scan(&entry.id, &entry.Type, &entry.EventId, &entry.SeriesId, &entry.OwnerId, &payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entry.Payload = []byte(payload)
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		if err := fanOut(ctx, tx, entry, now); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(entries), tx.Commit()
+}
+
+func fanOut(ctx context.Context, tx *sql.Tx, entry outboxEntry, now time.Time) error {
+	seriesId := entry.EventId
+	if entry.SeriesId != nil {
+		seriesId = *entry.SeriesId
+	}
+
+	query := `
+		SELECT id, events FROM webhooks
+		WHERE active AND ((event_id IS NULL AND user_id = $1) OR event_id = $2 OR event_id = $3)
+	`
+	rows, err := tx.QueryContext(ctx, query, entry.OwnerId, entry.EventId, seriesId)
+	if err != nil {
+		return err
+	}
+
+	var webhookIds []int
+	for rows.Next() {
+		var id int
+		var events string
+		if err := rows.Scan(&id, &events); err != nil {
+			rows.Close()
+			return err
+		}
+		if slices.Contains(strings.Split(events, ","), entry.Type) {
+			webhookIds = append(webhookIds, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO webhook_deliveries (webhook_id, outbox_id, type, payload, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (webhook_id, outbox_id) DO NOTHING
+	`
+	for _, id := range webhookIds {
+		if _, err := tx.ExecContext(ctx, query, id, entry.id, entry.Type, string(entry.Payload), now, now); err != nil {
+			return err
+		}
+	}
+
+	if entry.Type == WebhookEventDeleted {
+		if _, err := tx.ExecContext(ctx, "UPDATE webhooks SET active = FALSE WHERE event_id = $1", entry.EventId); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM webhook_outbox WHERE id = $1", entry.id)
+	return err
+}
+
+/*
+Dispatch moves up to limit notifications from the outbox to the deliveries
+of the webhooks that want them and reports how many it handled. Every
+notification is deleted from the outbox in the same transaction its
+deliveries are created in, so it is neither lost nor delivered twice;
+should two servers dispatch the same notification at once, the unique
+index on (webhook_id, outbox_id) drops the second copy. The webhooks of a
+series also get the notifications of its occurrences.
+*/
+
+var deliveryColumns = `id, webhook_id, type, payload, status, attempts, next_attempt_at, response_status,
+	last_error, created_at, delivered_at`
+
+func scanDelivery(scan func(...interface{}) error) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	var payload string
+
+	err := scan(&delivery.Id, &delivery.WebhookId, &delivery.Type, &payload, &delivery.Status, &delivery.Attempts,
+		&delivery.NextAttemptAt, &delivery.ResponseStatus, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = json.RawMessage(payload)
+	return &delivery, nil
+}
+
+func (m WebhookModel) deliveries(ctx context.Context, query string, args ...interface{}) ([]*WebhookDelivery, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (m WebhookModel) GetDeliveries(ctx context.Context, webhookId, limit int) ([]*WebhookDelivery, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.getDeliveries")
+	defer cancel()
+
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2"
+	return m.deliveries(ctx, query, webhookId, limit)
+}
+
+func (m WebhookModel) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*WebhookDelivery, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.dueDeliveries")
+	defer cancel()
+
+	query := `
+		SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at, id LIMIT $2
+	`
+	return m.deliveries(ctx, query, now.UTC(), limit)
+}
+
+// GetDeliveries returns the delivery log of a webhook, newest first.
+// DueDeliveries returns the pending deliveries whose next attempt is due.
+
+func (m WebhookModel) Claim(ctx context.Context, delivery *WebhookDelivery, lease time.Time) (bool, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.claim")
+	defer cancel()
+
+	query := `
+		UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = $1
+		WHERE id = $2 AND status = 'pending' AND attempts = $3
+	`
+	result, err := m.DB.ExecContext(ctx, query, lease.UTC(), delivery.Id, delivery.Attempts)
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil || claimed == 0 {
+		return false, err
+	}
+
+	delivery.Attempts++
+	return true, nil
+}
+
+/*
+Claim counts an attempt before the request is sent and pushes the next
+attempt to lease. If the server crashes while sending, the delivery is
+retried once the lease ran out instead of being lost. The attempts in the
+WHERE make sure only one worker sends it when several servers share the
+database.
+*/
+
+func (m WebhookModel) SaveAttempt(ctx context.Context, delivery *WebhookDelivery) error {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.saveAttempt")
+	defer cancel()
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, next_attempt_at = $2, response_status = $3, last_error = $4, delivered_at = $5
+		WHERE id = $6
+	`
+	_, err := m.DB.ExecContext(ctx, query, delivery.Status, delivery.NextAttemptAt, delivery.ResponseStatus,
+		delivery.LastError, delivery.DeliveredAt, delivery.Id)
+	return err
+}
+
+// SaveAttempt stores the outcome of the last attempt: delivered, failed for
+// good, or pending with the time of the next attempt.
+
+func (m WebhookModel) DeleteOldDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := m.Timeouts.WithTimeout(ctx, "webhooks.deleteOldDeliveries")
+	defer cancel()
+
+	query := "DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1"
+	result, err := m.DB.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteOldDeliveries trims the delivery log, pending deliveries are kept
+// however old they are.
@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 func GetEnvString(key, defaultValue string) string {
@@ -21,9 +22,19 @@ func GetEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return defaultValue
+}
+
 /*
-The GetEnvString and GetEnvInt functions are used
+The GetEnvString, GetEnvInt and GetEnvDuration functions are used
 to get the value of an environment variable.
+Durations use the time.ParseDuration format, for example 15m or 720h.
 If the environment variable is not set,
 the function returns the default value.
 */
@@ -0,0 +1,192 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	Id       string
+	Type     string
+	EventId  int
+	SeriesId int
+	Public   bool
+	Data     json.RawMessage
+
+	seq uint64
+}
+
+/*
+A Message is one change to an event or its attendees. SeriesId is the id
+of the series for an occurrence and 0 otherwise, Public tells whether
+everybody may see the event. Id is assigned by Publish and is what clients
+send back in Last-Event-ID when they reconnect.
+*/
+
+type Subscription struct {
+	messages chan Message
+	filter   func(Message) bool
+}
+
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Messages is closed when the hub shuts down or the subscriber fell so far
+// behind that its buffer overflowed. The client reconnects and catches up
+// from the history.
+
+type Hub struct {
+	mu          sync.Mutex
+	boot        string
+	seq         uint64
+	history     []Message
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func New(historySize, bufferSize int) *Hub {
+	return &Hub{
+		boot:        strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+/*
+Hub is an in-process publish/subscribe hub. The handlers publish every
+change, every open stream subscribes with a filter for the messages it
+wants. The last historySize messages are kept so a client that lost its
+connection can get what it missed. Ids start with the time the hub was
+created, an id of an earlier run of the server cannot be resumed.
+The hub only reaches the streams of its own process, with several servers
+behind a load balancer a client only hears about the changes made through
+the server it is connected to.
+*/
+
+func (h *Hub) Publish(message Message) Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return message
+	}
+
+	h.seq++
+	message.seq = h.seq
+	message.Id = fmt.Sprintf("%s-%d", h.boot, h.seq)
+
+	h.history = append(h.history, message)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for subscriber := range h.subscribers {
+		if !subscriber.filter(message) {
+			continue
+		}
+
+		select {
+		case subscriber.messages <- message:
+		default:
+			delete(h.subscribers, subscriber)
+			close(subscriber.messages)
+		}
+	}
+
+	return message
+}
+
+// Publish never blocks on a slow subscriber, a subscriber whose buffer is
+// full is dropped instead.
+
+func (h *Hub) Subscribe(filter func(Message) bool, lastEventId string) (*Subscription, []Message, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscription := &Subscription{
+		messages: make(chan Message, h.bufferSize),
+		filter:   filter,
+	}
+
+	if h.closed {
+		close(subscription.messages)
+		return subscription, nil, false
+	}
+	h.subscribers[subscription] = struct{}{}
+
+	if lastEventId == "" {
+		return subscription, nil, true
+	}
+
+	after, ok := h.parseId(lastEventId)
+	if !ok {
+		return subscription, nil, false
+	}
+	if len(h.history) > 0 && after+1 < h.history[0].seq {
+		return subscription, nil, false
+	}
+
+	var missed []Message
+	for _, message := range h.history {
+		if message.seq > after && filter(message) {
+			missed = append(missed, message)
+		}
+	}
+
+	return subscription, missed, true
+}
+
+/*
+Subscribe registers a subscriber and returns the messages it missed since
+lastEventId. Both happen under the same lock as Publish, so no message is
+lost or sent twice between the replay and the live messages. The last
+result is false if the missed messages cannot be told: the id is unknown,
+comes from an earlier run, or is older than the history. The client then
+has to load the current state again.
+*/
+
+func (h *Hub) parseId(id string) (uint64, bool) {
+	boot, seq, ok := strings.Cut(id, "-")
+	if !ok || boot != h.boot {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n > h.seq {
+		return 0, false
+	}
+
+	return n, true
+}
+
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[subscription]; ok {
+		delete(h.subscribers, subscription)
+		close(subscription.messages)
+	}
+}
+
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for subscription := range h.subscribers {
+		delete(h.subscribers, subscription)
+		close(subscription.messages)
+	}
+}
+
+// Close ends every subscription, the streams return and the server can shut
+// down without waiting for them.
@@ -0,0 +1,179 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Event struct {
+	UID          string
+	RecurrenceId time.Time
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	Sequence     int
+	Status       string
+	Summary      string
+	Description  string
+	Location     string
+	RRule        string
+	ExDates      []time.Time
+	URL          string
+}
+
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+/*
+Calendar and Event hold just what EventApp needs to publish events in
+the iCalendar format of RFC 5545. The location of Start decides how times
+are written: in UTC, or as local time with the TZID of the zone, which
+keeps recurring events at the same local time across DST changes.
+AllDay events are written as DATE values. RecurrenceId marks an event that
+replaces a single occurrence of the recurring event with the same UID.
+Status is one of the STATUS values of a VEVENT, like StatusCancelled, or
+empty to leave it out.
+*/
+
+const StatusCancelled = "CANCELLED"
+
+const (
+	dateFormat      = "20060102"
+	localTimeFormat = "20060102T150405"
+	utcTimeFormat   = "20060102T150405Z"
+)
+
+func escapeText(value string) string {
+	value = strings.ReplaceAll(value, "\r", "")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// escapeText escapes the characters that have a meaning in TEXT values.
+
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *writer) line(content string) {
+	if w.err != nil {
+		return
+	}
+
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		for !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		if _, w.err = w.w.WriteString(content[:cut] + "\r\n "); w.err != nil {
+			return
+		}
+		content = content[cut:]
+		limit = 74
+	}
+
+	_, w.err = w.w.WriteString(content + "\r\n")
+}
+
+/*
+Lines end with CRLF and are folded after 75 octets: the rest continues on
+the next line after a single space. We never cut through a multi-byte
+UTF-8 character. Continuation lines hold one octet less because of the
+leading space.
+*/
+
+func formatTime(value time.Time, allDay bool) (string, string) {
+	switch {
+	case allDay:
+		return ";VALUE=DATE", value.Format(dateFormat)
+	case value.Location() == time.UTC:
+		return "", value.Format(utcTimeFormat)
+	default:
+		return ";TZID=" + value.Location().String(), value.Format(localTimeFormat)
+	}
+}
+
+func (w *writer) time(name string, value time.Time, allDay bool) {
+	params, formatted := formatTime(value, allDay)
+	w.line(name + params + ":" + formatted)
+}
+
+/*
+formatTime returns the parameters and the value of a DATE or DATE-TIME
+property. Zones are referenced by their IANA name without a VTIMEZONE
+component, the common calendar apps all know these names.
+*/
+
+func (w *writer) text(name, value string) {
+	if value != "" {
+		w.line(name + ":" + escapeText(value))
+	}
+}
+
+func (cal *Calendar) Write(out io.Writer) error {
+	w := &writer{w: bufio.NewWriter(out)}
+	stamp := time.Now().UTC().Format(utcTimeFormat)
+
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//EventApp//EventApp API//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.text("X-WR-CALNAME", cal.Name)
+
+	for _, event := range cal.Events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + event.UID)
+		w.line("DTSTAMP:" + stamp)
+		if event.Sequence > 0 {
+			w.line("SEQUENCE:" + strconv.Itoa(event.Sequence))
+		}
+		if !event.RecurrenceId.IsZero() {
+			w.time("RECURRENCE-ID", event.RecurrenceId, event.AllDay)
+		}
+		w.time("DTSTART", event.Start, event.AllDay)
+		w.time("DTEND", event.End, event.AllDay)
+		if event.Status != "" {
+			w.line("STATUS:" + event.Status)
+		}
+		if event.RRule != "" {
+			w.line("RRULE:" + event.RRule)
+		}
+		if len(event.ExDates) > 0 {
+			var params string
+			dates := make([]string, len(event.ExDates))
+			for i, date := range event.ExDates {
+				params, dates[i] = formatTime(date, event.AllDay)
+			}
+			w.line("EXDATE" + params + ":" + strings.Join(dates, ","))
+		}
+		w.text("SUMMARY", event.Summary)
+		w.text("DESCRIPTION", event.Description)
+		w.text("LOCATION", event.Location)
+		if event.URL != "" {
+			w.line("URL:" + event.URL)
+		}
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+/*
+Write renders the calendar. DTEND is exclusive in iCalendar, an all-day
+event that lasts one day ends on the next day. Calendar apps recognise updates
+by the UID, so callers must derive it from something that never changes,
+like the id of the event, and take a higher SEQUENCE as a newer revision.
+*/
@@ -0,0 +1,265 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ParsedEvent struct {
+	Row   int
+	Event Event
+	Err   error
+}
+
+var ErrNotACalendar = errors.New("not an iCalendar file")
+
+/*
+ParsedEvent is one VEVENT read from a file. Row counts the VEVENTs from 1,
+Err is set when the VEVENT could not be read, the other events are still
+returned so the caller can report every problem at once.
+*/
+
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// unfold joins folded lines again, a line starting with a space or tab
+// continues the previous one.
+
+func unescapeText(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(value[i])
+			}
+			continue
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+func parseTime(params map[string]string, value string) (time.Time, bool, error) {
+	zone := time.UTC
+	if tzid, ok := params["TZID"]; ok {
+		var err error
+		if zone, err = time.LoadLocation(strings.Trim(tzid, `"`)); err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+
+	var (
+		result time.Time
+		err    error
+	)
+
+	allDay := params["VALUE"] == "DATE" || len(value) == len(dateFormat)
+	switch {
+	case allDay:
+		result, err = time.ParseInLocation(dateFormat, value, zone)
+	case strings.HasSuffix(value, "Z"):
+		result, err = time.Parse(utcTimeFormat, value)
+	default:
+		result, err = time.ParseInLocation(localTimeFormat, value, zone)
+	}
+
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date or time %q", value)
+	}
+
+	return result, allDay, nil
+}
+
+/*
+parseTime reads DATE and DATE-TIME values. Times in UTC end in Z, local
+times use the zone named by TZID, and floating times without either are
+taken as UTC because an event needs a fixed start.
+*/
+
+func parseDuration(value string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid duration %q", value)
+
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(value, "+"), "P")
+	if !ok || rest == "" {
+		return 0, invalid
+	}
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var duration time.Duration
+
+	for rest != "" {
+		if rest[0] == 'T' {
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+			rest = rest[1:]
+			continue
+		}
+
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(rest) {
+			return 0, invalid
+		}
+
+		n, err := strconv.Atoi(rest[:i])
+		unit, ok := units[rest[i]]
+		if err != nil || !ok {
+			return 0, invalid
+		}
+
+		duration += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+
+	return duration, nil
+}
+
+// parseDuration reads positive DURATION values like PT1H30M or P1D, a day
+// always counts as 24 hours.
+
+func parseProperty(line string) (string, map[string]string, string, bool) {
+	nameAndParams, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(nameAndParams, ";")
+	params := map[string]string{}
+	for _, param := range parts[1:] {
+		key, paramValue, _ := strings.Cut(param, "=")
+		params[strings.ToUpper(key)] = paramValue
+	}
+
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+func Parse(r io.Reader) ([]ParsedEvent, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, ErrNotACalendar
+	}
+
+	var (
+		events   []ParsedEvent
+		current  *ParsedEvent
+		duration time.Duration
+	)
+
+	for _, line := range lines {
+		name, params, value, ok := parseProperty(line)
+		if !ok {
+			continue
+		}
+
+		if name == "BEGIN" && strings.EqualFold(value, "VEVENT") {
+			events = append(events, ParsedEvent{Row: len(events) + 1})
+			current = &events[len(events)-1]
+			duration = 0
+			continue
+		}
+		if name == "END" && strings.EqualFold(value, "VEVENT") {
+			if current != nil && current.Err == nil {
+				finishEvent(&current.Event, duration)
+			}
+			current = nil
+			continue
+		}
+		if current == nil || current.Err != nil {
+			continue
+		}
+
+		event := &current.Event
+
+		switch name {
+		case "UID":
+			event.UID = value
+		case "SUMMARY":
+			event.Summary = unescapeText(value)
+		case "DESCRIPTION":
+			event.Description = unescapeText(value)
+		case "LOCATION":
+			event.Location = unescapeText(value)
+		case "URL":
+			event.URL = value
+		case "RRULE":
+			event.RRule = value
+		case "STATUS":
+			event.Status = strings.ToUpper(value)
+		case "DTSTART":
+			event.Start, event.AllDay, current.Err = parseTime(params, value)
+		case "DTEND":
+			event.End, _, current.Err = parseTime(params, value)
+		case "DURATION":
+			duration, current.Err = parseDuration(value)
+		case "RECURRENCE-ID":
+			event.RecurrenceId, _, current.Err = parseTime(params, value)
+		case "EXDATE":
+			for _, exdate := range strings.Split(value, ",") {
+				date, _, err := parseTime(params, exdate)
+				if err != nil {
+					current.Err = err
+					break
+				}
+				event.ExDates = append(event.ExDates, date)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+/*
+Parse reads the VEVENTs of an iCalendar file. It understands the
+properties Write produces, other properties and components are skipped.
+*/
+
+func finishEvent(event *Event, duration time.Duration) {
+	if !event.End.IsZero() || event.Start.IsZero() {
+		return
+	}
+
+	switch {
+	case duration > 0:
+		event.End = event.Start.Add(duration)
+	case event.AllDay:
+		event.End = event.Start.AddDate(0, 0, 1)
+	default:
+		event.End = event.Start
+	}
+}
+
+/*
+finishEvent fills in End when the VEVENT has no DTEND. As RFC 5545
+defines it, an all-day event without DURATION lasts one day and any other
+event ends when it starts.
+*/
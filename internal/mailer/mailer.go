@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+/*
+Mailer is implemented by everything that can deliver an email.
+The handlers only depend on this interface, so the delivery can be
+switched between SMTP in production and LogMailer for development and tests.
+*/
+
+type LogMailer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewLogMailer(out io.Writer) *LogMailer {
+	return &LogMailer{out: out}
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.out, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), to, subject, body)
+	return err
+}
+
+/*
+LogMailer does not deliver anything, it writes every email to the given
+writer instead, usually stdout or a file. This lets us run the password
+reset flow offline and read the token from the output.
+*/